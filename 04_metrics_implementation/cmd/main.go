@@ -1,8 +1,11 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"io"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -29,19 +32,107 @@ func main() {
 	// 設定の読み込み
 	cfg := config.NewConfig()
 
-	// OTEL Provider の初期化
-	provider, err := otel.NewProvider(ctx, otel.Config{
-		ServiceName:    cfg.ServiceName,
-		ServiceVersion: cfg.ServiceVersion,
-		Environment:    cfg.Environment,
+	// OTEL_* 環境変数の読み込み
+	otelCfg, err := loadOTELConfig(cfg)
+	if err != nil {
+		slog.ErrorContext(ctx, "invalid otel environment variables", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+
+	// NOTE: どのホスト / コンテナ / Pod のどのビルドが出力したテレメトリかを識別する Detector は、
+	// 実行環境に合わせて OTEL_RESOURCE_DETECTORS で有効化する (例: OTEL_RESOURCE_DETECTORS=all)
+
+	// NOTE: ランタイムの状態 (ゴルーチン・メモリ・GC 等) のメトリクスは OTEL_GO_RUNTIME_METRICS=true で有効化する
+
+	// メトリクスの View
+	// NOTE: article.views.total の article_id 属性は記事数に比例して時系列が増えるため (usecase/get_by_id.go 参照)、
+	// 計装コードは変更せずに View で category_id のみを残してエクスポートする
+	otelCfg.MetricViews = append(otelCfg.MetricViews, otel.MetricView{
+		Instrument:    "article.views.total",
+		AttributeKeys: []string{"category_id"},
 	})
+
+	// 個人情報の置き換え
+	// NOTE: 記事タイトルはハッシュ化してトレースとログ (handler の title 属性) を突き合わせられるようにし、
+	// メールアドレス・ユーザー名はキーまたは値の形式でマスクする (エラーメッセージに含まれる場合も対象)
+	otelCfg.Redaction = otel.RedactionConfig{
+		Rules: []otel.RedactionRule{
+			{Keys: []string{"article.title", "title"}, Action: otel.RedactionHash},
+			{Keys: []string{"*email*", "user.name"}, Action: otel.RedactionMask},
+			{Values: []string{`[\w.+-]+@[\w-]+\.[\w.-]+`}, Action: otel.RedactionMask},
+		},
+	}
+
+	// トレースコンテキストの伝搬形式
+	// NOTE: B3 / X-Ray のヘッダーを送信する既存のサービスからのリクエストもトレースに参加させる。
+	// 受信時は後に指定した形式が優先されるため、W3C Trace Context を最後に指定する (OTEL_PROPAGATORS で上書き可能)
+	if len(otelCfg.Propagators) == 0 {
+		otelCfg.Propagators = []otel.Propagator{
+			otel.PropagatorB3,
+			otel.PropagatorXRay,
+			otel.PropagatorTraceContext,
+			otel.PropagatorBaggage,
+		}
+	}
+
+	// Baggage の属性化
+	// NOTE: 上流から baggage ヘッダーで伝搬されたテナントとクライアントアプリをスパン・ログに付与する。
+	// client.app は値の種類が少ないためメトリクスにも付与し、アプリごとの閲覧数や処理時間を集計できるようにする
+	otelCfg.Baggage = otel.BaggageConfig{
+		Keys:       []string{"tenant.id", "client.app"},
+		MetricKeys: []string{"client.app"},
+	}
+
+	if cfg.OTELQueueDir != "" {
+		// NOTE: Collector の停止中に送信できなかったテレメトリはディスクに退避し、復旧後 (再起動後を含む) に再送する
+		otelCfg.PersistentQueue = otel.PersistentQueueConfig{
+			Dir:     cfg.OTELQueueDir,
+			MaxSize: 512 * 1024 * 1024,
+			MaxAge:  6 * time.Hour,
+		}
+	}
+
+	// OTEL Provider の初期化
+	// NOTE: 追加のエクスポート先 (ローカルの Collector・エラーのスパンのファイル) は任意のため、
+	// 作成に失敗した場合は警告を出力し、通常のエクスポート先のみで起動する
+	provider, err := otel.NewProvider(ctx, withOptionalPipelines(otelCfg, cfg))
+	if err != nil && (cfg.LocalCollectorEndpoint != "" || cfg.ErrorSpansFile != "") {
+		slog.WarnContext(ctx, "failed to initialize optional otel pipelines; continuing without them", slog.String("error", err.Error()))
+		provider, err = otel.NewProvider(ctx, otelCfg)
+	}
 	if err != nil {
 		slog.ErrorContext(ctx, "failed to initialize otel", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
+	// Provider 初期化後の Logger に差し替え
+	// NOTE: ログファイルが設定されている場合は標準出力とファイルの両方に出力する
+	var logOutput io.Writer = os.Stdout
+	if provider.LogWriter != nil {
+		logOutput = io.MultiWriter(os.Stdout, provider.LogWriter)
+	}
+	// NOTE: LoggerProvider を渡すことで、同じログを OTel の LogRecord としてもエクスポートする
+	logger := slog.New(otel.NewOTELHandler(
+		slog.NewJSONHandler(logOutput, nil),
+		otel.WithLoggerProvider(provider.LoggerProvider),
+		otel.WithRedactor(provider.Redactor),      // NOTE: スパンと同じルールでログの属性を置き換える
+		otel.WithBaggage(otelCfg.Baggage.Keys...), // NOTE: スパンと同じ Baggage のメンバーをログにも付与する
+	))
+	slog.SetDefault(logger)
+
 	// 依存関係の初期化
-	container := di.NewContainer()
+	// NOTE: Tracer / Meter / Logger はグローバルではなく otel.Telemetry として各層のコンストラクタに渡す
+	// NOTE: Prometheus の専用ポートを指定していない場合は API サーバーの /metrics で公開する
+	var metricsHandler http.Handler
+	if otelCfg.Prometheus.Addr == "" {
+		metricsHandler = provider.MetricsHandler
+	}
+	container, err := di.NewContainer(provider.Telemetry(logger), metricsHandler)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to initialize dependencies", slog.String("error", err.Error()))
+		_ = provider.Shutdown(ctx)
+		os.Exit(1)
+	}
 
 	// サーバー起動 (別goroutine)
 	go func() {
@@ -70,3 +161,71 @@ func main() {
 
 	slog.InfoContext(ctx, "shutdown complete")
 }
+
+// loadOTELConfig は OTEL_* 環境変数から otel.Config を読み込み、未設定の項目に cfg の値を適用する
+//
+// NOTE: config のサービス名等は環境変数が未設定の場合のデフォルト値として扱い、再ビルドなしでデプロイ先ごとに上書きできるようにする
+// (例: OTEL_RESOURCE_ATTRIBUTES=deployment.environment=production)。
+func loadOTELConfig(cfg *config.Config) (otel.Config, error) {
+	otelCfg, err := otel.LoadConfigFromEnv(otel.Config{})
+	if err != nil {
+		return otel.Config{}, err
+	}
+	otelCfg.ServiceName = cmp.Or(otelCfg.ServiceName, cfg.ServiceName)
+	otelCfg.ServiceVersion = cmp.Or(otelCfg.ServiceVersion, cfg.ServiceVersion)
+	otelCfg.Environment = cmp.Or(otelCfg.Environment, cfg.Environment)
+
+	// NOTE: 開発環境ではスパンをトレースごとのツリーで出力する (OTEL_TRACES_EXPORTER=console で従来の JSON 出力に戻せる)。
+	// 環境変数で development 以外の環境を指定した場合は、これまで通りエクスポーターのデフォルトに従う
+	if otelCfg.Environment == "development" && otelCfg.TraceExporter.Type == "" {
+		otelCfg.TraceExporter.Type = otel.ExporterConsoleTree
+	}
+	return otelCfg, nil
+}
+
+// withOptionalPipelines は cfg で有効化された追加のエクスポート先のパイプラインを otelCfg に追加する
+//
+//   - LocalCollectorEndpoint: トレースとメトリクスをローカルの Collector にも送信し、バックエンドの UI でも確認できるようにする
+//   - ErrorSpansFile: エラーのスパンのみをファイルに残し、Collector の障害時にも調査できるようにする
+func withOptionalPipelines(otelCfg otel.Config, cfg *config.Config) otel.Config {
+	traces := []otel.TracePipeline{{Exporter: otelCfg.TraceExporter}}
+	metrics := []otel.MetricPipeline{{Exporter: otelCfg.MetricExporter}}
+
+	if cfg.LocalCollectorEndpoint != "" {
+		localCollector := otel.ExporterConfig{
+			Type: otel.ExporterOTLPGRPC,
+			OTLP: otel.OTLPConfig{Endpoint: cfg.LocalCollectorEndpoint, Insecure: true},
+		}
+		// NOTE: OTEL_*_EXPORTER=otlp で既に Collector に送信しているシグナルは、同じデータを重複して送信しないよう追加しない
+		if !isOTLP(otelCfg.TraceExporter) {
+			traces = append(traces, otel.TracePipeline{Name: "local-collector", Exporter: localCollector})
+		}
+		if !isOTLP(otelCfg.MetricExporter) {
+			metrics = append(metrics, otel.MetricPipeline{Name: "local-collector", Exporter: localCollector})
+		}
+	}
+	if cfg.ErrorSpansFile != "" {
+		traces = append(traces, otel.TracePipeline{
+			Name: "error-spans",
+			Exporter: otel.ExporterConfig{
+				Type: otel.ExporterFile,
+				File: otel.FileConfig{Path: cfg.ErrorSpansFile, MaxBackups: 7},
+			},
+			Batch:  otel.BatchConfig{Timeout: time.Second}, // NOTE: エラーは件数が少ないため、溜めずに早めに書き出す
+			Filter: otel.SpanFilter{Statuses: []otel.SpanStatus{otel.SpanStatusError}},
+		})
+	}
+
+	if len(traces) > 1 {
+		otelCfg.TracePipelines = traces
+	}
+	if len(metrics) > 1 {
+		otelCfg.MetricPipelines = metrics
+	}
+	return otelCfg
+}
+
+// isOTLP は exporter が OTLP で送信するかを返す
+func isOTLP(exporter otel.ExporterConfig) bool {
+	return exporter.Type == otel.ExporterOTLPGRPC || exporter.Type == otel.ExporterOTLPHTTP
+}
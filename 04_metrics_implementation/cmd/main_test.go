@@ -0,0 +1,57 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/config"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
+)
+
+func TestLoadOTELConfigTraceExporterDefault(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		wantEnvironment string
+		wantExporter    otel.ExporterType
+	}{
+		{
+			name:            "development by default renders the console tree",
+			wantEnvironment: "development",
+			wantExporter:    otel.ExporterConsoleTree,
+		},
+		{
+			name:            "production keeps the exporter default",
+			env:             map[string]string{"OTEL_RESOURCE_ATTRIBUTES": "deployment.environment=production"},
+			wantEnvironment: "production",
+		},
+		{
+			name:            "staging keeps the exporter default",
+			env:             map[string]string{"OTEL_RESOURCE_ATTRIBUTES": "deployment.environment=staging"},
+			wantEnvironment: "staging",
+		},
+		{
+			name:            "explicit exporter in development",
+			env:             map[string]string{"OTEL_TRACES_EXPORTER": "console"},
+			wantEnvironment: "development",
+			wantExporter:    otel.ExporterStdout,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NOTE: 実行環境の変数の影響を受けないように、参照する変数を全て設定する
+			for _, k := range []string{"OTEL_RESOURCE_ATTRIBUTES", "OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"} {
+				t.Setenv(k, tt.env[k])
+			}
+			otelCfg, err := loadOTELConfig(config.NewConfig())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if otelCfg.Environment != tt.wantEnvironment {
+				t.Errorf("Environment = %q, want %q", otelCfg.Environment, tt.wantEnvironment)
+			}
+			if otelCfg.TraceExporter.Type != tt.wantExporter {
+				t.Errorf("TraceExporter.Type = %q, want %q", otelCfg.TraceExporter.Type, tt.wantExporter)
+			}
+		})
+	}
+}
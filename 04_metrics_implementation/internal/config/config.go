@@ -1,17 +1,34 @@
 package config
 
+import "os"
+
 // Config はアプリケーション設定
 type Config struct {
 	ServiceName    string
 	ServiceVersion string
 	Environment    string
+
+	// LocalCollectorEndpoint はトレースとメトリクスを追加で送信するローカルの Collector のアドレス (例: "localhost:4317")。
+	// 空の場合は送信しない (環境変数 LOCAL_COLLECTOR_ENDPOINT)
+	LocalCollectorEndpoint string
+
+	// ErrorSpansFile はエラーのスパンのみを追加で出力するファイルのパス (例: "/var/log/article-api/error-spans.jsonl")。
+	// 空の場合は出力しない (環境変数 ERROR_SPANS_FILE)
+	ErrorSpansFile string
+
+	// OTELQueueDir は Collector に送信できなかったテレメトリを退避するディレクトリ (例: "/var/lib/article-api/otel-queue")。
+	// 空の場合は退避しない (環境変数 OTEL_QUEUE_DIR)
+	OTELQueueDir string
 }
 
 // NewConfig はデフォルト設定を返す
 func NewConfig() *Config {
 	return &Config{
-		ServiceName:    "article-api",
-		ServiceVersion: "1.0.0",
-		Environment:    "development",
+		ServiceName:            "article-api",
+		ServiceVersion:         "1.0.0",
+		Environment:            "development",
+		LocalCollectorEndpoint: os.Getenv("LOCAL_COLLECTOR_ENDPOINT"),
+		ErrorSpansFile:         os.Getenv("ERROR_SPANS_FILE"),
+		OTELQueueDir:           os.Getenv("OTEL_QUEUE_DIR"),
 	}
 }
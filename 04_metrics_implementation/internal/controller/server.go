@@ -6,19 +6,26 @@ import (
 	"net/http"
 
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/handler"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
 // Server はHTTPサーバー
 type Server struct {
 	articleHandler *handler.ArticleHandler
+	metricsHandler http.Handler
+	telemetry      otel.Telemetry
 	server         *http.Server
 }
 
 // NewServer は Server を生成
-func NewServer(articleHandler *handler.ArticleHandler) *Server {
+//
+// metricsHandler が nil でない場合は GET /metrics で公開する (Prometheus の scrape 用)
+func NewServer(articleHandler *handler.ArticleHandler, metricsHandler http.Handler, tel otel.Telemetry) *Server {
 	return &Server{
 		articleHandler: articleHandler,
+		metricsHandler: metricsHandler,
+		telemetry:      tel,
 	}
 }
 
@@ -31,14 +38,27 @@ func (s *Server) Run(ctx context.Context, addr string) error {
 	mux.HandleFunc("POST /articles", s.articleHandler.CreateArticle)
 
 	// otelhttp でラップ (自動計装)
-	otelHandler := otelhttp.NewHandler(mux, "http-server")
+	// NOTE: グローバルではなく注入された Provider でスパン・メトリクスを記録する
+	var otelHandler http.Handler = otelhttp.NewHandler(mux, "http-server",
+		otelhttp.WithTracerProvider(s.telemetry.TracerProvider),
+		otelhttp.WithMeterProvider(s.telemetry.MeterProvider),
+		otelhttp.WithPropagators(s.telemetry.Propagators), // NOTE: otel.Config.Propagators の形式で上流のトレースコンテキストを読み取る
+	)
+
+	// NOTE: /metrics は otelhttp の外側に登録し、Prometheus の scrape ごとにスパンが生成されないようにする
+	if s.metricsHandler != nil {
+		root := http.NewServeMux()
+		root.Handle("GET /metrics", s.metricsHandler)
+		root.Handle("/", otelHandler)
+		otelHandler = root
+	}
 
 	s.server = &http.Server{
 		Addr:    addr,
 		Handler: otelHandler,
 	}
 
-	slog.InfoContext(ctx, "server starting", slog.String("addr", addr))
+	s.telemetry.Log().InfoContext(ctx, "server starting", slog.String("addr", addr))
 	return s.server.ListenAndServe()
 }
 
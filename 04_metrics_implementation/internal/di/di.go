@@ -1,10 +1,14 @@
 package di
 
 import (
+	"fmt"
+	"net/http"
+
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/controller"
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/handler"
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/repository"
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/usecase"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
 )
 
 // Container は依存関係を保持するコンテナ
@@ -13,20 +17,26 @@ type Container struct {
 }
 
 // NewContainer は依存関係を初期化して Container を返す
-func NewContainer() *Container {
+//
+// tel の Provider と Logger を各層のコンストラクタに渡し、計装はグローバルではなく tel を通して行う。
+// metricsHandler が nil でない場合は API サーバーの GET /metrics で公開する
+func NewContainer(tel otel.Telemetry, metricsHandler http.Handler) (*Container, error) {
 	// Repository
-	repo := repository.NewArticleRepository()
+	repo := repository.NewArticleRepository(tel)
 
 	// Usecase
-	uc := usecase.NewArticleUsecase(repo)
+	uc, err := usecase.NewArticleUsecase(repo, tel)
+	if err != nil {
+		return nil, fmt.Errorf("new article usecase: %w", err)
+	}
 
 	// Handler
-	h := handler.NewArticleHandler(uc)
+	h := handler.NewArticleHandler(uc, tel)
 
 	// Controller
-	srv := controller.NewServer(h)
+	srv := controller.NewServer(h, metricsHandler, tel)
 
 	return &Container{
 		Server: srv,
-	}
+	}, nil
 }
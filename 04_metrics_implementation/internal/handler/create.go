@@ -15,7 +15,7 @@ func (h *ArticleHandler) CreateArticle(w http.ResponseWriter, r *http.Request) {
 
 	var input usecase.CreateArticleInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-		slog.ErrorContext(ctx, "failed to decode request body",
+		h.logger.ErrorContext(ctx, "failed to decode request body",
 			slog.String("error", err.Error()),
 		)
 		http.Error(w, "invalid request body", http.StatusBadRequest)
@@ -24,14 +24,14 @@ func (h *ArticleHandler) CreateArticle(w http.ResponseWriter, r *http.Request) {
 
 	article, err := h.usecase.Create(ctx, &input)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to create article",
+		h.logger.ErrorContext(ctx, "failed to create article",
 			slog.String("error", err.Error()),
 		)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	slog.InfoContext(ctx, "article created",
+	h.logger.InfoContext(ctx, "article created",
 		slog.String("id", article.ID),
 		slog.String("title", article.Title),
 		slog.String("status", article.Status),
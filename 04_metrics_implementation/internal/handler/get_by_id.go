@@ -14,7 +14,7 @@ func (h *ArticleHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 
 	article, err := h.usecase.GetByID(ctx, id)
 	if err != nil {
-		slog.ErrorContext(ctx, "failed to get article",
+		h.logger.ErrorContext(ctx, "failed to get article",
 			slog.String("id", id),
 			slog.String("error", err.Error()),
 		)
@@ -22,7 +22,7 @@ func (h *ArticleHandler) GetArticle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	slog.InfoContext(ctx, "article retrieved",
+	h.logger.InfoContext(ctx, "article retrieved",
 		slog.String("id", article.ID),
 		slog.String("title", article.Title),
 		slog.String("status", article.Status),
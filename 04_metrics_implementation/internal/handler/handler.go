@@ -1,15 +1,24 @@
 package handler
 
 import (
+	"log/slog"
+
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/usecase"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
 )
 
 // ArticleHandler は記事ハンドラ
 type ArticleHandler struct {
 	usecase usecase.ArticleUsecase
+	logger  *slog.Logger
 }
 
 // NewArticleHandler は ArticleHandler を生成
-func NewArticleHandler(uc usecase.ArticleUsecase) *ArticleHandler {
-	return &ArticleHandler{usecase: uc}
+//
+// ログは slog.Default() ではなく tel.Logger に出力する (ctx の trace_id / span_id を付与するため OTELHandler を使用した Logger を渡す)
+func NewArticleHandler(uc usecase.ArticleUsecase, tel otel.Telemetry) *ArticleHandler {
+	return &ArticleHandler{
+		usecase: uc,
+		logger:  tel.Log(),
+	}
 }
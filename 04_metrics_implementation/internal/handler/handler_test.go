@@ -0,0 +1,80 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/entity"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/handler"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/usecase"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel/oteltest"
+
+	apperrors "github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/errors"
+)
+
+// stubUsecase は結果を固定した usecase.ArticleUsecase
+type stubUsecase struct {
+	article *entity.Article
+	err     error
+}
+
+func (u *stubUsecase) GetByID(context.Context, string) (*entity.Article, error) {
+	return u.article, u.err
+}
+
+func (u *stubUsecase) Create(context.Context, *usecase.CreateArticleInput) (*entity.Article, error) {
+	return u.article, u.err
+}
+
+// serve はサーバースパン (本番では otelhttp が開始する) の中でハンドラを呼び出す
+func serve(p *oteltest.Provider, h http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+	ctx, span := p.TracerProvider.Tracer("test").Start(req.Context(), "server")
+	defer span.End()
+	rec := httptest.NewRecorder()
+	h(rec, req.WithContext(ctx))
+	return rec
+}
+
+func TestCreateArticleLogs(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		usecase  *stubUsecase
+		wantCode int
+		wantLog  string
+	}{
+		{name: "created", body: `{"Title":"title"}`, usecase: &stubUsecase{article: &entity.Article{ID: "new-article-456", Title: "title", Status: "published"}}, wantCode: http.StatusCreated, wantLog: "article created"},
+		{name: "invalid body", body: `{`, usecase: &stubUsecase{}, wantCode: http.StatusBadRequest, wantLog: "failed to decode request body"},
+		{name: "usecase error", body: `{}`, usecase: &stubUsecase{err: apperrors.ErrValidation}, wantCode: http.StatusInternalServerError, wantLog: "failed to create article"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := oteltest.NewProvider(t)
+			h := handler.NewArticleHandler(tt.usecase, p.Telemetry())
+
+			rec := serve(p, h.CreateArticle, httptest.NewRequest(http.MethodPost, "/articles", strings.NewReader(tt.body)))
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			p.AssertLogHasTraceID(t, tt.wantLog, "server")
+		})
+	}
+}
+
+func TestGetArticleLogs(t *testing.T) {
+	p := oteltest.NewProvider(t)
+	h := handler.NewArticleHandler(&stubUsecase{err: apperrors.ErrNotFound}, p.Telemetry())
+
+	req := httptest.NewRequest(http.MethodGet, "/articles/missing", nil)
+	req.SetPathValue("id", "missing")
+	if rec := serve(p, h.GetArticle, req); rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	p.AssertLogHasTraceID(t, "failed to get article", "server")
+	if logs := p.Logs(t, "failed to get article"); logs[0]["id"] != "missing" {
+		t.Errorf("log id = %v, want missing", logs[0]["id"])
+	}
+}
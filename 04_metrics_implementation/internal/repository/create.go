@@ -15,7 +15,7 @@ var ErrDBConnection = errors.New("database connection error")
 
 // Create は記事を作成する
 func (r *articleRepository) Create(ctx context.Context, article *entity.Article) (*entity.Article, error) {
-	_, span := tracer.Start(ctx, "ArticleRepository.Create",
+	_, span := r.tracer.Start(ctx, "ArticleRepository.Create",
 		trace.WithSpanKind(trace.SpanKindClient),
 		trace.WithAttributes(
 			attribute.String("db.system", "postgresql"),
@@ -34,7 +34,7 @@ func (r *articleRepository) Create(ctx context.Context, article *entity.Article)
 	article.Status = "published"
 
 	// 公開記事数をインクリメント (本番では DB 側で管理されるため不要)
-	publishedCount.Add(1)
+	r.publishedCount.Add(1)
 
 	span.SetAttributes(attribute.String("article.id", article.ID))
 
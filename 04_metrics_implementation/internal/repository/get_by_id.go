@@ -13,7 +13,7 @@ import (
 func (r *articleRepository) FindByID(ctx context.Context, id string) (*entity.Article, error) {
 	// 子スパンを作成
 	// SpanKindClient: 外部サービス (DB等) への呼び出しを表す
-	_, span := tracer.Start(ctx, "ArticleRepository.FindByID",
+	_, span := r.tracer.Start(ctx, "ArticleRepository.FindByID",
 		trace.WithSpanKind(trace.SpanKindClient),
 		trace.WithAttributes(
 			attribute.String("db.system", "postgresql"),
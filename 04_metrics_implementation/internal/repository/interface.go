@@ -2,8 +2,11 @@ package repository
 
 import (
 	"context"
+	"sync/atomic"
 
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/entity"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ArticleRepository は記事リポジトリのインターフェース
@@ -16,9 +19,17 @@ type ArticleRepository interface {
 }
 
 // articleRepository は ArticleRepository の実装
-type articleRepository struct{}
+type articleRepository struct {
+	tracer trace.Tracer
+
+	// publishedCount は公開中の記事数 (本番では DB の COUNT クエリで取得する値をインメモリで代用)
+	// NOTE: インスタンスごとに保持し、テストで複数のリポジトリを生成しても値が共有されないようにする
+	publishedCount atomic.Int64
+}
 
 // NewArticleRepository は ArticleRepository を生成
-func NewArticleRepository() ArticleRepository {
-	return &articleRepository{}
+func NewArticleRepository(tel otel.Telemetry) ArticleRepository {
+	return &articleRepository{
+		tracer: tel.Tracer("repository/article"),
+	}
 }
@@ -0,0 +1,81 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/entity"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/repository"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel/oteltest"
+)
+
+// NOTE: リポジトリは DB エラー・未検出を確率で模擬するため、複数回呼び出して全ての結果のスパンを検証する
+const attempts = 100
+
+func TestCreateSpans(t *testing.T) {
+	p := oteltest.NewProvider(t)
+	repo := repository.NewArticleRepository(p.Telemetry())
+
+	ctx, parent := p.TracerProvider.Tracer("test").Start(context.Background(), "parent")
+	var created, failed int64
+	for range attempts {
+		_, err := repo.Create(ctx, &entity.Article{Title: "title"})
+		switch {
+		case err == nil:
+			created++
+		case errors.Is(err, repository.ErrDBConnection):
+			failed++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	parent.End()
+
+	spans := p.SpansNamed("ArticleRepository.Create")
+	if len(spans) != attempts {
+		t.Fatalf("got %d spans, want %d", len(spans), attempts)
+	}
+	for _, s := range spans {
+		if s.SpanKind() != trace.SpanKindClient {
+			t.Errorf("span kind = %s, want client", s.SpanKind())
+		}
+	}
+	p.AssertSpanParent(t, "ArticleRepository.Create", "parent")
+	p.AssertSpanAttribute(t, "ArticleRepository.Create", attribute.String("db.system", "postgresql"))
+	p.AssertSpanAttribute(t, "ArticleRepository.Create", attribute.String("db.operation", "INSERT"))
+	if created > 0 {
+		p.AssertSpanAttribute(t, "ArticleRepository.Create", attribute.String("article.id", "new-article-456"))
+	}
+
+	// NOTE: 公開記事数は article.active.count (usecase の Observable Gauge) で観測される
+	if got := repo.GetPublishedCount(context.Background()); got != created {
+		t.Errorf("GetPublishedCount = %d, want %d created (%d failed)", got, created, failed)
+	}
+}
+
+func TestFindByIDSpans(t *testing.T) {
+	p := oteltest.NewProvider(t)
+	repo := repository.NewArticleRepository(p.Telemetry())
+
+	ctx, parent := p.TracerProvider.Tracer("test").Start(context.Background(), "parent")
+	for range attempts {
+		if _, err := repo.FindByID(ctx, "article-123"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parent.End()
+
+	if got := len(p.SpansNamed("ArticleRepository.FindByID")); got != attempts {
+		t.Fatalf("got %d spans, want %d", got, attempts)
+	}
+	p.AssertSpanParent(t, "ArticleRepository.FindByID", "parent")
+	p.AssertSpanAttribute(t, "ArticleRepository.FindByID", attribute.String("db.operation", "SELECT"))
+	p.AssertSpanAttribute(t, "ArticleRepository.FindByID", attribute.String("article.id", "article-123"))
+	if got := p.Span(t, "ArticleRepository.FindByID").SpanKind(); got != trace.SpanKindClient {
+		t.Errorf("span kind = %s, want client", got)
+	}
+}
@@ -38,7 +38,7 @@ func (u *articleUsecase) Create(ctx context.Context, input *CreateArticleInput)
 
 	startTime := time.Now()
 
-	ctx, span := tracer.Start(ctx, "ArticleUsecase.Create")
+	ctx, span := u.tracer.Start(ctx, "ArticleUsecase.Create")
 	defer span.End()
 
 	// イベント: バリデーション開始
@@ -49,7 +49,7 @@ func (u *articleUsecase) Create(ctx context.Context, input *CreateArticleInput)
 		span.SetStatus(codes.Error, "validation failed")
 
 		// NOTE: Histogram 記録: バリデーションエラー時の処理時間
-		articleCreateDuration.Record(ctx, time.Since(startTime).Seconds(),
+		u.articleCreateDuration.Record(ctx, time.Since(startTime).Seconds(),
 			metric.WithAttributes(attribute.String("status", "validation_error")),
 		)
 		return nil, err
@@ -71,7 +71,7 @@ func (u *articleUsecase) Create(ctx context.Context, input *CreateArticleInput)
 		span.SetStatus(codes.Error, err.Error())
 
 		// NOTE: Histogram 記録: DBエラー時の処理時間
-		articleCreateDuration.Record(ctx, time.Since(startTime).Seconds(),
+		u.articleCreateDuration.Record(ctx, time.Since(startTime).Seconds(),
 			metric.WithAttributes(attribute.String("status", "error")),
 		)
 		return nil, err
@@ -83,7 +83,7 @@ func (u *articleUsecase) Create(ctx context.Context, input *CreateArticleInput)
 	))
 
 	// NOTE: Histogram 記録: 成功時の処理時間
-	articleCreateDuration.Record(ctx, time.Since(startTime).Seconds(),
+	u.articleCreateDuration.Record(ctx, time.Since(startTime).Seconds(),
 		metric.WithAttributes(attribute.String("status", "success")),
 	)
 
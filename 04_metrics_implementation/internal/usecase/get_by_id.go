@@ -16,7 +16,7 @@ import (
 func (u *articleUsecase) GetByID(ctx context.Context, id string) (*entity.Article, error) {
 	// スパンの開始
 	// SpanKindInternal: 内部処理を表す (デフォルト)
-	ctx, span := tracer.Start(ctx, "ArticleUsecase.GetByID",
+	ctx, span := u.tracer.Start(ctx, "ArticleUsecase.GetByID",
 		trace.WithSpanKind(trace.SpanKindInternal),
 	)
 	defer span.End()
@@ -54,14 +54,16 @@ func (u *articleUsecase) GetByID(ctx context.Context, id string) (*entity.Articl
 	//
 	// 本サンプルでは学習目的で article_id を使用しているが、本番では以下のように低カーディナリティ属性 (status, category 等) のみを使い、
 	// article_id のような高カーディナリティ属性はトレース (span attribute) で記録する。
-	articleViewsCounter.Add(ctx, 1,
+	// なお cmd/main.go では otel.Config.MetricViews で article_id をエクスポート前に除外している。
+	// また otel.Config.MetricCardinalityLimit (デフォルト2000) により、上限を超えた組み合わせは otel.metric.overflow=true の時系列にまとめられる。
+	u.articleViewsCounter.Add(ctx, 1,
 		metric.WithAttributes(
 			attribute.String("article_id", article.ID),
 			attribute.String("category_id", "general"),
 		),
 	)
 	// 本番例:
-	//   articleViewsCounter.Add(ctx, 1,
+	//   u.articleViewsCounter.Add(ctx, 1,
 	//       metric.WithAttributes(
 	//           attribute.String("status", "published"),  // 数パターンに限定
 	//           attribute.String("category", "tech"),      // 数十パターン程度
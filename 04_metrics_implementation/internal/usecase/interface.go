@@ -2,10 +2,13 @@ package usecase
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/entity"
 	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/repository"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
 	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // ArticleUsecase は記事ユースケースのインターフェース
@@ -17,16 +20,25 @@ type ArticleUsecase interface {
 // articleUsecase は ArticleUsecase の実装
 type articleUsecase struct {
 	repo repository.ArticleRepository
+
+	tracer                trace.Tracer
+	articleViewsCounter   metric.Int64Counter
+	articleCreateDuration metric.Float64Histogram
 }
 
 // NewArticleUsecase は ArticleUsecase を生成し、メトリクス計器を初期化する。
 //
-// meter.Int64Counter() 等は (instrument, error) の2値を返すため、パッケージレベルの var 宣言では直接受け取れない。
-// otel.Tracer() / otel.Meter() はエラーを返さない1値なので var で宣言できる。
+// Tracer / Meter はグローバルの otel.Tracer() / otel.Meter() ではなく、引数の tel から取得する。
+// 計器はインスタンスごとに保持するため、テストでは Provider の異なる複数の ArticleUsecase を独立して生成できる。
 //
-// なお otel.Meter() は SetMeterProvider() より前に呼んでも安全。
-// 取得した Meter はグローバル委譲パターンにより、後から設定された実際の MeterProvider に自動的に委譲される。(otel.Tracer() と同じ仕組み)
-func NewArticleUsecase(repo repository.ArticleRepository) ArticleUsecase {
+// meter.Int64Counter() 等は (instrument, error) の2値を返すため、計器の作成に失敗した場合はエラーを返す。
+func NewArticleUsecase(repo repository.ArticleRepository, tel otel.Telemetry) (ArticleUsecase, error) {
+	u := &articleUsecase{
+		repo:   repo,
+		tracer: tel.Tracer("usecase/article"),
+	}
+	meter := tel.Meter("usecase/article")
+
 	var err error
 
 	// Counter: 記事閲覧数の累計
@@ -40,12 +52,13 @@ func NewArticleUsecase(repo repository.ArticleRepository) ArticleUsecase {
 	// エクスポート時には CumulativeTemporality の場合「起動時からの累計値」が送信される。
 	// 例: 10秒間に5回閲覧 → Value: 5、さらに3回閲覧 → Value: 8 (差分ではなく累計)
 	// Prometheus 等のバックエンドでは rate() 関数で「毎秒の増加率」に変換してグラフ化する。
-	articleViewsCounter, err = meter.Int64Counter(
+	u.articleViewsCounter, err = meter.Int64Counter(
 		"article.views.total",
 		metric.WithDescription("記事閲覧数の累計"),
+		metric.WithUnit("{view}"), // NOTE: UCUM 形式の単位。{} で囲んだ注釈は Prometheus 変換時に名前へ付与されない
 	)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("create article.views.total counter: %w", err)
 	}
 
 	// Histogram: 記事作成の処理時間 (秒)
@@ -65,13 +78,17 @@ func NewArticleUsecase(repo repository.ArticleRepository) ArticleUsecase {
 	//   - SLO/SLA の閾値を含める (例: 目標レイテンシが1秒なら 1 を含める)
 	//   - 境界を細かくしすぎるとデータポイント数が増えるため、5〜10個程度が目安
 	//   - 未指定の場合は SDK デフォルト境界が使われるが、アプリ特性に合わせて明示指定を推奨
-	articleCreateDuration, err = meter.Float64Histogram(
+	//
+	// NOTE: 境界の設計が難しい場合は otel.Config.HistogramAggregation (または OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION) に
+	// base2_exponential_bucket_histogram を指定すると、ここで指定した境界の代わりに値の範囲に応じて自動で調整される指数ヒストグラムで集約される。
+	u.articleCreateDuration, err = meter.Float64Histogram(
 		"article.create.duration",
 		metric.WithDescription("記事作成の処理時間 (秒)"),
+		metric.WithUnit("s"),                                   // NOTE: Prometheus 変換時に article_create_duration_seconds となる
 		metric.WithExplicitBucketBoundaries(0.1, 0.5, 1, 2, 5), // NOTE: 6つのバケットに分布を記録
 	)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("create article.create.duration histogram: %w", err)
 	}
 
 	// Observable Gauge: 公開中の記事数
@@ -88,14 +105,15 @@ func NewArticleUsecase(repo repository.ArticleRepository) ArticleUsecase {
 	_, err = meter.Int64ObservableGauge(
 		"article.active.count",
 		metric.WithDescription("公開中の記事数"),
+		metric.WithUnit("{article}"),
 		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
 			o.Observe(repo.GetPublishedCount(ctx)) // NOTE: インターフェース経由で現在の公開記事数を観測
 			return nil
 		}),
 	)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("create article.active.count gauge: %w", err)
 	}
 
-	return &articleUsecase{repo: repo}
+	return u, nil
 }
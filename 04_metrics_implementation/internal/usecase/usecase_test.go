@@ -0,0 +1,147 @@
+package usecase_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/entity"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/internal/usecase"
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel/oteltest"
+
+	apperrors "github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/errors"
+)
+
+// stubRepository は結果を固定した repository.ArticleRepository
+type stubRepository struct {
+	article   *entity.Article
+	err       error
+	published int64
+}
+
+func (r *stubRepository) FindByID(context.Context, string) (*entity.Article, error) {
+	return r.article, r.err
+}
+
+func (r *stubRepository) Create(_ context.Context, a *entity.Article) (*entity.Article, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	a.ID, a.Status = "new-article-456", "published"
+	return a, nil
+}
+
+func (r *stubRepository) GetPublishedCount(context.Context) int64 {
+	return r.published
+}
+
+// newUsecase は repo と oteltest.Provider を使用する ArticleUsecase を生成する
+func newUsecase(t *testing.T, repo *stubRepository) (usecase.ArticleUsecase, *oteltest.Provider) {
+	t.Helper()
+	p := oteltest.NewProvider(t)
+	uc, err := usecase.NewArticleUsecase(repo, p.Telemetry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uc, p
+}
+
+func TestCreateTelemetry(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      usecase.CreateArticleInput
+		repoErr    error
+		wantStatus codes.Code
+		wantEvent  string
+		wantMetric string
+	}{
+		{name: "success", input: usecase.CreateArticleInput{Title: "title"}, wantStatus: codes.Unset, wantEvent: "create_completed", wantMetric: "success"},
+		{name: "validation error", input: usecase.CreateArticleInput{}, wantStatus: codes.Error, wantEvent: "validation_started", wantMetric: "validation_error"},
+		{name: "repository error", input: usecase.CreateArticleInput{Title: "title"}, repoErr: errors.New("database connection error"), wantStatus: codes.Error, wantEvent: "create_started", wantMetric: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc, p := newUsecase(t, &stubRepository{err: tt.repoErr})
+
+			_, err := uc.Create(context.Background(), &tt.input)
+			if (err != nil) != (tt.wantStatus == codes.Error) {
+				t.Fatalf("Create error = %v", err)
+			}
+
+			p.AssertSpanStatus(t, "ArticleUsecase.Create", tt.wantStatus)
+			p.AssertSpanHasEvent(t, "ArticleUsecase.Create", tt.wantEvent)
+			p.AssertHistogramPoint(t, "article.create.duration", attribute.String("status", tt.wantMetric))
+			if got := p.Value(t, "article.create.duration", attribute.String("status", tt.wantMetric)); got != 1 {
+				t.Errorf("article.create.duration count = %v, want 1", got)
+			}
+		})
+	}
+}
+
+func TestCreateRecordsArticleIDEvent(t *testing.T) {
+	uc, p := newUsecase(t, &stubRepository{})
+
+	if _, err := uc.Create(context.Background(), &usecase.CreateArticleInput{Title: "title"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range p.Span(t, "ArticleUsecase.Create").Events() {
+		if e.Name != "create_completed" {
+			continue
+		}
+		for _, kv := range e.Attributes {
+			if kv == attribute.String("article.id", "new-article-456") {
+				return
+			}
+		}
+		t.Errorf("create_completed attributes = %v, want article.id", e.Attributes)
+		return
+	}
+	t.Error("create_completed event not found")
+}
+
+func TestGetByIDTelemetry(t *testing.T) {
+	t.Run("found", func(t *testing.T) {
+		uc, p := newUsecase(t, &stubRepository{article: &entity.Article{ID: "article-123", Title: "title", Status: "published"}})
+
+		if _, err := uc.GetByID(context.Background(), "article-123"); err != nil {
+			t.Fatal(err)
+		}
+		p.AssertSpanStatus(t, "ArticleUsecase.GetByID", codes.Unset)
+		p.AssertSpanAttribute(t, "ArticleUsecase.GetByID", attribute.String("article.id", "article-123"))
+		p.AssertSpanAttribute(t, "ArticleUsecase.GetByID", attribute.String("article.status", "published"))
+		if got := p.Value(t, "article.views.total", attribute.String("article_id", "article-123"), attribute.String("category_id", "general")); got != 1 {
+			t.Errorf("article.views.total = %v, want 1", got)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		uc, p := newUsecase(t, &stubRepository{})
+
+		if _, err := uc.GetByID(context.Background(), "missing"); !errors.Is(err, apperrors.ErrNotFound) {
+			t.Fatalf("GetByID error = %v, want ErrNotFound", err)
+		}
+		p.AssertSpanStatus(t, "ArticleUsecase.GetByID", codes.Error)
+		if got := len(p.Span(t, "ArticleUsecase.GetByID").Events()); got != 0 {
+			t.Errorf("got %d events, want no recorded error for a missing article", got)
+		}
+		// NOTE: 閲覧数は記事が見つかった場合のみ記録する
+		for _, sm := range p.Collect(t).ScopeMetrics {
+			for _, m := range sm.Metrics {
+				if m.Name == "article.views.total" {
+					t.Error("article.views.total recorded for a missing article")
+				}
+			}
+		}
+	})
+}
+
+func TestActiveCountGauge(t *testing.T) {
+	_, p := newUsecase(t, &stubRepository{published: 3})
+
+	if got := p.Value(t, "article.active.count"); got != 3 {
+		t.Errorf("article.active.count = %v, want 3", got)
+	}
+}
@@ -0,0 +1,329 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// BaggageConfig は Baggage のメンバーをテレメトリの属性としてコピーする許可リスト
+//
+// Baggage はリクエストの上流 (API ゲートウェイや呼び出し元のサービス) が baggage ヘッダーで伝搬する key=value で、
+// 伝搬されるだけではバックエンドに記録されない。許可リストに含まれるメンバーのみを同じキーの属性としてコピーすることで、
+// 「どのテナント / クライアントアプリからのリクエストか」でトレース・ログ・メトリクスを絞り込めるようにする。
+//
+// 例: baggage: tenant.id=acme,client.app=ios,session.id=... の場合
+//
+//	BaggageConfig{
+//	    Keys:       []string{"tenant.id", "client.app"}, // スパン (と WithBaggage を指定したログ) に付与する
+//	    MetricKeys: []string{"client.app"},              // 値の種類が少ないため、メトリクスにも付与する
+//	}
+//
+// NOTE: Baggage はクライアントが自由に設定できるため、値を信頼した判定 (認可等) には使用しない。
+type BaggageConfig struct {
+	// Keys はリクエスト内で開始される全てのスパンに属性としてコピーするメンバー
+	Keys []string
+
+	// MetricKeys は同期の計器 (Counter / Histogram 等) の記録に属性としてコピーするメンバー。
+	// NOTE: 属性の組み合わせごとに時系列が増えるため、値の種類が有限 (数十程度まで) のメンバーのみを指定する。
+	// Provider.Telemetry が返す MeterProvider 経由で作成した計器のみに適用される
+	MetricKeys []string
+}
+
+// validate はメンバーのキーが空でないことを検証する
+func (c BaggageConfig) validate() error {
+	for _, keys := range [][]string{c.Keys, c.MetricKeys} {
+		for _, k := range keys {
+			if k == "" {
+				return fmt.Errorf("otel: baggage keys must not be empty")
+			}
+		}
+	}
+	return nil
+}
+
+// baggageAttributes は ctx の Baggage のうち keys に含まれるメンバーを属性として返す (Baggage の値が空のメンバーは含まない)
+func baggageAttributes(ctx context.Context, keys []string) []attribute.KeyValue {
+	b := baggage.FromContext(ctx)
+	if b.Len() == 0 {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	for _, k := range keys {
+		if v := b.Member(k).Value(); v != "" {
+			attrs = append(attrs, attribute.String(k, v))
+		}
+	}
+	return attrs
+}
+
+// =======================================================
+// スパン
+// =======================================================
+
+// BaggageSpanProcessor はスパンの開始時に ctx の Baggage のうち許可リストに含まれるメンバーを属性としてコピーする sdktrace.SpanProcessor
+//
+// NOTE: 親スパンの属性は子スパンに引き継がれないが、Baggage は ctx で伝搬されるため、リクエスト内の全てのスパンに同じ属性が付与される。
+type BaggageSpanProcessor struct {
+	keys []string
+}
+
+var _ sdktrace.SpanProcessor = (*BaggageSpanProcessor)(nil)
+
+// NewBaggageSpanProcessor は keys のメンバーをコピーする BaggageSpanProcessor を生成する
+func NewBaggageSpanProcessor(keys []string) *BaggageSpanProcessor {
+	return &BaggageSpanProcessor{keys: keys}
+}
+
+// OnStart は ctx の Baggage のメンバーをスパンの属性に追加する
+func (p *BaggageSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	if attrs := baggageAttributes(ctx, p.keys); len(attrs) > 0 {
+		s.SetAttributes(attrs...)
+	}
+}
+
+// OnEnd は何もしない
+func (p *BaggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+// Shutdown は何もしない
+func (p *BaggageSpanProcessor) Shutdown(context.Context) error { return nil }
+
+// ForceFlush は何もしない
+func (p *BaggageSpanProcessor) ForceFlush(context.Context) error { return nil }
+
+// =======================================================
+// ログ
+// =======================================================
+
+// WithBaggage はログに ctx の Baggage のうち keys に含まれるメンバーを属性として追加する
+//
+// 通常は Config.Baggage.Keys を渡し、スパンと同じメンバーをログにも付与する。
+func WithBaggage(keys ...string) HandlerOption {
+	return func(h *OTELHandler) {
+		h.baggageKeys = keys
+	}
+}
+
+// baggageLogAttrs は ctx の Baggage のうち keys に含まれるメンバーを slog の属性として返す
+func baggageLogAttrs(ctx context.Context, keys []string) []slog.Attr {
+	kvs := baggageAttributes(ctx, keys)
+	if len(kvs) == 0 {
+		return nil
+	}
+	attrs := make([]slog.Attr, 0, len(kvs))
+	for _, kv := range kvs {
+		attrs = append(attrs, slog.String(string(kv.Key), kv.Value.AsString()))
+	}
+	return attrs
+}
+
+// =======================================================
+// メトリクス
+// =======================================================
+
+// NOTE: メトリクスの SDK には記録時に属性を追加する拡張ポイントが無いため、MeterProvider をラップし、
+// 同期の計器の Add / Record で ctx の Baggage のメンバーを属性に追加する。
+// 計装コードで同じキーの属性を指定した場合は計装コードの値が優先される。
+// 非同期の計器 (Observable) はリクエストの ctx を持たないため対象外。
+
+// baggageMeterProvider は作成した計器の記録に Baggage のメンバーを属性として追加する metric.MeterProvider
+type baggageMeterProvider struct {
+	metric.MeterProvider
+	keys []string
+}
+
+// newBaggageMeterProvider は mp をラップした baggageMeterProvider を返す。keys が空の場合は mp をそのまま返す
+func newBaggageMeterProvider(mp metric.MeterProvider, keys []string) metric.MeterProvider {
+	if len(keys) == 0 {
+		return mp
+	}
+	return &baggageMeterProvider{MeterProvider: mp, keys: keys}
+}
+
+// Meter は同期の計器をラップする Meter を返す
+func (p *baggageMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	return &baggageMeter{Meter: p.MeterProvider.Meter(name, opts...), keys: p.keys}
+}
+
+// baggageMeter は同期の計器をラップする metric.Meter
+type baggageMeter struct {
+	metric.Meter
+	keys []string
+}
+
+// measurementOption は ctx の Baggage のメンバーを属性とする MeasurementOption を返す (メンバーが無い場合は nil)
+func (m *baggageMeter) measurementOption(ctx context.Context) metric.MeasurementOption {
+	attrs := baggageAttributes(ctx, m.keys)
+	if len(attrs) == 0 {
+		return nil
+	}
+	return metric.WithAttributes(attrs...)
+}
+
+// addOptions は opts の先頭に Baggage の属性を追加する (計装コードの属性を後に適用し、同じキーの場合に優先させる)
+func (m *baggageMeter) addOptions(ctx context.Context, opts []metric.AddOption) []metric.AddOption {
+	opt := m.measurementOption(ctx)
+	if opt == nil {
+		return opts
+	}
+	return append([]metric.AddOption{opt}, opts...)
+}
+
+// recordOptions は opts の先頭に Baggage の属性を追加する
+func (m *baggageMeter) recordOptions(ctx context.Context, opts []metric.RecordOption) []metric.RecordOption {
+	opt := m.measurementOption(ctx)
+	if opt == nil {
+		return opts
+	}
+	return append([]metric.RecordOption{opt}, opts...)
+}
+
+// Int64Counter は Baggage の属性を追加する Int64Counter を返す
+func (m *baggageMeter) Int64Counter(name string, opts ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	i, err := m.Meter.Int64Counter(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageInt64Counter{Int64Counter: i, meter: m}, nil
+}
+
+// Int64UpDownCounter は Baggage の属性を追加する Int64UpDownCounter を返す
+func (m *baggageMeter) Int64UpDownCounter(name string, opts ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	i, err := m.Meter.Int64UpDownCounter(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageInt64UpDownCounter{Int64UpDownCounter: i, meter: m}, nil
+}
+
+// Int64Histogram は Baggage の属性を追加する Int64Histogram を返す
+func (m *baggageMeter) Int64Histogram(name string, opts ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	i, err := m.Meter.Int64Histogram(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageInt64Histogram{Int64Histogram: i, meter: m}, nil
+}
+
+// Int64Gauge は Baggage の属性を追加する Int64Gauge を返す
+func (m *baggageMeter) Int64Gauge(name string, opts ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	i, err := m.Meter.Int64Gauge(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageInt64Gauge{Int64Gauge: i, meter: m}, nil
+}
+
+// Float64Counter は Baggage の属性を追加する Float64Counter を返す
+func (m *baggageMeter) Float64Counter(name string, opts ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	i, err := m.Meter.Float64Counter(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageFloat64Counter{Float64Counter: i, meter: m}, nil
+}
+
+// Float64UpDownCounter は Baggage の属性を追加する Float64UpDownCounter を返す
+func (m *baggageMeter) Float64UpDownCounter(name string, opts ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
+	i, err := m.Meter.Float64UpDownCounter(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageFloat64UpDownCounter{Float64UpDownCounter: i, meter: m}, nil
+}
+
+// Float64Histogram は Baggage の属性を追加する Float64Histogram を返す
+func (m *baggageMeter) Float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	i, err := m.Meter.Float64Histogram(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageFloat64Histogram{Float64Histogram: i, meter: m}, nil
+}
+
+// Float64Gauge は Baggage の属性を追加する Float64Gauge を返す
+func (m *baggageMeter) Float64Gauge(name string, opts ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
+	i, err := m.Meter.Float64Gauge(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &baggageFloat64Gauge{Float64Gauge: i, meter: m}, nil
+}
+
+type baggageInt64Counter struct {
+	metric.Int64Counter
+	meter *baggageMeter
+}
+
+func (i *baggageInt64Counter) Add(ctx context.Context, v int64, opts ...metric.AddOption) {
+	i.Int64Counter.Add(ctx, v, i.meter.addOptions(ctx, opts)...)
+}
+
+type baggageInt64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	meter *baggageMeter
+}
+
+func (i *baggageInt64UpDownCounter) Add(ctx context.Context, v int64, opts ...metric.AddOption) {
+	i.Int64UpDownCounter.Add(ctx, v, i.meter.addOptions(ctx, opts)...)
+}
+
+type baggageInt64Histogram struct {
+	metric.Int64Histogram
+	meter *baggageMeter
+}
+
+func (i *baggageInt64Histogram) Record(ctx context.Context, v int64, opts ...metric.RecordOption) {
+	i.Int64Histogram.Record(ctx, v, i.meter.recordOptions(ctx, opts)...)
+}
+
+type baggageInt64Gauge struct {
+	metric.Int64Gauge
+	meter *baggageMeter
+}
+
+func (i *baggageInt64Gauge) Record(ctx context.Context, v int64, opts ...metric.RecordOption) {
+	i.Int64Gauge.Record(ctx, v, i.meter.recordOptions(ctx, opts)...)
+}
+
+type baggageFloat64Counter struct {
+	metric.Float64Counter
+	meter *baggageMeter
+}
+
+func (i *baggageFloat64Counter) Add(ctx context.Context, v float64, opts ...metric.AddOption) {
+	i.Float64Counter.Add(ctx, v, i.meter.addOptions(ctx, opts)...)
+}
+
+type baggageFloat64UpDownCounter struct {
+	metric.Float64UpDownCounter
+	meter *baggageMeter
+}
+
+func (i *baggageFloat64UpDownCounter) Add(ctx context.Context, v float64, opts ...metric.AddOption) {
+	i.Float64UpDownCounter.Add(ctx, v, i.meter.addOptions(ctx, opts)...)
+}
+
+type baggageFloat64Histogram struct {
+	metric.Float64Histogram
+	meter *baggageMeter
+}
+
+func (i *baggageFloat64Histogram) Record(ctx context.Context, v float64, opts ...metric.RecordOption) {
+	i.Float64Histogram.Record(ctx, v, i.meter.recordOptions(ctx, opts)...)
+}
+
+type baggageFloat64Gauge struct {
+	metric.Float64Gauge
+	meter *baggageMeter
+}
+
+func (i *baggageFloat64Gauge) Record(ctx context.Context, v float64, opts ...metric.RecordOption) {
+	i.Float64Gauge.Record(ctx, v, i.meter.recordOptions(ctx, opts)...)
+}
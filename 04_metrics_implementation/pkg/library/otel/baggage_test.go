@@ -0,0 +1,141 @@
+package otel
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// baggageContext は members (key, value の順) を Baggage に持つ ctx を返す
+func baggageContext(t *testing.T, members ...string) context.Context {
+	t.Helper()
+	var ms []baggage.Member
+	for i := 0; i < len(members); i += 2 {
+		m, err := baggage.NewMember(members[i], members[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ms = append(ms, m)
+	}
+	b, err := baggage.New(ms...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return baggage.ContextWithBaggage(context.Background(), b)
+}
+
+func TestBaggageSpanProcessorCopiesAllowlistedMembers(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(NewBaggageSpanProcessor([]string{"tenant.id", "client.app", "region"})),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+	ctx := baggageContext(t, "tenant.id", "acme", "client.app", "ios", "session.id", "secret")
+	ctx, parent := tp.Tracer("test").Start(ctx, "http-server")
+	_, child := tp.Tracer("test").Start(ctx, "ArticleUsecase.GetByID")
+	child.End()
+	parent.End()
+	_, outside := tp.Tracer("test").Start(context.Background(), "no baggage")
+	outside.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(spans))
+	}
+	// NOTE: 子スパンにも ctx の Baggage から同じ属性が付与される。許可リストにないメンバー・Baggage にないキーは付与しない
+	want := attribute.NewSet(attribute.String("tenant.id", "acme"), attribute.String("client.app", "ios"))
+	for _, s := range spans[:2] {
+		if got := attribute.NewSet(s.Attributes()...); !got.Equals(&want) {
+			t.Errorf("%s attributes = %v, want %v", s.Name(), got.ToSlice(), want.ToSlice())
+		}
+	}
+	if attrs := spans[2].Attributes(); len(attrs) != 0 {
+		t.Errorf("span without baggage has attributes %v", attrs)
+	}
+}
+
+func TestOTELHandlerAddsBaggage(t *testing.T) {
+	logger, buf, recorder := newTestLogger(t, WithBaggage("tenant.id", "client.app"))
+	ctx := baggageContext(t, "tenant.id", "acme", "session.id", "secret")
+	logger.WithGroup("request").InfoContext(ctx, "article fetched", slog.String("method", "GET"))
+
+	records := recorder.get()
+	if len(records) != 1 {
+		t.Fatalf("exported %d log records, want 1", len(records))
+	}
+	attrs := recordAttrs(records[0])
+	// NOTE: Baggage の属性は WithGroup のグループ内に追加される
+	group := logMapValue(t, attrs["request"])
+	if group["tenant.id"].AsString() != "acme" {
+		t.Errorf("LogRecord attributes = %v, want tenant.id=acme", group)
+	}
+	for _, key := range []string{"client.app", "session.id"} {
+		if _, ok := group[key]; ok {
+			t.Errorf("LogRecord has %s, want only members in baggage and the allowlist", key)
+		}
+	}
+
+	line := jsonLines(t, buf)[0]
+	request, _ := line["request"].(map[string]any)
+	if request["tenant.id"] != "acme" || request["session.id"] != nil {
+		t.Errorf("JSON line = %v, want request.tenant.id=acme without session.id", line)
+	}
+}
+
+func TestBaggageMeterProvider(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	sdkmp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = sdkmp.Shutdown(context.Background()) })
+
+	if mp := newBaggageMeterProvider(sdkmp, nil); mp != metric.MeterProvider(sdkmp) {
+		t.Errorf("provider without keys = %T, want the MeterProvider as is", mp)
+	}
+
+	meter := newBaggageMeterProvider(sdkmp, []string{"client.app"}).Meter("article")
+	views, err := meter.Int64Counter("article.views.total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	active, err := meter.Int64Gauge("article.active.count")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := baggageContext(t, "client.app", "ios", "tenant.id", "acme")
+	status := attribute.Int("status", 200)
+	views.Add(ctx, 1, metric.WithAttributes(status))
+	// NOTE: 計装コードで同じキーを指定した場合は計装コードの値が優先される
+	views.Add(ctx, 2, metric.WithAttributes(status, attribute.String("client.app", "web")))
+	views.Add(context.Background(), 4, metric.WithAttributes(status))
+	active.Record(ctx, 3)
+
+	tests := []struct {
+		name       string
+		instrument string
+		attrs      []attribute.KeyValue
+		want       int64
+	}{
+		{name: "MetricKeys are copied and other members are filtered", instrument: "article.views.total", attrs: []attribute.KeyValue{status, attribute.String("client.app", "ios")}, want: 1},
+		{name: "call-site attribute wins for the same key", instrument: "article.views.total", attrs: []attribute.KeyValue{status, attribute.String("client.app", "web")}, want: 2},
+		{name: "no baggage", instrument: "article.views.total", attrs: []attribute.KeyValue{status}, want: 4},
+		{name: "tenant.id is not in MetricKeys", instrument: "article.views.total", attrs: []attribute.KeyValue{status, attribute.String("client.app", "ios"), attribute.String("tenant.id", "acme")}, want: 0},
+		{name: "record options", instrument: "article.active.count", attrs: []attribute.KeyValue{attribute.String("client.app", "ios")}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := int64Value(t, reader, tt.instrument, tt.attrs...); got != tt.want {
+				t.Errorf("%s%v = %d, want %d", tt.instrument, tt.attrs, got, tt.want)
+			}
+		})
+	}
+}
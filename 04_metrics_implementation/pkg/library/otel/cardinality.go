@@ -0,0 +1,171 @@
+package otel
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	dto "github.com/prometheus/client_model/go"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// NOTE: カーディナリティ上限
+// 計器ごとの時系列 (属性の組み合わせ) の数が上限に達すると、SDK はそれ以降の新しい組み合わせの記録を
+// otel.metric.overflow=true 属性のみを持つ1つの時系列にまとめる (既存の組み合わせへの記録はそのまま継続される)。
+// これにより、article_id のような高カーディナリティ属性を誤って記録してもメモリ使用量とバックエンドへの送信量が上限で抑えられる。
+//
+// SDK の制約により上限は MeterProvider 全体で1つの値となり、各計器にそれぞれ同じ上限が適用される。
+
+// defaultCardinalityLimit は計器ごとの時系列数の上限 (OpenTelemetry 仕様の推奨値)
+const defaultCardinalityLimit = 2000
+
+// overflowAttribute はカーディナリティ上限を超えた記録をまとめた時系列に付与される属性
+var overflowAttribute = attribute.Bool("otel.metric.overflow", true)
+
+// cardinalityLimit は Config.MetricCardinalityLimit を sdkmetric.WithCardinalityLimit に渡す値に変換する
+func cardinalityLimit(limit int) int {
+	switch {
+	case limit == 0:
+		return defaultCardinalityLimit
+	case limit < 0:
+		return 0 // NOTE: SDK では 0 以下が上限なしを表す
+	default:
+		return limit
+	}
+}
+
+// overflowDetector はカーディナリティ上限を超えた計器を検出し、計器ごとに初回のみ slog で警告する
+//
+// NOTE: 上限は Reader ごとの集約に適用されるため、Reader (パイプライン) が複数ある場合は同じ計器の超過が各 Reader で検出される。
+// Provider 全体で1つの overflowDetector を共有し、警告済みの計器を sync.Map に記録することで警告を1回にまとめる。
+type overflowDetector struct {
+	limit  int
+	warned sync.Map // NOTE: overflowKey → struct{}
+}
+
+// overflowKey は警告済みの計器を識別するキー
+type overflowKey struct {
+	scope string
+	name  string
+}
+
+// newOverflowDetector は overflowDetector を生成する
+func newOverflowDetector(limit int) *overflowDetector {
+	return &overflowDetector{limit: limit}
+}
+
+// detect は rm からカーディナリティ上限を超えた計器を検出して警告する
+func (d *overflowDetector) detect(ctx context.Context, rm *metricdata.ResourceMetrics) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if hasOverflow(m.Data) {
+				d.warn(ctx, sm.Scope.Name, m.Name)
+			}
+		}
+	}
+}
+
+// warn は計器ごとに初回のみ警告する
+func (d *overflowDetector) warn(ctx context.Context, scope, name string) {
+	if _, warned := d.warned.LoadOrStore(overflowKey{scope: scope, name: name}, struct{}{}); warned {
+		return
+	}
+	slog.WarnContext(ctx, "otel: metric cardinality limit exceeded; new attribute sets are aggregated into the overflow series",
+		slog.String("metric", name),
+		slog.String("scope", scope),
+		slog.Int("limit", d.limit),
+		slog.String("overflow_attribute", string(overflowAttribute.Key)),
+	)
+}
+
+// overflowWarningExporter は PeriodicReader (push 型) が収集したメトリクスから、カーディナリティ上限を超えた計器を検出する sdkmetric.Exporter
+type overflowWarningExporter struct {
+	sdkmetric.Exporter
+	detector *overflowDetector
+}
+
+// Export はカーディナリティ上限を超えた計器を警告してからエクスポートする
+func (e *overflowWarningExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	e.detector.detect(ctx, rm)
+	return e.Exporter.Export(ctx, rm)
+}
+
+// overflowWarningGatherer は Prometheus Reader (pull 型) が scrape 時に収集したメトリクスから、カーディナリティ上限を超えた計器を検出する prometheus.Gatherer
+//
+// NOTE: Prometheus のメトリクス名は OTel の計器名から変換されているため (prometheus.go 参照)、
+// PeriodicReader と併用する場合は警告が計器名の違いで重複しないよう、PeriodicReader 側のみで検出する。
+type overflowWarningGatherer struct {
+	prometheus.Gatherer
+	detector *overflowDetector
+}
+
+// Gather はカーディナリティ上限を超えた計器を警告してから、収集したメトリクスを返す
+func (g *overflowWarningGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			if scope, ok := prometheusOverflowScope(m); ok {
+				g.detector.warn(context.Background(), scope, mf.GetName())
+				break
+			}
+		}
+	}
+	return families, err
+}
+
+// prometheusOverflowScope は m が otel.metric.overflow=true のラベルを持つ場合に計装スコープ名を返す
+func prometheusOverflowScope(m *dto.Metric) (string, bool) {
+	var scope string
+	var overflow bool
+	for _, l := range m.GetLabel() {
+		switch l.GetName() {
+		case prometheusOverflowLabel:
+			overflow = l.GetValue() == "true"
+		case "otel_scope_name":
+			scope = l.GetValue()
+		}
+	}
+	return scope, overflow
+}
+
+// prometheusOverflowLabel は Prometheus の命名規則に変換された otel.metric.overflow 属性のラベル名
+const prometheusOverflowLabel = "otel_metric_overflow"
+
+// hasOverflow は data に otel.metric.overflow=true 属性の時系列が含まれるかを返す
+func hasOverflow(data metricdata.Aggregation) bool {
+	switch d := data.(type) {
+	case metricdata.Gauge[int64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.DataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.Gauge[float64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.DataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.Sum[int64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.DataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.Sum[float64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.DataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.Histogram[int64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.HistogramDataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.Histogram[float64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.HistogramDataPoint[float64]) attribute.Set { return p.Attributes })
+	case metricdata.ExponentialHistogram[int64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.ExponentialHistogramDataPoint[int64]) attribute.Set { return p.Attributes })
+	case metricdata.ExponentialHistogram[float64]:
+		return containsOverflow(d.DataPoints, func(p metricdata.ExponentialHistogramDataPoint[float64]) attribute.Set { return p.Attributes })
+	default:
+		return false
+	}
+}
+
+// containsOverflow は points のいずれかが otel.metric.overflow=true 属性を持つかを返す
+func containsOverflow[P any](points []P, attrs func(P) attribute.Set) bool {
+	for _, p := range points {
+		set := attrs(p)
+		if v, ok := set.Value(overflowAttribute.Key); ok && v.AsBool() {
+			return true
+		}
+	}
+	return false
+}
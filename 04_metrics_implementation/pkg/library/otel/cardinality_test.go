@@ -0,0 +1,103 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// captureSlog はテスト中の slog のデフォルトの出力先をバッファに差し替える
+func captureSlog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+// recordOverflow は上限を超える数の属性の組み合わせを Counter に記録する
+func recordOverflow(t *testing.T, p *Provider, limit int) {
+	t.Helper()
+	counter, err := p.MeterProvider.Meter("test").Int64Counter("article.views.total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range limit * 2 {
+		counter.Add(context.Background(), 1, metric.WithAttributes(attribute.String("article_id", fmt.Sprint(i))))
+	}
+}
+
+const overflowWarning = "metric cardinality limit exceeded"
+
+func TestOverflowWarnsOncePerInstrumentAcrossPipelines(t *testing.T) {
+	logs := captureSlog(t)
+	dir := t.TempDir()
+	p, err := NewProvider(t.Context(), Config{
+		ServiceName:            "test",
+		TraceExporter:          ExporterConfig{Type: ExporterNone},
+		MetricCardinalityLimit: 3,
+		MetricPipelines: []MetricPipeline{
+			{Name: "primary", Exporter: ExporterConfig{Type: ExporterFile, File: FileConfig{Path: filepath.Join(dir, "primary.jsonl")}}},
+			{Name: "secondary", Exporter: ExporterConfig{Type: ExporterFile, File: FileConfig{Path: filepath.Join(dir, "secondary.jsonl")}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+
+	recordOverflow(t, p, 3)
+	for range 2 {
+		if err := p.ForceFlush(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := strings.Count(logs.String(), overflowWarning); got != 1 {
+		t.Errorf("overflow warnings = %d, want 1\n%s", got, logs)
+	}
+	if !strings.Contains(logs.String(), "metric=article.views.total") {
+		t.Errorf("warning does not name the instrument:\n%s", logs)
+	}
+}
+
+func TestOverflowWarnsOnPrometheusScrape(t *testing.T) {
+	logs := captureSlog(t)
+	p, err := NewProvider(t.Context(), Config{
+		ServiceName:            "test",
+		TraceExporter:          ExporterConfig{Type: ExporterNone},
+		MetricExporter:         ExporterConfig{Type: ExporterNone},
+		MetricCardinalityLimit: 3,
+		Prometheus:             PrometheusConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+
+	recordOverflow(t, p, 3)
+	for range 2 {
+		rec := httptest.NewRecorder()
+		p.MetricsHandler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if !strings.Contains(rec.Body.String(), `otel_metric_overflow="true"`) {
+			t.Fatalf("scrape does not contain the overflow series:\n%s", rec.Body)
+		}
+	}
+
+	if got := strings.Count(logs.String(), overflowWarning); got != 1 {
+		t.Errorf("overflow warnings = %d, want 1\n%s", got, logs)
+	}
+	if !strings.Contains(logs.String(), "metric=article_views_total") {
+		t.Errorf("warning does not name the instrument:\n%s", logs)
+	}
+}
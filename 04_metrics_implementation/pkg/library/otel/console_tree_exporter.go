@@ -0,0 +1,335 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NOTE: コンソールツリーエクスポーター (開発環境向け)
+// stdouttrace の pretty print は1スパンあたり約80行になり、リクエストの流れを追いにくい (sample/01_get_by_id_result.txt 参照)。
+// スパンをトレースごとにバッファし、ローカルのルートスパン (親がないか、親がリモートのスパン) が終了した時点で
+// 1スパン1行のツリーとウォーターフォールとして出力する。
+//
+//	trace 9f30c8ed60eca2034c034b10dfd5d17b  3 spans  1.52ms
+//	http-server                          |██████████████████████████████|  1.52ms  OK     http.route=/articles/{id}
+//	└─ ArticleUsecase.GetByID            |   ████████████████████████   |  1.21ms  -      article.id=article-123
+//	   └─ ArticleRepository.FindByID     |      ██████                  |   310µs  ERROR  db.system=postgresql error="connection refused"
+//	      ◦ +1.02ms exception  exception.message="connection refused"
+//
+// ルートスパンが届かないトレース (ルートの実行中に終了した非同期処理のスパン等) は一定時間後と Shutdown 時に出力する。
+// リクエストがなくエクスポートが呼ばれない間も出力されるよう、最も古いトレースの期限にタイマーを設定する。
+
+const (
+	// consoleTreeBarWidth はウォーターフォールのバーの幅 (文字数)
+	consoleTreeBarWidth = 30
+	// consoleTreeMaxNameWidth はスパン名の列の最大幅 (文字数)
+	consoleTreeMaxNameWidth = 60
+	// consoleTreeMaxValueLength は属性の値を省略せずに表示する最大の文字数
+	consoleTreeMaxValueLength = 64
+	// consoleTreePendingTimeout はルートスパンが届かないトレースを出力するまでの時間
+	consoleTreePendingTimeout = 30 * time.Second
+	// consoleTreeMaxPendingTraces はバッファするトレースの最大数 (超えた場合は古いトレースから出力する)
+	consoleTreeMaxPendingTraces = 1000
+)
+
+// consoleTreeTrace はバッファ中のトレース
+type consoleTreeTrace struct {
+	spans     []sdktrace.ReadOnlySpan
+	firstSeen time.Time
+}
+
+// consoleTreeExporter はスパンをトレースごとにツリー形式で出力する sdktrace.SpanExporter
+type consoleTreeExporter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	pending map[trace.TraceID]*consoleTreeTrace
+	order   []trace.TraceID // NOTE: pending に追加した順 (古いトレースから出力するため)
+	now     func() time.Time
+	timer   *time.Timer // NOTE: ルートスパンが届かないトレースを出力するタイマー (最初のトレースを pending に追加した時点で生成する)
+	stopped bool
+}
+
+var _ sdktrace.SpanExporter = (*consoleTreeExporter)(nil)
+
+// newConsoleTreeExporter は w に出力する consoleTreeExporter を生成する
+func newConsoleTreeExporter(w io.Writer) *consoleTreeExporter {
+	return &consoleTreeExporter{
+		w:       w,
+		pending: make(map[trace.TraceID]*consoleTreeTrace),
+		now:     time.Now,
+	}
+}
+
+// ExportSpans はスパンをバッファし、ルートスパンが終了したトレースを出力する
+func (e *consoleTreeExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil
+	}
+
+	now := e.now()
+	var completed []trace.TraceID
+	for _, s := range spans {
+		id := s.SpanContext().TraceID()
+		t, ok := e.pending[id]
+		if !ok {
+			t = &consoleTreeTrace{firstSeen: now}
+			e.pending[id] = t
+			e.order = append(e.order, id)
+		}
+		t.spans = append(t.spans, s)
+		if parent := s.Parent(); !parent.IsValid() || parent.IsRemote() {
+			completed = append(completed, id)
+		}
+	}
+
+	var b strings.Builder
+	for _, id := range completed {
+		e.render(&b, id)
+	}
+	e.renderExpiredLocked(&b, now)
+	e.scheduleLocked(now)
+	return e.write(b.String())
+}
+
+// renderExpiredLocked はルートスパンが届かないまま一定時間が経過したトレースと、上限を超えた古いトレースを b に書き込む (e.mu を保持して呼ぶ)
+func (e *consoleTreeExporter) renderExpiredLocked(b *strings.Builder, now time.Time) {
+	for len(e.order) > 0 {
+		id := e.order[0]
+		if len(e.order) <= consoleTreeMaxPendingTraces && now.Sub(e.pending[id].firstSeen) < consoleTreePendingTimeout {
+			break
+		}
+		e.render(b, id)
+	}
+}
+
+// scheduleLocked は最も古いバッファ中のトレースの期限に flushExpired を実行するタイマーを設定する (e.mu を保持して呼ぶ)
+func (e *consoleTreeExporter) scheduleLocked(now time.Time) {
+	if len(e.order) == 0 {
+		if e.timer != nil {
+			e.timer.Stop()
+		}
+		return
+	}
+	d := max(e.pending[e.order[0]].firstSeen.Add(consoleTreePendingTimeout).Sub(now), 0)
+	if e.timer == nil {
+		e.timer = time.AfterFunc(d, e.flushExpired)
+		return
+	}
+	e.timer.Reset(d)
+}
+
+// flushExpired はルートスパンが届かないまま一定時間が経過したトレースを出力する (タイマーから呼ばれる)
+func (e *consoleTreeExporter) flushExpired() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return
+	}
+	now := e.now()
+	var b strings.Builder
+	e.renderExpiredLocked(&b, now)
+	e.scheduleLocked(now)
+	_ = e.write(b.String())
+}
+
+// Shutdown はバッファ中の全てのトレースを出力して、以降のエクスポートを停止する
+func (e *consoleTreeExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stopped {
+		return nil
+	}
+	e.stopped = true
+	if e.timer != nil {
+		e.timer.Stop()
+	}
+
+	var b strings.Builder
+	for len(e.order) > 0 {
+		e.render(&b, e.order[0])
+	}
+	return e.write(b.String())
+}
+
+// write は出力をまとめて1回で書き込む (複数のトレースの行が混ざらないようにする)
+func (e *consoleTreeExporter) write(s string) error {
+	if s == "" {
+		return nil
+	}
+	_, err := io.WriteString(e.w, s)
+	return err
+}
+
+// render はトレースをツリー形式で b に書き込み、pending から削除する
+func (e *consoleTreeExporter) render(b *strings.Builder, id trace.TraceID) {
+	t, ok := e.pending[id]
+	if !ok {
+		return
+	}
+	delete(e.pending, id)
+	e.order = slices.DeleteFunc(e.order, func(v trace.TraceID) bool { return v == id })
+
+	// 親子関係の構築
+	// NOTE: 親がバッファ内にないスパン (ルートスパン・親が先に出力されたスパン) はツリーの最上位として扱う
+	byID := make(map[trace.SpanID]bool, len(t.spans))
+	for _, s := range t.spans {
+		byID[s.SpanContext().SpanID()] = true
+	}
+	children := make(map[trace.SpanID][]sdktrace.ReadOnlySpan)
+	var roots []sdktrace.ReadOnlySpan
+	for _, s := range t.spans {
+		if parent := s.Parent().SpanID(); parent.IsValid() && byID[parent] {
+			children[parent] = append(children[parent], s)
+		} else {
+			roots = append(roots, s)
+		}
+	}
+	byStart := func(a, b sdktrace.ReadOnlySpan) int { return a.StartTime().Compare(b.StartTime()) }
+	slices.SortStableFunc(roots, byStart)
+	for _, c := range children {
+		slices.SortStableFunc(c, byStart)
+	}
+
+	// ツリーの行の生成 (深さ優先)
+	type row struct {
+		prefix string // NOTE: スパン名の前に付ける罫線
+		indent string // NOTE: 子スパンとイベントの行の前に付ける罫線
+		span   sdktrace.ReadOnlySpan
+	}
+	var rows []row
+	var walk func(s sdktrace.ReadOnlySpan, prefix, childIndent string)
+	walk = func(s sdktrace.ReadOnlySpan, prefix, childIndent string) {
+		rows = append(rows, row{prefix: prefix, indent: childIndent, span: s})
+		c := children[s.SpanContext().SpanID()]
+		for i, child := range c {
+			if i == len(c)-1 {
+				walk(child, childIndent+"└─ ", childIndent+"   ")
+			} else {
+				walk(child, childIndent+"├─ ", childIndent+"│  ")
+			}
+		}
+	}
+	for _, s := range roots {
+		walk(s, "", "")
+	}
+
+	// ウォーターフォールの基準となるトレース全体の開始・終了時刻
+	start, end := rows[0].span.StartTime(), rows[0].span.EndTime()
+	nameWidth := 0
+	for _, r := range rows {
+		if r.span.StartTime().Before(start) {
+			start = r.span.StartTime()
+		}
+		if r.span.EndTime().After(end) {
+			end = r.span.EndTime()
+		}
+		nameWidth = max(nameWidth, utf8.RuneCountInString(r.prefix)+utf8.RuneCountInString(r.span.Name()))
+	}
+	nameWidth = min(nameWidth, consoleTreeMaxNameWidth)
+	total := end.Sub(start)
+
+	fmt.Fprintf(b, "trace %s  %d spans  %s\n", id, len(t.spans), formatTreeDuration(total))
+	for _, r := range rows {
+		s := r.span
+		name := truncateRunes(r.prefix+s.Name(), nameWidth)
+		line := fmt.Sprintf("%s%s  |%s|  %7s  %-5s",
+			name, strings.Repeat(" ", nameWidth-utf8.RuneCountInString(name)),
+			treeBar(s.StartTime().Sub(start), s.EndTime().Sub(s.StartTime()), total),
+			formatTreeDuration(s.EndTime().Sub(s.StartTime())),
+			treeStatus(s.Status().Code),
+		)
+		for _, kv := range s.Attributes() {
+			line += "  " + formatTreeAttribute(kv)
+		}
+		if status := s.Status(); status.Code == codes.Error && status.Description != "" {
+			line += "  error=" + formatTreeValue(status.Description)
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+
+		// NOTE: イベントはスパン名の位置に揃えて出力する (子スパンがある場合は罫線を続ける)
+		eventIndent := r.indent
+		if len(children[s.SpanContext().SpanID()]) > 0 {
+			eventIndent += "│  "
+		}
+		for _, ev := range s.Events() {
+			fmt.Fprintf(b, "%s◦ +%s %s", eventIndent, formatTreeDuration(ev.Time.Sub(s.StartTime())), ev.Name)
+			for _, kv := range ev.Attributes {
+				b.WriteString("  " + formatTreeAttribute(kv))
+			}
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteByte('\n')
+}
+
+// treeBar はトレース全体に対するスパンの開始位置と長さをバーで表す (短いスパンも1文字は表示する)
+func treeBar(offset, duration, total time.Duration) string {
+	if total <= 0 {
+		return strings.Repeat("█", consoleTreeBarWidth)
+	}
+	from := min(int(int64(offset)*consoleTreeBarWidth/int64(total)), consoleTreeBarWidth-1)
+	width := min(max(int(int64(duration)*consoleTreeBarWidth/int64(total)), 1), consoleTreeBarWidth-from)
+	return strings.Repeat(" ", from) + strings.Repeat("█", width) + strings.Repeat(" ", consoleTreeBarWidth-from-width)
+}
+
+// treeStatus はステータスコードの表示 (未設定は "-")
+func treeStatus(code codes.Code) string {
+	switch code {
+	case codes.Ok:
+		return "OK"
+	case codes.Error:
+		return "ERROR"
+	default:
+		return "-"
+	}
+}
+
+// formatTreeDuration は時間を µs / ms / s の単位で短く表す
+func formatTreeDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return strconv.FormatInt(d.Microseconds(), 10) + "µs"
+	case d < time.Second:
+		return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 2, 64) + "ms"
+	default:
+		return strconv.FormatFloat(d.Seconds(), 'f', 2, 64) + "s"
+	}
+}
+
+// formatTreeAttribute は属性を key=value の形式で表す
+func formatTreeAttribute(kv attribute.KeyValue) string {
+	return string(kv.Key) + "=" + formatTreeValue(kv.Value.Emit())
+}
+
+// formatTreeValue は値を表示用に整形する (長い値は省略し、空白や記号を含む値は引用符で囲む)
+func formatTreeValue(v string) string {
+	v = truncateRunes(v, consoleTreeMaxValueLength)
+	if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// truncateRunes は s が n 文字を超える場合に末尾を "…" で省略する
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	r := []rune(s)
+	return string(r[:n-1]) + "…"
+}
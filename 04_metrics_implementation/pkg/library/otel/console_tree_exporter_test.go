@@ -0,0 +1,145 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// consoleTreeSpanContext は traceID のトレースの spanID のスパンの SpanContext を返す
+func consoleTreeSpanContext(traceID trace.TraceID, spanID byte) trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: trace.SpanID{spanID}, TraceFlags: trace.FlagsSampled})
+}
+
+// newTestConsoleTreeExporter は buf に出力し、now を現在時刻とする consoleTreeExporter を返す
+func newTestConsoleTreeExporter(t *testing.T, now *time.Time) (*consoleTreeExporter, *bytes.Buffer) {
+	t.Helper()
+	var buf bytes.Buffer
+	e := newConsoleTreeExporter(&buf)
+	e.now = func() time.Time { return *now }
+	t.Cleanup(func() { _ = e.Shutdown(context.Background()) })
+	return e, &buf
+}
+
+func TestConsoleTreeExporterRendersTree(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	e, buf := newTestConsoleTreeExporter(t, &now)
+
+	traceID := trace.TraceID{0x9f, 0x30}
+	start := now
+	root := consoleTreeSpanContext(traceID, 1)
+	usecase := consoleTreeSpanContext(traceID, 2)
+	spans := tracetest.SpanStubs{
+		{
+			Name:        "ArticleRepository.FindByID",
+			SpanContext: consoleTreeSpanContext(traceID, 3),
+			Parent:      usecase,
+			SpanKind:    trace.SpanKindClient,
+			StartTime:   start.Add(500 * time.Microsecond),
+			EndTime:     start.Add(810 * time.Microsecond),
+			Attributes:  []attribute.KeyValue{attribute.String("db.system", "postgresql")},
+			Status:      sdktrace.Status{Code: codes.Error, Description: "connection refused"},
+			Events: []sdktrace.Event{{
+				Name:       "exception",
+				Time:       start.Add(800 * time.Microsecond),
+				Attributes: []attribute.KeyValue{attribute.String("exception.message", "connection refused")},
+			}},
+		},
+		{
+			Name:        "ArticleUsecase.GetByID",
+			SpanContext: usecase,
+			Parent:      root,
+			StartTime:   start.Add(100 * time.Microsecond),
+			EndTime:     start.Add(time.Millisecond),
+			Attributes:  []attribute.KeyValue{attribute.String("article.id", "article-123")},
+		},
+		{
+			Name:        "cache.Get",
+			SpanContext: consoleTreeSpanContext(traceID, 4),
+			Parent:      root,
+			StartTime:   start.Add(1200 * time.Microsecond),
+			EndTime:     start.Add(1400 * time.Microsecond),
+		},
+		{
+			Name:        "http-server",
+			SpanContext: root,
+			SpanKind:    trace.SpanKindServer,
+			StartTime:   start,
+			EndTime:     start.Add(1500 * time.Microsecond),
+			Attributes:  []attribute.KeyValue{attribute.String("http.route", "/articles/{id}")},
+			Status:      sdktrace.Status{Code: codes.Ok},
+		},
+	}.Snapshots()
+
+	// NOTE: ルートスパンが届くまでは出力しない
+	if err := e.ExportSpans(context.Background(), spans[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("rendered before the root span ended:\n%s", buf)
+	}
+	if err := e.ExportSpans(context.Background(), spans[3:]); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "trace 9f300000000000000000000000000000  4 spans  1.50ms\n" +
+		"http-server                       |██████████████████████████████|   1.50ms  OK     http.route=/articles/{id}\n" +
+		"├─ ArticleUsecase.GetByID         |  ██████████████████          |    900µs  -      article.id=article-123\n" +
+		"│  └─ ArticleRepository.FindByID  |          ██████              |    310µs  ERROR  db.system=postgresql  error=\"connection refused\"\n" +
+		"│     ◦ +300µs exception  exception.message=\"connection refused\"\n" +
+		"└─ cache.Get                      |                        ████  |    200µs  -\n" +
+		"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("rendered =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestConsoleTreeExporterFlushesOrphanTracesWhenIdle(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	e, buf := newTestConsoleTreeExporter(t, &now)
+
+	// NOTE: 親 (リモートではない) が届かないスパンのみのトレース
+	traceID := trace.TraceID{0x01}
+	orphan := tracetest.SpanStubs{{
+		Name:        "async.Publish",
+		SpanContext: consoleTreeSpanContext(traceID, 2),
+		Parent:      consoleTreeSpanContext(traceID, 1),
+		StartTime:   now,
+		EndTime:     now.Add(time.Millisecond),
+	}}.Snapshots()
+	if err := e.ExportSpans(context.Background(), orphan); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("rendered an orphan trace before the timeout:\n%s", buf)
+	}
+
+	e.mu.Lock()
+	armed := e.timer != nil
+	e.mu.Unlock()
+	if !armed {
+		t.Fatal("no timer was armed for the pending trace")
+	}
+
+	// NOTE: 以降のエクスポートがなくても、期限にタイマーから出力される
+	now = now.Add(consoleTreePendingTimeout)
+	e.flushExpired()
+
+	want := "trace 01000000000000000000000000000000  1 spans  1.00ms\n" +
+		"async.Publish  |██████████████████████████████|   1.00ms  -\n" +
+		"\n"
+	if got := buf.String(); got != want {
+		t.Errorf("rendered =\n%s\nwant\n%s", got, want)
+	}
+	if len(e.pending) != 0 {
+		t.Errorf("%d traces are still pending", len(e.pending))
+	}
+}
@@ -0,0 +1,507 @@
+package otel
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LoadConfigFromEnv は OpenTelemetry 標準の OTEL_* 環境変数で Config を補完する
+//
+// cfg に明示的に設定された値 (ゼロ値でないフィールド) が環境変数より優先される。
+// 不正な値が含まれる場合は、該当する全ての環境変数名と値をまとめたエラーを返す。
+//
+// 対応する環境変数:
+//   - OTEL_SDK_DISABLED
+//   - OTEL_SERVICE_NAME / OTEL_RESOURCE_ATTRIBUTES
+//   - OTEL_RESOURCE_DETECTORS (host, os, process, service.instance, container, k8s, build, all, none のカンマ区切り)
+//   - OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG
+//   - OTEL_PROPAGATORS (tracecontext, baggage, b3, b3multi, jaeger, xray, none のカンマ区切り)
+//   - OTEL_TRACES_EXPORTER (otlp, console, zipkin, none) / OTEL_METRICS_EXPORTER (otlp, console, prometheus, none のカンマ区切り) / OTEL_LOGS_EXPORTER (otlp, console, none)
+//   - OTEL_EXPORTER_PROMETHEUS_HOST / OTEL_EXPORTER_PROMETHEUS_PORT
+//   - OTEL_EXPORTER_ZIPKIN_ENDPOINT / OTEL_EXPORTER_ZIPKIN_TIMEOUT
+//   - OTEL_EXPORTER_OTLP_{PROTOCOL, ENDPOINT, HEADERS, INSECURE, CERTIFICATE, CLIENT_CERTIFICATE, CLIENT_KEY, COMPRESSION, TIMEOUT}
+//     (OTEL_EXPORTER_OTLP_TRACES_* / OTEL_EXPORTER_OTLP_METRICS_* / OTEL_EXPORTER_OTLP_LOGS_* のシグナル別指定が優先される)
+//   - OTEL_METRIC_EXPORT_INTERVAL
+//   - OTEL_GO_RUNTIME_METRICS (true / false)
+//   - OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION (explicit_bucket_histogram, base2_exponential_bucket_histogram)
+//   - OTEL_METRICS_EXEMPLAR_FILTER (always_on, always_off, trace_based)
+//   - OTEL_GO_X_CARDINALITY_LIMIT
+func LoadConfigFromEnv(cfg Config) (Config, error) {
+	return loadConfig(cfg, os.LookupEnv)
+}
+
+// loadConfig は lookup で取得した環境変数で Config を補完する (テストで環境変数を差し替えられるよう分離)
+func loadConfig(cfg Config, lookup func(string) (string, bool)) (Config, error) {
+	l := envLoader{lookup: lookup}
+
+	if !cfg.Disabled {
+		cfg.Disabled = l.bool("OTEL_SDK_DISABLED")
+	}
+
+	// NOTE: OTEL_SERVICE_NAME は OTEL_RESOURCE_ATTRIBUTES の service.name より優先される
+	attrs := l.keyValues("OTEL_RESOURCE_ATTRIBUTES")
+	if cfg.ServiceName == "" {
+		if v, ok := l.string("OTEL_SERVICE_NAME"); ok {
+			cfg.ServiceName = v
+		} else {
+			cfg.ServiceName = attrs["service.name"]
+		}
+	}
+	if cfg.ServiceVersion == "" {
+		cfg.ServiceVersion = attrs["service.version"]
+	}
+	if cfg.Environment == "" {
+		cfg.Environment = attrs["deployment.environment"]
+	}
+	delete(attrs, "service.name")
+	delete(attrs, "service.version")
+	delete(attrs, "deployment.environment")
+	for k, v := range attrs {
+		if cfg.ResourceAttributes == nil {
+			cfg.ResourceAttributes = make(map[string]string, len(attrs))
+		}
+		if _, ok := cfg.ResourceAttributes[k]; !ok {
+			cfg.ResourceAttributes[k] = v
+		}
+	}
+
+	if cfg.ResourceDetectors == (ResourceDetectorsConfig{}) {
+		cfg.ResourceDetectors = l.resourceDetectors()
+	}
+
+	if cfg.Sampler.Type == "" {
+		cfg.Sampler = l.sampler()
+	}
+
+	if len(cfg.Propagators) == 0 {
+		cfg.Propagators = l.propagators()
+	}
+
+	cfg.TraceExporter = l.exporter(cfg.TraceExporter, "OTEL_TRACES_EXPORTER", "TRACES", "/v1/traces")
+	cfg.MetricExporter = l.exporter(cfg.MetricExporter, "OTEL_METRICS_EXPORTER", "METRICS", "/v1/metrics")
+	cfg.LogExporter = l.exporter(cfg.LogExporter, "OTEL_LOGS_EXPORTER", "LOGS", "/v1/logs")
+
+	if !cfg.Prometheus.Enabled {
+		cfg.Prometheus = l.prometheus()
+	}
+
+	if cfg.MetricExportInterval == 0 {
+		cfg.MetricExportInterval = l.millis("OTEL_METRIC_EXPORT_INTERVAL")
+	}
+
+	if !cfg.RuntimeMetrics {
+		cfg.RuntimeMetrics = l.bool("OTEL_GO_RUNTIME_METRICS")
+	}
+
+	if cfg.HistogramAggregation == "" {
+		cfg.HistogramAggregation = l.histogramAggregation()
+	}
+
+	// NOTE: SDK も同じ環境変数を読むが、NewProvider は常に WithExemplarFilter を指定するため、ここで Config に取り込む
+	if cfg.Exemplars == "" {
+		cfg.Exemplars = l.exemplarFilter()
+	}
+
+	// NOTE: SDK も同じ環境変数を読むが、NewProvider は常に WithCardinalityLimit を指定するため、ここで Config に取り込む
+	if cfg.MetricCardinalityLimit == 0 {
+		if v, ok := l.string("OTEL_GO_X_CARDINALITY_LIMIT"); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				l.invalid("OTEL_GO_X_CARDINALITY_LIMIT", v, err)
+			}
+			cfg.MetricCardinalityLimit = n
+		}
+	}
+
+	if len(l.errs) > 0 {
+		return cfg, errors.Join(l.errs...)
+	}
+	return cfg, nil
+}
+
+// envLoader は環境変数の読み取りとパースエラーの収集を行う
+type envLoader struct {
+	lookup func(string) (string, bool)
+	errs   []error
+}
+
+// invalid はパースエラーを環境変数名付きで記録する
+func (l *envLoader) invalid(key, value string, err error) {
+	l.errs = append(l.errs, fmt.Errorf("otel: invalid %s=%q: %w", key, value, err))
+}
+
+// string は空でない環境変数の値を返す
+func (l *envLoader) string(key string) (string, bool) {
+	v, ok := l.lookup(key)
+	v = strings.TrimSpace(v)
+	return v, ok && v != ""
+}
+
+// firstString は keys のうち最初に設定されている環境変数の名前と値を返す
+func (l *envLoader) firstString(keys ...string) (string, string, bool) {
+	for _, k := range keys {
+		if v, ok := l.string(k); ok {
+			return k, v, true
+		}
+	}
+	return "", "", false
+}
+
+// bool は true / false の環境変数をパースする (未設定の場合は false)
+func (l *envLoader) bool(keys ...string) bool {
+	key, v, ok := l.firstString(keys...)
+	if !ok {
+		return false
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.invalid(key, v, errors.New("must be true or false"))
+		return false
+	}
+	return b
+}
+
+// millis はミリ秒単位の整数の環境変数を time.Duration にパースする (未設定の場合は 0)
+func (l *envLoader) millis(keys ...string) time.Duration {
+	key, v, ok := l.firstString(keys...)
+	if !ok {
+		return 0
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || ms < 0 {
+		l.invalid(key, v, errors.New("must be a non-negative integer in milliseconds"))
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// keyValues は "key1=value1,key2=value2" 形式 (値はパーセントエンコード可) の環境変数をパースする
+func (l *envLoader) keyValues(keys ...string) map[string]string {
+	key, v, ok := l.firstString(keys...)
+	if !ok {
+		return map[string]string{}
+	}
+
+	m := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		k, val, found := strings.Cut(pair, "=")
+		k = strings.TrimSpace(k)
+		if !found || k == "" {
+			l.invalid(key, v, fmt.Errorf("entry %q must be in key=value form", pair))
+			return map[string]string{}
+		}
+		decoded, err := url.PathUnescape(strings.TrimSpace(val))
+		if err != nil {
+			l.invalid(key, v, fmt.Errorf("entry %q has malformed percent-encoding", pair))
+			return map[string]string{}
+		}
+		m[k] = decoded
+	}
+	return m
+}
+
+// sampler は OTEL_TRACES_SAMPLER / OTEL_TRACES_SAMPLER_ARG をパースする
+func (l *envLoader) sampler() SamplerConfig {
+	v, ok := l.string("OTEL_TRACES_SAMPLER")
+	if !ok {
+		return SamplerConfig{}
+	}
+
+	cfg := SamplerConfig{Type: SamplerType(v)}
+	switch cfg.Type {
+	case SamplerAlwaysOn, SamplerAlwaysOff, SamplerParentBasedAlwaysOn, SamplerParentBasedAlwaysOff:
+		return cfg
+	case SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio:
+		// NOTE: OTEL_TRACES_SAMPLER_ARG が未設定の場合、仕様上のデフォルトは 1.0
+	default:
+		l.invalid("OTEL_TRACES_SAMPLER", v, errors.New("must be one of always_on, always_off, traceidratio, parentbased_always_on, parentbased_always_off, parentbased_traceidratio"))
+		return SamplerConfig{}
+	}
+
+	if arg, ok := l.string("OTEL_TRACES_SAMPLER_ARG"); ok {
+		ratio, err := strconv.ParseFloat(arg, 64)
+		if err != nil || validateRatio(ratio) != nil {
+			l.invalid("OTEL_TRACES_SAMPLER_ARG", arg, errors.New("must be a number in [0, 1]"))
+			return SamplerConfig{}
+		}
+		cfg.Ratio = &ratio // NOTE: 0 は未指定ではなく「ルートスパンを記録しない」として扱う
+	}
+	return cfg
+}
+
+// histogramAggregation は OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION をパースする
+func (l *envLoader) histogramAggregation() AggregationType {
+	const key = "OTEL_EXPORTER_OTLP_METRICS_DEFAULT_HISTOGRAM_AGGREGATION"
+	v, ok := l.string(key)
+	if !ok {
+		return ""
+	}
+	switch t := AggregationType(strings.ToLower(v)); t {
+	case AggregationExplicitBucketHistogram, AggregationBase2ExponentialHistogram:
+		return t
+	default:
+		l.invalid(key, v, errors.New("must be explicit_bucket_histogram or base2_exponential_bucket_histogram"))
+		return ""
+	}
+}
+
+// resourceDetectors は OTEL_RESOURCE_DETECTORS をパースする
+func (l *envLoader) resourceDetectors() ResourceDetectorsConfig {
+	const key = "OTEL_RESOURCE_DETECTORS"
+	v, ok := l.string(key)
+	if !ok {
+		return ResourceDetectorsConfig{}
+	}
+	var c ResourceDetectorsConfig
+	for _, name := range strings.Split(v, ",") {
+		switch strings.TrimSpace(name) {
+		case "", "none":
+		case "host":
+			c.Host = true
+		case "os":
+			c.OS = true
+		case "process":
+			c.Process = true
+		case "service.instance":
+			c.ServiceInstance = true
+		case "container":
+			c.Container = true
+		case "k8s":
+			c.Kubernetes = true
+		case "build":
+			c.BuildInfo = true
+		case "all":
+			c = ResourceDetectorsConfig{Host: true, OS: true, Process: true, ServiceInstance: true, Container: true, Kubernetes: true, BuildInfo: true}
+		default:
+			l.invalid(key, v, errors.New("must be a comma-separated list of host, os, process, service.instance, container, k8s, build, all or none"))
+			return ResourceDetectorsConfig{}
+		}
+	}
+	return c
+}
+
+// propagators は OTEL_PROPAGATORS をパースする
+func (l *envLoader) propagators() []Propagator {
+	const key = "OTEL_PROPAGATORS"
+	v, ok := l.string(key)
+	if !ok {
+		return nil
+	}
+	var names []Propagator
+	for _, name := range strings.Split(v, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		names = append(names, Propagator(name))
+	}
+	// NOTE: 名前の検証は NewProvider と同じ処理で行う
+	if _, err := newPropagator(names); err != nil {
+		l.invalid(key, v, errors.New("must be a comma-separated list of tracecontext, baggage, b3, b3multi, jaeger, xray or none"))
+		return nil
+	}
+	return names
+}
+
+// exemplarFilter は OTEL_METRICS_EXEMPLAR_FILTER をパースする
+func (l *envLoader) exemplarFilter() ExemplarFilter {
+	const key = "OTEL_METRICS_EXEMPLAR_FILTER"
+	v, ok := l.string(key)
+	if !ok {
+		return ""
+	}
+	switch f := ExemplarFilter(strings.ToLower(v)); f {
+	case ExemplarFilterAlwaysOn, ExemplarFilterAlwaysOff, ExemplarFilterTraceBased:
+		return f
+	default:
+		l.invalid(key, v, errors.New("must be always_on, always_off or trace_based"))
+		return ""
+	}
+}
+
+// prometheus は OTEL_METRICS_EXPORTER に prometheus が含まれる場合に Prometheus の設定を返す
+//
+// OTEL_EXPORTER_PROMETHEUS_HOST / OTEL_EXPORTER_PROMETHEUS_PORT のどちらかが設定されている場合は専用ポートで公開する。
+func (l *envLoader) prometheus() PrometheusConfig {
+	v, _ := l.string("OTEL_METRICS_EXPORTER")
+	enabled := false
+	for _, name := range strings.Split(v, ",") {
+		if strings.TrimSpace(name) == "prometheus" {
+			enabled = true
+		}
+	}
+	if !enabled {
+		return PrometheusConfig{}
+	}
+
+	cfg := PrometheusConfig{Enabled: true}
+	host, hostOK := l.string("OTEL_EXPORTER_PROMETHEUS_HOST")
+	port, portOK := l.string("OTEL_EXPORTER_PROMETHEUS_PORT")
+	if portOK {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			l.invalid("OTEL_EXPORTER_PROMETHEUS_PORT", port, errors.New("must be a port number"))
+			return PrometheusConfig{}
+		}
+	}
+	if hostOK || portOK {
+		// NOTE: 仕様上のデフォルトは localhost:9464
+		cfg.Addr = net.JoinHostPort(cmp.Or(host, "localhost"), cmp.Or(port, "9464"))
+	}
+	return cfg
+}
+
+// zipkin は OTEL_EXPORTER_ZIPKIN_* で ZipkinConfig を補完する
+func (l *envLoader) zipkin(cfg ZipkinConfig) ZipkinConfig {
+	if cfg.Endpoint == "" {
+		if v, ok := l.string("OTEL_EXPORTER_ZIPKIN_ENDPOINT"); ok {
+			u, err := url.Parse(v)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				l.invalid("OTEL_EXPORTER_ZIPKIN_ENDPOINT", v, errors.New("must be an absolute http or https URL"))
+			} else {
+				cfg.Endpoint = v
+			}
+		}
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = l.millis("OTEL_EXPORTER_ZIPKIN_TIMEOUT")
+	}
+	return cfg
+}
+
+// exporter は OTEL_{TRACES,METRICS}_EXPORTER と OTEL_EXPORTER_OTLP_* で ExporterConfig を補完する
+//
+// signal は "TRACES" / "METRICS" / "LOGS"、defaultPath は汎用エンドポイントに付与する OTLP/HTTP のパス。
+func (l *envLoader) exporter(cfg ExporterConfig, exporterKey, signal, defaultPath string) ExporterConfig {
+	otlpKey := func(name string) []string {
+		return []string{"OTEL_EXPORTER_OTLP_" + signal + "_" + name, "OTEL_EXPORTER_OTLP_" + name}
+	}
+
+	// エクスポーターの種類: OTEL_{SIGNAL}_EXPORTER → OTLP 系の環境変数が設定されていれば OTLP
+	// NOTE: OTEL_METRICS_EXPORTER の prometheus は PeriodicReader と併用する pull 型の Reader として prometheus() で扱う
+	useOTLP, useNone, usePrometheus := false, false, false
+	if v, ok := l.string(exporterKey); ok {
+		for _, name := range strings.Split(v, ",") {
+			switch strings.TrimSpace(name) {
+			case "otlp":
+				useOTLP = true
+			case "console":
+				if cfg.Type == "" {
+					cfg.Type = ExporterStdout
+				}
+			case "none":
+				useNone = true
+			case "prometheus":
+				if signal != "METRICS" {
+					l.invalid(exporterKey, v, errors.New("prometheus is only supported for metrics"))
+				}
+				usePrometheus = true
+			case "zipkin":
+				if signal != "TRACES" {
+					l.invalid(exporterKey, v, errors.New("zipkin is only supported for traces"))
+				} else if cfg.Type == "" {
+					cfg.Type = ExporterZipkin
+				}
+			default:
+				l.invalid(exporterKey, v, errors.New("must be a comma-separated list of otlp, console, prometheus, zipkin or none"))
+			}
+		}
+	} else if _, _, ok := l.firstString(append(otlpKey("ENDPOINT"), otlpKey("PROTOCOL")...)...); ok {
+		useOTLP = true
+	}
+	// NOTE: none と、メトリクスの prometheus のみの場合は push 型のエクスポーターを作成しない (シグナルを無効化する)
+	if cfg.Type == "" && !useOTLP && (useNone || usePrometheus) {
+		cfg.Type = ExporterNone
+	}
+	// NOTE: 明示的に指定された Type は環境変数で上書きしない
+	if useOTLP && cfg.Type == "" {
+		cfg.Type = ExporterOTLPGRPC
+		if key, v, ok := l.firstString(otlpKey("PROTOCOL")...); ok {
+			switch v {
+			case "grpc":
+			case "http/protobuf":
+				cfg.Type = ExporterOTLPHTTP
+				if cfg.OTLP.Encoding == "" {
+					cfg.OTLP.Encoding = OTLPEncodingProtobuf
+				}
+			case "http/json":
+				cfg.Type = ExporterOTLPHTTP
+				if cfg.OTLP.Encoding == "" {
+					cfg.OTLP.Encoding = OTLPEncodingJSON
+				}
+			default:
+				l.invalid(key, v, errors.New("must be grpc, http/protobuf or http/json"))
+			}
+		}
+	}
+	if cfg.Type == ExporterZipkin {
+		cfg.Zipkin = l.zipkin(cfg.Zipkin)
+		return cfg
+	}
+	if cfg.Type != ExporterOTLPGRPC && cfg.Type != ExporterOTLPHTTP {
+		return cfg
+	}
+
+	o := &cfg.OTLP
+	if o.Endpoint == "" {
+		if key, v, ok := l.firstString(otlpKey("ENDPOINT")...); ok {
+			u, err := url.Parse(v)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				l.invalid(key, v, errors.New("must be an absolute http or https URL"))
+			} else {
+				o.Endpoint = u.Host
+				if u.Scheme == "http" {
+					o.Insecure = true
+				}
+				// NOTE: シグナル別のエンドポイントはパスを含めてそのまま使用し (パスが無い場合は "/")、
+				// 汎用エンドポイントにはシグナルごとのパスを付与する
+				if cfg.Type == ExporterOTLPHTTP && o.URLPath == "" {
+					if strings.HasPrefix(key, "OTEL_EXPORTER_OTLP_"+signal) {
+						o.URLPath = cmp.Or(u.Path, "/")
+					} else if path := strings.TrimSuffix(u.Path, "/"); path != "" {
+						o.URLPath = path + defaultPath
+					}
+				}
+			}
+		}
+	}
+	if len(o.Headers) == 0 {
+		if h := l.keyValues(otlpKey("HEADERS")...); len(h) > 0 {
+			o.Headers = h
+		}
+	}
+	if !o.Insecure {
+		o.Insecure = l.bool(otlpKey("INSECURE")...)
+	}
+	if o.CAFile == "" {
+		_, o.CAFile, _ = l.firstString(otlpKey("CERTIFICATE")...)
+	}
+	if o.ClientCertFile == "" {
+		_, o.ClientCertFile, _ = l.firstString(otlpKey("CLIENT_CERTIFICATE")...)
+	}
+	if o.ClientKeyFile == "" {
+		_, o.ClientKeyFile, _ = l.firstString(otlpKey("CLIENT_KEY")...)
+	}
+	if o.Compression == "" {
+		if key, v, ok := l.firstString(otlpKey("COMPRESSION")...); ok {
+			if v != "gzip" && v != "none" {
+				l.invalid(key, v, errors.New("must be gzip or none"))
+			} else {
+				o.Compression = v
+			}
+		}
+	}
+	if o.Timeout == 0 {
+		o.Timeout = l.millis(otlpKey("TIMEOUT")...)
+	}
+	return cfg
+}
@@ -0,0 +1,327 @@
+package otel
+
+import (
+	"testing"
+)
+
+// envLookup は map を環境変数として扱う lookup 関数を返す
+func envLookup(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func TestLoadConfigExplicitValuesWin(t *testing.T) {
+	cfg, err := loadConfig(Config{
+		ServiceName:    "article-api",
+		ServiceVersion: "1.0.0",
+		Environment:    "development",
+		TraceExporter:  ExporterConfig{Type: ExporterStdout},
+	}, envLookup(map[string]string{
+		"OTEL_SERVICE_NAME":        "from-env",
+		"OTEL_RESOURCE_ATTRIBUTES": "service.version=9.9.9,deployment.environment=production,team=article",
+		"OTEL_TRACES_EXPORTER":     "otlp",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServiceName != "article-api" || cfg.ServiceVersion != "1.0.0" || cfg.Environment != "development" {
+		t.Errorf("service = %q %q %q, want explicit values", cfg.ServiceName, cfg.ServiceVersion, cfg.Environment)
+	}
+	if cfg.TraceExporter.Type != ExporterStdout {
+		t.Errorf("TraceExporter.Type = %q, want explicit %q", cfg.TraceExporter.Type, ExporterStdout)
+	}
+	if cfg.ResourceAttributes["team"] != "article" {
+		t.Errorf("ResourceAttributes = %v, want team from env", cfg.ResourceAttributes)
+	}
+
+	// NOTE: 未指定のフィールドは環境変数で補完される
+	cfg, err = loadConfig(Config{}, envLookup(map[string]string{
+		"OTEL_SERVICE_NAME":        "from-env",
+		"OTEL_RESOURCE_ATTRIBUTES": "service.version=9.9.9,deployment.environment=production",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServiceName != "from-env" || cfg.ServiceVersion != "9.9.9" || cfg.Environment != "production" {
+		t.Errorf("service = %q %q %q, want values from env", cfg.ServiceName, cfg.ServiceVersion, cfg.Environment)
+	}
+}
+
+func TestLoadConfigExporterTypes(t *testing.T) {
+	tests := []struct {
+		name           string
+		env            map[string]string
+		wantTrace      ExporterType
+		wantMetric     ExporterType
+		wantLog        ExporterType
+		wantPrometheus bool
+	}{
+		{
+			name:       "unset",
+			env:        map[string]string{},
+			wantTrace:  "",
+			wantMetric: "",
+			wantLog:    "",
+		},
+		{
+			name: "none for every signal",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":  "none",
+				"OTEL_METRICS_EXPORTER": "none",
+				"OTEL_LOGS_EXPORTER":    "none",
+			},
+			wantTrace:  ExporterNone,
+			wantMetric: ExporterNone,
+			wantLog:    ExporterNone,
+		},
+		{
+			name:           "prometheus only disables the push exporter",
+			env:            map[string]string{"OTEL_METRICS_EXPORTER": "prometheus"},
+			wantMetric:     ExporterNone,
+			wantPrometheus: true,
+		},
+		{
+			name:           "prometheus with otlp keeps the push exporter",
+			env:            map[string]string{"OTEL_METRICS_EXPORTER": "prometheus,otlp"},
+			wantMetric:     ExporterOTLPGRPC,
+			wantPrometheus: true,
+		},
+		{
+			name:           "prometheus with console keeps stdout",
+			env:            map[string]string{"OTEL_METRICS_EXPORTER": "console, prometheus"},
+			wantMetric:     ExporterStdout,
+			wantPrometheus: true,
+		},
+		{
+			name: "otlp with protocol",
+			env: map[string]string{
+				"OTEL_TRACES_EXPORTER":                "otlp",
+				"OTEL_EXPORTER_OTLP_TRACES_PROTOCOL":  "http/json",
+				"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "http://collector:4317",
+			},
+			wantTrace:  ExporterOTLPHTTP,
+			wantMetric: ExporterOTLPGRPC,
+		},
+		{
+			name:      "zipkin",
+			env:       map[string]string{"OTEL_TRACES_EXPORTER": "zipkin"},
+			wantTrace: ExporterZipkin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := loadConfig(Config{}, envLookup(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.TraceExporter.Type != tt.wantTrace {
+				t.Errorf("TraceExporter.Type = %q, want %q", cfg.TraceExporter.Type, tt.wantTrace)
+			}
+			if cfg.MetricExporter.Type != tt.wantMetric {
+				t.Errorf("MetricExporter.Type = %q, want %q", cfg.MetricExporter.Type, tt.wantMetric)
+			}
+			if cfg.LogExporter.Type != tt.wantLog {
+				t.Errorf("LogExporter.Type = %q, want %q", cfg.LogExporter.Type, tt.wantLog)
+			}
+			if cfg.Prometheus.Enabled != tt.wantPrometheus {
+				t.Errorf("Prometheus.Enabled = %v, want %v", cfg.Prometheus.Enabled, tt.wantPrometheus)
+			}
+		})
+	}
+}
+
+func TestLoadConfigInvalidExporters(t *testing.T) {
+	tests := map[string]map[string]string{
+		"unknown exporter":       {"OTEL_TRACES_EXPORTER": "jaeger"},
+		"prometheus for traces":  {"OTEL_TRACES_EXPORTER": "prometheus"},
+		"zipkin for metrics":     {"OTEL_METRICS_EXPORTER": "zipkin"},
+		"unknown protocol":       {"OTEL_EXPORTER_OTLP_PROTOCOL": "http"},
+		"relative endpoint":      {"OTEL_EXPORTER_OTLP_ENDPOINT": "collector:4317"},
+		"invalid zipkin address": {"OTEL_TRACES_EXPORTER": "zipkin", "OTEL_EXPORTER_ZIPKIN_ENDPOINT": "localhost:9411"},
+	}
+	for name, env := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadConfig(Config{}, envLookup(env)); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadConfigOTLPHTTPEndpoints(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantEndpoint string
+		wantPath     string
+		wantInsecure bool
+	}{
+		{
+			name: "generic endpoint without path uses the SDK default path",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318",
+			},
+			wantEndpoint: "collector:4318",
+			wantPath:     "",
+			wantInsecure: true,
+		},
+		{
+			name: "generic endpoint with path appends the signal path",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT": "https://gateway.example.com/otlp/",
+			},
+			wantEndpoint: "gateway.example.com",
+			wantPath:     "/otlp/v1/traces",
+		},
+		{
+			name: "signal endpoint with path is used as is",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://gateway.example.com/custom/traces",
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "https://ignored.example.com",
+			},
+			wantEndpoint: "gateway.example.com",
+			wantPath:     "/custom/traces",
+		},
+		{
+			name: "signal endpoint without path is used as is",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector:4318",
+			},
+			wantEndpoint: "collector:4318",
+			wantPath:     "/",
+			wantInsecure: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.env["OTEL_EXPORTER_OTLP_PROTOCOL"] = "http/protobuf"
+			cfg, err := loadConfig(Config{}, envLookup(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := cfg.TraceExporter.OTLP
+			if cfg.TraceExporter.Type != ExporterOTLPHTTP || o.Endpoint != tt.wantEndpoint || o.URLPath != tt.wantPath || o.Insecure != tt.wantInsecure {
+				t.Errorf("got type=%q endpoint=%q path=%q insecure=%v, want endpoint=%q path=%q insecure=%v",
+					cfg.TraceExporter.Type, o.Endpoint, o.URLPath, o.Insecure, tt.wantEndpoint, tt.wantPath, tt.wantInsecure)
+			}
+		})
+	}
+}
+
+func TestNewProviderWithDisabledSignals(t *testing.T) {
+	cfg, err := loadConfig(Config{ServiceName: "test"}, envLookup(map[string]string{
+		"OTEL_TRACES_EXPORTER":  "none",
+		"OTEL_METRICS_EXPORTER": "none",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewProvider(t.Context(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := p.TracerProvider.Tracer("test").Start(t.Context(), "span")
+	span.End()
+	if err := p.ForceFlush(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Shutdown(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigResourceDetectors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want ResourceDetectorsConfig
+	}{
+		{name: "unset", want: ResourceDetectorsConfig{}},
+		{name: "none", env: "none", want: ResourceDetectorsConfig{}},
+		{name: "list", env: "host, k8s,build", want: ResourceDetectorsConfig{Host: true, Kubernetes: true, BuildInfo: true}},
+		{name: "all", env: "all", want: ResourceDetectorsConfig{Host: true, OS: true, Process: true, ServiceInstance: true, Container: true, Kubernetes: true, BuildInfo: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := map[string]string{}
+			if tt.env != "" {
+				env["OTEL_RESOURCE_DETECTORS"] = tt.env
+			}
+			cfg, err := loadConfig(Config{}, envLookup(env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.ResourceDetectors != tt.want {
+				t.Errorf("ResourceDetectors = %+v, want %+v", cfg.ResourceDetectors, tt.want)
+			}
+		})
+	}
+
+	// NOTE: Config で明示的に指定した Detector が優先される
+	cfg, err := loadConfig(Config{ResourceDetectors: ResourceDetectorsConfig{OS: true}}, envLookup(map[string]string{"OTEL_RESOURCE_DETECTORS": "all"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ResourceDetectors != (ResourceDetectorsConfig{OS: true}) {
+		t.Errorf("ResourceDetectors = %+v, want the explicit config", cfg.ResourceDetectors)
+	}
+
+	if _, err := loadConfig(Config{}, envLookup(map[string]string{"OTEL_RESOURCE_DETECTORS": "host,gcp"})); err == nil {
+		t.Error("expected error for an unknown detector")
+	}
+}
+
+func TestLoadConfigRuntimeMetrics(t *testing.T) {
+	tests := map[string]struct {
+		env  map[string]string
+		want bool
+	}{
+		"unset": {env: map[string]string{}, want: false},
+		"false": {env: map[string]string{"OTEL_GO_RUNTIME_METRICS": "false"}, want: false},
+		"true":  {env: map[string]string{"OTEL_GO_RUNTIME_METRICS": "true"}, want: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := loadConfig(Config{}, envLookup(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.RuntimeMetrics != tt.want {
+				t.Errorf("RuntimeMetrics = %v, want %v", cfg.RuntimeMetrics, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigSamplerArg(t *testing.T) {
+	tests := map[string]struct {
+		env     map[string]string
+		want    *float64
+		sampled bool
+	}{
+		"unset arg defaults to one": {env: map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio"}, want: nil, sampled: true},
+		"zero drops every trace":    {env: map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio", "OTEL_TRACES_SAMPLER_ARG": "0"}, want: ratioPtr(0), sampled: false},
+		"zero drops every root":     {env: map[string]string{"OTEL_TRACES_SAMPLER": "parentbased_traceidratio", "OTEL_TRACES_SAMPLER_ARG": "0"}, want: ratioPtr(0), sampled: false},
+		"one keeps every trace":     {env: map[string]string{"OTEL_TRACES_SAMPLER": "traceidratio", "OTEL_TRACES_SAMPLER_ARG": "1"}, want: ratioPtr(1), sampled: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := loadConfig(Config{}, envLookup(tt.env))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Sampler.Ratio; (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Fatalf("Sampler.Ratio = %v, want %v", got, tt.want)
+			}
+			sampler, err := newSampler(cfg.Sampler)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sampleRoot(sampler, lowTraceID, "span"); got != tt.sampled {
+				t.Errorf("sampled = %v, want %v", got, tt.sampled)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package otel
+
+import (
+	"fmt"
+
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+)
+
+// NOTE: Exemplar
+// Exemplar は計器への記録のうちいくつかを、記録時のトレース ID・スパン ID・値・時刻と一緒にデータポイントに保持する仕組み。
+// 例えば article.create.duration の 2〜5秒のバケットが急増した場合に、そのバケットに記録された Exemplar の trace_id から
+// 実際に遅かったリクエストのトレースへ直接辿れるようになる。
+//
+// トレース ID は Record / Add に渡した ctx のスパンから取得されるため、計装コードではスパンを開始した後の ctx を渡す必要がある。
+// SDK はデータポイントごとに保持する数を制限するため (明示的なバケット境界のヒストグラムはバケットごとに1件、それ以外は GOMAXPROCS 件まで)、
+// Exemplar を有効にしても記録件数に比例してデータ量が増えることはない。
+
+// ExemplarFilter は Exemplar の候補とする記録を選ぶ条件
+type ExemplarFilter string
+
+const (
+	// ExemplarFilterTraceBased はサンプリングされたスパン内での記録のみを候補とする (デフォルト)。
+	// Exemplar の trace_id から辿れるトレースが必ずバックエンドに存在する
+	ExemplarFilterTraceBased ExemplarFilter = "trace_based"
+
+	// ExemplarFilterAlwaysOn は全ての記録を候補とする。
+	// スパン外やサンプリングされなかったスパン内での記録は trace_id を持たない Exemplar となる
+	ExemplarFilterAlwaysOn ExemplarFilter = "always_on"
+
+	// ExemplarFilterAlwaysOff は Exemplar を収集しない
+	ExemplarFilterAlwaysOff ExemplarFilter = "always_off"
+)
+
+// filter は ExemplarFilter を exemplar.Filter に変換する
+func (f ExemplarFilter) filter() (exemplar.Filter, error) {
+	switch f {
+	case "", ExemplarFilterTraceBased:
+		return exemplar.TraceBasedFilter, nil
+	case ExemplarFilterAlwaysOn:
+		return exemplar.AlwaysOnFilter, nil
+	case ExemplarFilterAlwaysOff:
+		return exemplar.AlwaysOffFilter, nil
+	default:
+		return nil, fmt.Errorf("otel: unsupported exemplar filter %q", f)
+	}
+}
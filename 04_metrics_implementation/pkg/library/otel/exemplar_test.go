@@ -0,0 +1,86 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestTraceBasedExemplarsAreEncodedAsHex(t *testing.T) {
+	filter, err := ExemplarFilterTraceBased.filter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithExemplarFilter(filter))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	duration, err := mp.Meter("article").Float64Histogram("article.create.duration")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sampled := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+	notSampled := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+	t.Cleanup(func() {
+		_ = sampled.Shutdown(context.Background())
+		_ = notSampled.Shutdown(context.Background())
+	})
+
+	// NOTE: サンプリングされなかったスパン内やスパン外での記録は trace_based では Exemplar にならない
+	ctx, span := notSampled.Tracer("test").Start(context.Background(), "not sampled")
+	duration.Record(ctx, 5)
+	span.End()
+	duration.Record(context.Background(), 5)
+
+	ctx, span = sampled.Tracer("test").Start(context.Background(), "ArticleUsecase.Create")
+	duration.Record(ctx, 0.3)
+	span.End()
+	sc := span.SpanContext()
+	traceID, spanID := sc.TraceID(), sc.SpanID()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	data := rm.ScopeMetrics[0].Metrics[0].Data.(metricdata.Histogram[float64])
+	exemplars := data.DataPoints[0].Exemplars
+	if len(exemplars) != 1 {
+		t.Fatalf("got %d exemplars, want 1 from the sampled span", len(exemplars))
+	}
+	if e := exemplars[0]; e.Value != 0.3 || !bytes.Equal(e.TraceID, traceID[:]) || !bytes.Equal(e.SpanID, spanID[:]) {
+		t.Errorf("exemplar = %+v, want value 0.3 with trace %s and span %s", e, sc.TraceID(), sc.SpanID())
+	}
+
+	var buf bytes.Buffer
+	if err := newReadableMetricEncoder(&buf).Encode(&rm); err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Data struct {
+					DataPoints []struct {
+						Exemplars []struct {
+							Value   float64
+							TraceID string
+							SpanID  string
+						}
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("decode encoded metrics: %v", err)
+	}
+	encoded := out.ScopeMetrics[0].Metrics[0].Data.DataPoints[0].Exemplars
+	if len(encoded) != 1 || encoded[0].TraceID != sc.TraceID().String() || encoded[0].SpanID != sc.SpanID().String() {
+		t.Errorf("encoded exemplars = %+v, want trace_id %s and span_id %s in hex", encoded, sc.TraceID(), sc.SpanID())
+	}
+}
@@ -0,0 +1,153 @@
+package otel
+
+import (
+	"fmt"
+	"math"
+
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// AggregationBase2ExponentialHistogram は Base2 指数ヒストグラムに集約する
+//
+// バケット境界を事前に設計する必要がなく、記録された値の範囲に合わせて解像度 (scale) を自動で調整する。
+// 各バケットの上限は前のバケットの 2^(2^-scale) 倍になるため、値の大小に関わらず一定の相対誤差でパーセンタイルを算出できる。
+// 例: scale=3 の場合、各バケットの幅は約9% (2^(1/8) ≒ 1.09 倍) となる。
+//
+// NOTE: Prometheus の /metrics (テキスト形式) では指数ヒストグラムのバケットを表現できないため、
+// Config.HistogramAggregation は PeriodicReader (stdout / OTLP / file) のみに適用し、Prometheus 側は明示的なバケット境界のまま集約する。
+// MetricView で指定した場合は全ての Reader に適用される。
+const AggregationBase2ExponentialHistogram AggregationType = "base2_exponential_bucket_histogram"
+
+// ExponentialHistogramConfig は Base2 指数ヒストグラムの設定
+type ExponentialHistogramConfig struct {
+	// MaxSize は正・負それぞれのバケット数の上限。0 の場合は160。
+	// 記録された値の範囲がバケット数に収まらない場合は scale を下げて (バケットを粗くして) 収める
+	MaxSize int32
+
+	// MaxScale は scale (解像度) の上限 (-10〜20)。nil の場合は20
+	// NOTE: 0 も有効な scale (各バケットの幅が2倍) であるため、未指定と区別できるようポインタで指定する
+	MaxScale *int32
+}
+
+const (
+	defaultExponentialHistogramMaxSize  = 160
+	defaultExponentialHistogramMaxScale = 20
+)
+
+// aggregation は ExponentialHistogramConfig を sdkmetric.Aggregation に変換する
+func (c ExponentialHistogramConfig) aggregation() (sdkmetric.Aggregation, error) {
+	if c.MaxSize <= 0 {
+		c.MaxSize = defaultExponentialHistogramMaxSize
+	}
+	maxScale := int32(defaultExponentialHistogramMaxScale)
+	if c.MaxScale != nil {
+		maxScale = *c.MaxScale
+	}
+	if maxScale < -10 || maxScale > 20 {
+		return nil, fmt.Errorf("otel: exponential histogram max scale must be between -10 and 20, got %d", maxScale)
+	}
+	return sdkmetric.AggregationBase2ExponentialHistogram{
+		MaxSize:  c.MaxSize,
+		MaxScale: maxScale,
+	}, nil
+}
+
+// histogramAggregationExporter はヒストグラム計器のデフォルトの集約方法を差し替える sdkmetric.Exporter
+//
+// NOTE: PeriodicReader は Exporter.Aggregation で計器の種類ごとの集約方法を決定する。
+// View で集約方法を指定した計器には適用されず、View の設定が優先される。
+type histogramAggregationExporter struct {
+	sdkmetric.Exporter
+	histogram sdkmetric.Aggregation
+}
+
+// Aggregation はヒストグラム計器の場合は差し替えた集約方法を返し、それ以外は元のエクスポーターに委譲する
+func (e *histogramAggregationExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	if kind == sdkmetric.InstrumentKindHistogram {
+		return e.histogram
+	}
+	return e.Exporter.Aggregation(kind)
+}
+
+// withHistogramAggregation は typ に応じてヒストグラム計器のデフォルトの集約方法を差し替えたエクスポーターを返す
+func withHistogramAggregation(exp sdkmetric.Exporter, typ AggregationType, cfg ExponentialHistogramConfig) (sdkmetric.Exporter, error) {
+	switch typ {
+	case "", AggregationExplicitBucketHistogram:
+		return exp, nil
+	case AggregationBase2ExponentialHistogram:
+		agg, err := cfg.aggregation()
+		if err != nil {
+			return nil, err
+		}
+		return &histogramAggregationExporter{Exporter: exp, histogram: agg}, nil
+	default:
+		return nil, fmt.Errorf("otel: unsupported histogram aggregation %q", typ)
+	}
+}
+
+// exponentialBucket は指数ヒストグラムの1バケット
+//
+// NOTE: 正のバケットは (LowerBound, UpperBound]、負のバケットは [LowerBound, UpperBound) の範囲の値を数える。
+// ZeroThreshold 以内の値は ZeroCount に数えられ、Buckets には含まれない。
+type exponentialBucket struct {
+	LowerBound float64
+	UpperBound float64
+	Count      uint64
+}
+
+// readableExponentialHistogram は各データポイントに Buckets を付与し、Exemplar を読める形式に変換した指数ヒストグラムを返す
+func readableExponentialHistogram[N int64 | float64](h metricdata.ExponentialHistogram[N]) any {
+	type dataPoint struct {
+		metricdata.ExponentialHistogramDataPoint[N]
+		Buckets   []exponentialBucket
+		Exemplars []readableExemplar[N] `json:",omitempty"`
+	}
+	points := make([]dataPoint, 0, len(h.DataPoints))
+	for _, dp := range h.DataPoints {
+		points = append(points, dataPoint{
+			ExponentialHistogramDataPoint: dp,
+			Buckets:                       exponentialBuckets(dp.Scale, dp.NegativeBucket, dp.PositiveBucket),
+			Exemplars:                     readableExemplars(dp.Exemplars),
+		})
+	}
+	return struct {
+		DataPoints  []dataPoint
+		Temporality metricdata.Temporality
+	}{DataPoints: points, Temporality: h.Temporality}
+}
+
+// exponentialBuckets はカウントが1以上のバケットの範囲を小さい値から順に返す
+//
+// バケット番号 i の正のバケットは (base^i, base^(i+1)] の範囲となる (base = 2^(2^-scale))。
+func exponentialBuckets(scale int32, negative, positive metricdata.ExponentialBucket) []exponentialBucket {
+	bound := func(i int) float64 {
+		return math.Exp2(float64(i) * math.Exp2(-float64(scale)))
+	}
+
+	var buckets []exponentialBucket
+	for i := len(negative.Counts) - 1; i >= 0; i-- {
+		if negative.Counts[i] == 0 {
+			continue
+		}
+		index := int(negative.Offset) + i
+		buckets = append(buckets, exponentialBucket{
+			LowerBound: -bound(index + 1),
+			UpperBound: -bound(index),
+			Count:      negative.Counts[i],
+		})
+	}
+	for i, count := range positive.Counts {
+		if count == 0 {
+			continue
+		}
+		index := int(positive.Offset) + i
+		buckets = append(buckets, exponentialBucket{
+			LowerBound: bound(index),
+			UpperBound: bound(index + 1),
+			Count:      count,
+		})
+	}
+	return buckets
+}
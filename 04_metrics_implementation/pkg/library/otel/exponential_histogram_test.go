@@ -0,0 +1,42 @@
+package otel
+
+import (
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestExponentialHistogramMaxScale(t *testing.T) {
+	scale := func(v int32) *int32 { return &v }
+
+	tests := []struct {
+		name     string
+		maxScale *int32
+		want     int32
+		wantErr  bool
+	}{
+		{name: "unset defaults to 20", maxScale: nil, want: 20},
+		{name: "zero is configurable", maxScale: scale(0), want: 0},
+		{name: "negative scale", maxScale: scale(-10), want: -10},
+		{name: "below the minimum", maxScale: scale(-11), wantErr: true},
+		{name: "above the maximum", maxScale: scale(21), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg, err := ExponentialHistogramConfig{MaxScale: tt.maxScale}.aggregation()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := agg.(sdkmetric.AggregationBase2ExponentialHistogram)
+			if got.MaxScale != tt.want || got.MaxSize != defaultExponentialHistogramMaxSize {
+				t.Errorf("aggregation = %+v, want MaxScale %d and the default MaxSize", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package otel
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ExporterType はテレメトリの送信先の種類
+type ExporterType string
+
+const (
+	// ExporterStdout は標準出力に pretty print で出力する (開発環境向け、未指定時のデフォルト)
+	ExporterStdout ExporterType = "stdout"
+	// ExporterConsoleTree はスパンをトレースごとのツリーとウォーターフォールで標準出力に出力する (トレースのみ対応、開発環境向け)
+	ExporterConsoleTree ExporterType = "console-tree"
+	// ExporterOTLPGRPC は OTLP/gRPC で Collector に送信する (本番環境向け)
+	ExporterOTLPGRPC ExporterType = "otlp-grpc"
+	// ExporterOTLPHTTP は OTLP/HTTP で Collector に送信する (HTTP の egress しか許可されていない環境向け)
+	ExporterOTLPHTTP ExporterType = "otlp-http"
+	// ExporterFile はローテーション付きのファイルに JSON Lines 形式で出力する (オフラインでのデバッグ向け)
+	ExporterFile ExporterType = "file"
+	// ExporterZipkin は Zipkin v2 の JSON 形式で送信する (トレースのみ対応、Zipkin を運用しているチームとの共有向け)
+	ExporterZipkin ExporterType = "zipkin"
+	// ExporterNone はエクスポートしない (そのシグナルを無効化する、ログの未指定時のデフォルト)
+	ExporterNone ExporterType = "none"
+)
+
+// OTLPEncoding は OTLP/HTTP のペイロードのエンコーディング
+type OTLPEncoding string
+
+const (
+	// OTLPEncodingProtobuf は application/x-protobuf で送信する (未指定時のデフォルト)
+	OTLPEncodingProtobuf OTLPEncoding = "protobuf"
+	// OTLPEncodingJSON は application/json で送信する (プロキシでペイロードを確認したい場合等)
+	OTLPEncodingJSON OTLPEncoding = "json"
+)
+
+// ExporterConfig はシグナル (トレース / メトリクス / ログ) ごとのエクスポーター設定
+type ExporterConfig struct {
+	// Type はエクスポーターの種類。空文字の場合はトレース・メトリクスは ExporterStdout、ログは ExporterNone として扱う
+	Type ExporterType
+
+	// OTLP は Type が OTLP 系の場合に使用する接続設定
+	OTLP OTLPConfig
+
+	// File は Type が ExporterFile の場合に使用する出力先の設定
+	File FileConfig
+
+	// Zipkin は Type が ExporterZipkin の場合に使用する接続設定
+	Zipkin ZipkinConfig
+}
+
+// OTLPConfig は OTLP エクスポーターの接続設定
+type OTLPConfig struct {
+	// Endpoint は送信先の host:port (例: "otel-collector:4317")。空の場合は SDK デフォルト (gRPC: localhost:4317, HTTP: localhost:4318)
+	Endpoint string
+
+	// URLPath は OTLP/HTTP の送信先パス。空の場合は SDK デフォルト (/v1/traces, /v1/metrics)
+	URLPath string
+
+	// Encoding は OTLP/HTTP のペイロード形式。空の場合は OTLPEncodingProtobuf。gRPC では無視される
+	Encoding OTLPEncoding
+
+	// Headers は全リクエストに付与するヘッダー (gRPC の場合は metadata)。認証トークン等に使用する
+	Headers map[string]string
+
+	// Insecure が true の場合は TLS を使用せず平文で接続する (Collector がサイドカーの場合等)
+	Insecure bool
+
+	// CAFile はサーバー証明書の検証に使用する CA 証明書 (PEM) のパス。空の場合はシステムの証明書プールを使用する
+	CAFile string
+	// ClientCertFile / ClientKeyFile は mTLS のクライアント証明書と秘密鍵 (PEM) のパス
+	ClientCertFile string
+	ClientKeyFile  string
+
+	// Compression は送信時の圧縮方式 ("gzip" または "none")。空の場合は圧縮しない
+	Compression string
+
+	// Timeout は1回のエクスポートのタイムアウト。0 の場合は SDK デフォルト (10秒)
+	Timeout time.Duration
+}
+
+// tlsConfig は CAFile / ClientCertFile / ClientKeyFile から tls.Config を生成する
+func (c OTLPConfig) tlsConfig() (*tls.Config, error) {
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("otel: read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("otel: no valid certificates in CA file %q", c.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if c.ClientCertFile != "" || c.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.ClientCertFile, c.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("otel: load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsCfg, nil
+}
+
+// validateCompression は Compression の値を検証する
+func (c OTLPConfig) validateCompression() error {
+	switch c.Compression {
+	case "", "none", "gzip":
+		return nil
+	default:
+		return fmt.Errorf("otel: unsupported OTLP compression %q", c.Compression)
+	}
+}
+
+// newTraceExporter は ExporterConfig に応じた SpanExporter を生成する
+//
+// queue が nil でない場合、OTLP の送信に一時的なエラーで失敗したリクエストを queue に退避する。
+func newTraceExporter(ctx context.Context, cfg ExporterConfig, queue *persistentQueue) (sdktrace.SpanExporter, error) {
+	switch cfg.Type {
+	case "", ExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterConsoleTree:
+		return newConsoleTreeExporter(os.Stdout), nil
+	case ExporterOTLPGRPC:
+		opts, err := otlpTraceGRPCOptions(cfg.OTLP, queue)
+		if err != nil {
+			return nil, err
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		opts, err := otlpTraceHTTPOptions(cfg.OTLP, queue)
+		if err != nil {
+			return nil, err
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case ExporterFile:
+		return newFileSpanExporter(cfg.File)
+	case ExporterZipkin:
+		return newZipkinExporter(cfg.Zipkin)
+	default:
+		return nil, fmt.Errorf("otel: unsupported trace exporter %q", cfg.Type)
+	}
+}
+
+// newMetricExporter は ExporterConfig に応じた metric Exporter を生成する
+//
+// queue が nil でない場合、OTLP の送信に一時的なエラーで失敗したリクエストを queue に退避する。
+func newMetricExporter(ctx context.Context, cfg ExporterConfig, queue *persistentQueue) (sdkmetric.Exporter, error) {
+	switch cfg.Type {
+	case "", ExporterStdout:
+		// NOTE: 指数ヒストグラムのバケットの範囲を読めるよう、独自の Encoder で出力する (WithPrettyPrint 相当のインデント付き)
+		return stdoutmetric.New(stdoutmetric.WithEncoder(newReadableMetricEncoder(os.Stdout)))
+	case ExporterOTLPGRPC:
+		opts, err := otlpMetricGRPCOptions(cfg.OTLP, queue)
+		if err != nil {
+			return nil, err
+		}
+		return otlpmetricgrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		opts, err := otlpMetricHTTPOptions(cfg.OTLP, queue)
+		if err != nil {
+			return nil, err
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	case ExporterFile:
+		return newFileMetricExporter(cfg.File)
+	default:
+		return nil, fmt.Errorf("otel: unsupported metric exporter %q", cfg.Type)
+	}
+}
+
+// otlpTraceGRPCOptions は OTLPConfig を otlptracegrpc のオプションに変換する
+func otlpTraceGRPCOptions(c OTLPConfig, queue *persistentQueue) ([]otlptracegrpc.Option, error) {
+	if err := c.validateCompression(); err != nil {
+		return nil, err
+	}
+
+	var opts []otlptracegrpc.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlptracegrpc.WithEndpoint(c.Endpoint))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracegrpc.WithHeaders(c.Headers))
+	}
+	creds := insecure.NewCredentials()
+	if c.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	} else {
+		tlsCfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsCfg)
+		opts = append(opts, otlptracegrpc.WithTLSCredentials(creds))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlptracegrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlptracegrpc.WithTimeout(c.Timeout))
+	}
+	if queue != nil {
+		dialOpt, err := queue.grpcDialOption(creds)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlptracegrpc.WithDialOption(dialOpt))
+	}
+	return opts, nil
+}
+
+// otlpMetricGRPCOptions は OTLPConfig を otlpmetricgrpc のオプションに変換する
+func otlpMetricGRPCOptions(c OTLPConfig, queue *persistentQueue) ([]otlpmetricgrpc.Option, error) {
+	if err := c.validateCompression(); err != nil {
+		return nil, err
+	}
+
+	var opts []otlpmetricgrpc.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlpmetricgrpc.WithEndpoint(c.Endpoint))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlpmetricgrpc.WithHeaders(c.Headers))
+	}
+	creds := insecure.NewCredentials()
+	if c.Insecure {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	} else {
+		tlsCfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsCfg)
+		opts = append(opts, otlpmetricgrpc.WithTLSCredentials(creds))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlpmetricgrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlpmetricgrpc.WithTimeout(c.Timeout))
+	}
+	if queue != nil {
+		dialOpt, err := queue.grpcDialOption(creds)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlpmetricgrpc.WithDialOption(dialOpt))
+	}
+	return opts, nil
+}
@@ -0,0 +1,375 @@
+package otel
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+
+	_ "google.golang.org/grpc/encoding/gzip" // NOTE: 受信側で gzip を展開できるよう登録する
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collectormetrics "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// grpcRequest は受信側で記録した1回のエクスポートリクエスト
+type grpcRequest struct {
+	metadata    metadata.MD
+	compression string
+	clientCerts int // NOTE: TLS で検証済みのクライアント証明書の数 (平文の場合は -1)
+	items       int
+}
+
+// grpcReceiver はトレースとメトリクスを受信する OTLP/gRPC のテスト用サーバー
+type grpcReceiver struct {
+	collectortrace.UnimplementedTraceServiceServer
+
+	addr string
+
+	mu          sync.Mutex
+	requests    []grpcRequest
+	compression map[string]string // NOTE: メソッド名 → stats.InHeader で受信した grpc-encoding
+}
+
+// startGRPCReceiver は 127.0.0.1 の空きポートで grpcReceiver を起動する (creds が nil の場合は平文)
+func startGRPCReceiver(t *testing.T, creds credentials.TransportCredentials) *grpcReceiver {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &grpcReceiver{addr: lis.Addr().String(), compression: make(map[string]string)}
+	opts := []grpc.ServerOption{grpc.StatsHandler(r)}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	collectortrace.RegisterTraceServiceServer(srv, r)
+	collectormetrics.RegisterMetricsServiceServer(srv, metricsServer{r: r})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return r
+}
+
+func (r *grpcReceiver) Export(ctx context.Context, req *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	n := 0
+	for _, rs := range req.GetResourceSpans() {
+		for _, ss := range rs.GetScopeSpans() {
+			n += len(ss.GetSpans())
+		}
+	}
+	r.record(ctx, "/opentelemetry.proto.collector.trace.v1.TraceService/Export", n)
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// metricsServer は MetricsService の Export を grpcReceiver に委譲する (TraceService と同名のメソッドのため分離)
+type metricsServer struct {
+	collectormetrics.UnimplementedMetricsServiceServer
+	r *grpcReceiver
+}
+
+func (s metricsServer) Export(ctx context.Context, req *collectormetrics.ExportMetricsServiceRequest) (*collectormetrics.ExportMetricsServiceResponse, error) {
+	n := 0
+	for _, rm := range req.GetResourceMetrics() {
+		for _, sm := range rm.GetScopeMetrics() {
+			n += len(sm.GetMetrics())
+		}
+	}
+	s.r.record(ctx, "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export", n)
+	return &collectormetrics.ExportMetricsServiceResponse{}, nil
+}
+
+func (r *grpcReceiver) record(ctx context.Context, method string, items int) {
+	req := grpcRequest{items: items, clientCerts: -1}
+	req.metadata, _ = metadata.FromIncomingContext(ctx)
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			req.clientCerts = len(info.State.VerifiedChains)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	req.compression = r.compression[method]
+	r.requests = append(r.requests, req)
+}
+
+func (r *grpcReceiver) received() []grpcRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]grpcRequest(nil), r.requests...)
+}
+
+// NOTE: 圧縮方式は metadata からは取得できないため、stats.Handler で受信ヘッダーを記録する
+func (r *grpcReceiver) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context { return ctx }
+func (r *grpcReceiver) HandleRPC(_ context.Context, s stats.RPCStats) {
+	if h, ok := s.(*stats.InHeader); ok {
+		r.mu.Lock()
+		r.compression[h.FullMethod] = h.Compression
+		r.mu.Unlock()
+	}
+}
+func (r *grpcReceiver) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context { return ctx }
+func (r *grpcReceiver) HandleConn(context.Context, stats.ConnStats)                       {}
+
+// testCerts はテスト用に生成した CA・サーバー証明書・クライアント証明書のファイル
+type testCerts struct {
+	caFile, clientCertFile, clientKeyFile string
+	server                                tls.Certificate
+	pool                                  *x509.CertPool
+}
+
+// newTestCerts は 127.0.0.1 用のサーバー証明書と mTLS 用のクライアント証明書を CA で署名して生成する
+func newTestCerts(t *testing.T) testCerts {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issue := func(serial int64, name string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	}
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	serverCert, serverKey := issue(2, "127.0.0.1", x509.ExtKeyUsageServerAuth)
+	clientCert, clientKey := issue(3, "client", x509.ExtKeyUsageClientAuth)
+	server, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(caCert)
+	return testCerts{
+		caFile:         write("ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		clientCertFile: write("client.pem", clientCert),
+		clientKeyFile:  write("client-key.pem", clientKey),
+		server:         server,
+		pool:           pool,
+	}
+}
+
+func TestOTLPGRPCExporters(t *testing.T) {
+	certs := newTestCerts(t)
+	mtls := credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{certs.server},
+		ClientCAs:    certs.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+
+	tests := []struct {
+		name            string
+		creds           credentials.TransportCredentials
+		otlp            func(addr string) OTLPConfig
+		wantCompression string
+		wantClientCerts int
+	}{
+		{
+			name:  "insecure with headers and gzip",
+			creds: nil,
+			otlp: func(addr string) OTLPConfig {
+				return OTLPConfig{
+					Endpoint:    addr,
+					Insecure:    true,
+					Headers:     map[string]string{"authorization": "Bearer token", "x-tenant": "acme"},
+					Compression: "gzip",
+				}
+			},
+			wantCompression: "gzip",
+			wantClientCerts: -1,
+		},
+		{
+			name:  "mTLS with CA file",
+			creds: mtls,
+			otlp: func(addr string) OTLPConfig {
+				return OTLPConfig{
+					Endpoint:       addr,
+					Headers:        map[string]string{"authorization": "Bearer token", "x-tenant": "acme"},
+					CAFile:         certs.caFile,
+					ClientCertFile: certs.clientCertFile,
+					ClientKeyFile:  certs.clientKeyFile,
+				}
+			},
+			wantCompression: "",
+			wantClientCerts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			r := startGRPCReceiver(t, tt.creds)
+			cfg := ExporterConfig{Type: ExporterOTLPGRPC, OTLP: tt.otlp(r.addr)}
+
+			spanExp, err := newTraceExporter(ctx, cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExp))
+			_, span := tp.Tracer("test").Start(ctx, "span")
+			span.End()
+			if err := tp.Shutdown(ctx); err != nil {
+				t.Fatalf("trace export: %v", err)
+			}
+
+			metricExp, err := newMetricExporter(ctx, cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			reader := sdkmetric.NewPeriodicReader(metricExp)
+			mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+			counter, err := mp.Meter("test").Int64Counter("requests")
+			if err != nil {
+				t.Fatal(err)
+			}
+			counter.Add(ctx, 1)
+			if err := mp.Shutdown(ctx); err != nil {
+				t.Fatalf("metric export: %v", err)
+			}
+
+			got := r.received()
+			if len(got) != 2 {
+				t.Fatalf("received %d requests, want 2 (trace and metric)", len(got))
+			}
+			for _, req := range got {
+				if req.items != 1 {
+					t.Errorf("items = %d, want 1", req.items)
+				}
+				if v := req.metadata.Get("authorization"); len(v) != 1 || v[0] != "Bearer token" {
+					t.Errorf("authorization metadata = %v, want [Bearer token]", v)
+				}
+				if v := req.metadata.Get("x-tenant"); len(v) != 1 || v[0] != "acme" {
+					t.Errorf("x-tenant metadata = %v, want [acme]", v)
+				}
+				if req.compression != tt.wantCompression {
+					t.Errorf("compression = %q, want %q", req.compression, tt.wantCompression)
+				}
+				if req.clientCerts != tt.wantClientCerts {
+					t.Errorf("verified client certificate chains = %d, want %d", req.clientCerts, tt.wantClientCerts)
+				}
+			}
+		})
+	}
+}
+
+func TestOTLPGRPCExporterRejectsUntrustedServer(t *testing.T) {
+	certs := newTestCerts(t)
+	other := newTestCerts(t) // NOTE: 別の CA で署名されたサーバー証明書
+	r := startGRPCReceiver(t, credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{other.server}}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	exp, err := newTraceExporter(ctx, ExporterConfig{
+		Type: ExporterOTLPGRPC,
+		OTLP: OTLPConfig{Endpoint: r.addr, CAFile: certs.caFile, Timeout: 2 * time.Second},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exp))
+	_, span := tp.Tracer("test").Start(ctx, "span")
+	span.End()
+	_ = tp.Shutdown(ctx)
+
+	if got := r.received(); len(got) != 0 {
+		t.Fatalf("received %d requests from a client that should not trust the server", len(got))
+	}
+}
+
+func TestOTLPConfigValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  OTLPConfig
+	}{
+		{name: "unsupported compression", cfg: OTLPConfig{Compression: "zstd"}},
+		{name: "missing CA file", cfg: OTLPConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{name: "CA file without certificates", cfg: OTLPConfig{CAFile: writeTempFile(t, "ca.pem", "not a certificate")}},
+		{name: "client certificate without key", cfg: OTLPConfig{ClientCertFile: writeTempFile(t, "client.pem", "")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := otlpTraceGRPCOptions(tt.cfg, nil); err == nil {
+				t.Error("otlpTraceGRPCOptions: expected error")
+			}
+			if _, err := otlpMetricGRPCOptions(tt.cfg, nil); err == nil {
+				t.Error("otlpMetricGRPCOptions: expected error")
+			}
+		})
+	}
+}
+
+// writeTempFile はテスト用の一時ファイルを作成してパスを返す
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,71 @@
+package otel
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NOTE: ファイルエクスポーターは stdouttrace / stdoutmetric の出力先を rotatingFile に差し替えたもの。
+// WithPrettyPrint を指定しないことで、スパンは1件ごと、メトリクスはエクスポート1回ごとに1行の JSON として出力される (JSON Lines)。
+// stdout の Shutdown は出力先を閉じないため、ラップしてファイルの同期とクローズを行う。
+
+// fileSpanExporter は JSON Lines 形式でスパンをファイルに出力する sdktrace.SpanExporter
+type fileSpanExporter struct {
+	sdktrace.SpanExporter
+	file *rotatingFile
+}
+
+// newFileSpanExporter は fileSpanExporter を生成する
+func newFileSpanExporter(cfg FileConfig) (sdktrace.SpanExporter, error) {
+	file, err := newRotatingFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &fileSpanExporter{SpanExporter: exp, file: file}, nil
+}
+
+// Shutdown はエクスポーターを停止してからファイルを閉じる
+func (e *fileSpanExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.file.Close())
+}
+
+// fileMetricExporter は JSON Lines 形式でメトリクスをファイルに出力する sdkmetric.Exporter
+type fileMetricExporter struct {
+	sdkmetric.Exporter
+	file *rotatingFile
+}
+
+// newFileMetricExporter は fileMetricExporter を生成する
+func newFileMetricExporter(cfg FileConfig) (sdkmetric.Exporter, error) {
+	file, err := newRotatingFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := stdoutmetric.New(stdoutmetric.WithWriter(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &fileMetricExporter{Exporter: exp, file: file}, nil
+}
+
+// ForceFlush は書き込み済みのデータをディスクに同期する
+func (e *fileMetricExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.Exporter.ForceFlush(ctx), e.file.Sync())
+}
+
+// Shutdown はエクスポーターを停止してからファイルを閉じる
+func (e *fileMetricExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.file.Close())
+}
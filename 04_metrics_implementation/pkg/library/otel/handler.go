@@ -2,25 +2,78 @@ package otel
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/log"
 	"go.opentelemetry.io/otel/trace"
 )
 
 // OTELHandler は slog.Handler をラップし、trace_id / span_id を自動注入する
+//
+// WithLoggerProvider を指定した場合は、同じログレコードを OTel の LogRecord としても LoggerProvider に送信する。
 type OTELHandler struct {
 	slog.Handler
+
+	// bridge は slog.Record を OTel の LogRecord に変換して LoggerProvider に送信するハンドラ (未指定時は nil)
+	bridge slog.Handler
+
+	// redactor はログの属性から個人情報を取り除く (未指定時は nil)
+	redactor *Redactor
+
+	// baggageKeys はログの属性として追加する Baggage のメンバー
+	baggageKeys []string
+}
+
+// HandlerOption は OTELHandler のオプション
+type HandlerOption func(*OTELHandler)
+
+// WithLoggerProvider は slog のログを OTel の LogRecord として lp にも送信する
+//
+// LogRecord には ctx のトレースコンテキスト (trace_id / span_id)、slog のレベルに対応する Severity、属性がそのまま引き継がれる。
+func WithLoggerProvider(lp log.LoggerProvider) HandlerOption {
+	return func(h *OTELHandler) {
+		h.bridge = otelslog.NewHandler(instrumentationName, otelslog.WithLoggerProvider(lp))
+	}
+}
+
+// WithRedactor はログの属性に r のルールを適用してから出力・送信する
+//
+// Provider.Redactor を渡すと、スパンと同じルールがログにも適用される。r が nil の場合は何もしない。
+func WithRedactor(r *Redactor) HandlerOption {
+	return func(h *OTELHandler) {
+		h.redactor = r
+	}
 }
 
 // NewOTELHandler は OTELHandler を生成する
-func NewOTELHandler(h slog.Handler) *OTELHandler {
-	return &OTELHandler{Handler: h}
+func NewOTELHandler(h slog.Handler, opts ...HandlerOption) *OTELHandler {
+	handler := &OTELHandler{Handler: h}
+	for _, opt := range opts {
+		opt(handler)
+	}
+	return handler
 }
 
 // Handle はログレコードに trace_id / span_id を追加してから内部ハンドラに委譲する
 //
 // NOTE: ロジック中の slog.InfoContext などが実行された場合、このメソッドが呼び出される
 func (h *OTELHandler) Handle(ctx context.Context, r slog.Record) error {
+	// NOTE: Baggage のメンバーも置き換えの対象とするため、先に追加する
+	if attrs := baggageLogAttrs(ctx, h.baggageKeys); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	// NOTE: 出力先と LoggerProvider の両方に同じ置き換え後のレコードを渡す
+	r = h.redactor.record(r)
+
+	// NOTE: LogRecord はトレースコンテキストを専用のフィールドで持つため、trace_id / span_id 属性を追加する前のレコードを渡す
+	var bridgeErr error
+	if h.bridge != nil && h.bridge.Enabled(ctx, r.Level) {
+		bridgeErr = h.bridge.Handle(ctx, r.Clone())
+	}
+
 	spanCtx := trace.SpanContextFromContext(ctx)
 	// ctx から trace_id, span_id を抽出し、ログの構造体に追加
 	if spanCtx.IsValid() {
@@ -29,15 +82,24 @@ func (h *OTELHandler) Handle(ctx context.Context, r slog.Record) error {
 			slog.String("span_id", spanCtx.SpanID().String()),
 		)
 	}
-	return h.Handler.Handle(ctx, r)
+	return errors.Join(h.Handler.Handle(ctx, r), bridgeErr)
 }
 
 // WithAttrs はラップされたハンドラに属性を追加した新しい OTELHandler を返す
 func (h *OTELHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	return &OTELHandler{Handler: h.Handler.WithAttrs(attrs)}
+	attrs = h.redactor.slogAttrs(attrs)
+	handler := &OTELHandler{Handler: h.Handler.WithAttrs(attrs), redactor: h.redactor, baggageKeys: h.baggageKeys}
+	if h.bridge != nil {
+		handler.bridge = h.bridge.WithAttrs(attrs)
+	}
+	return handler
 }
 
 // WithGroup はラップされたハンドラにグループを追加した新しい OTELHandler を返す
 func (h *OTELHandler) WithGroup(name string) slog.Handler {
-	return &OTELHandler{Handler: h.Handler.WithGroup(name)}
+	handler := &OTELHandler{Handler: h.Handler.WithGroup(name), redactor: h.redactor, baggageKeys: h.baggageKeys}
+	if h.bridge != nil {
+		handler.bridge = h.bridge.WithGroup(name)
+	}
+	return handler
 }
@@ -0,0 +1,159 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/log"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// logRecorder は受け取った LogRecord をメモリに保持する sdklog.Exporter
+type logRecorder struct {
+	mu      sync.Mutex
+	records []sdklog.Record
+}
+
+// Export は records を保持する
+func (e *logRecorder) Export(_ context.Context, records []sdklog.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, r := range records {
+		e.records = append(e.records, r.Clone())
+	}
+	return nil
+}
+
+// Shutdown は何もしない
+func (e *logRecorder) Shutdown(context.Context) error { return nil }
+
+// ForceFlush は何もしない
+func (e *logRecorder) ForceFlush(context.Context) error { return nil }
+
+// get は保持している LogRecord を返す
+func (e *logRecorder) get() []sdklog.Record {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]sdklog.Record(nil), e.records...)
+}
+
+// newTestLogger は JSON を buf に出力し、LogRecord を recorder に送信する OTELHandler の Logger を返す
+func newTestLogger(t *testing.T, opts ...HandlerOption) (*slog.Logger, *bytes.Buffer, *logRecorder) {
+	t.Helper()
+	recorder := &logRecorder{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(sdklog.NewSimpleProcessor(recorder)))
+	t.Cleanup(func() { _ = lp.Shutdown(context.Background()) })
+
+	var buf bytes.Buffer
+	h := NewOTELHandler(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}), append([]HandlerOption{WithLoggerProvider(lp)}, opts...)...)
+	return slog.New(h), &buf, recorder
+}
+
+// recordAttrs は LogRecord の属性を key → 値の map で返す
+func recordAttrs(r sdklog.Record) map[string]log.Value {
+	attrs := make(map[string]log.Value)
+	r.WalkAttributes(func(kv log.KeyValue) bool {
+		attrs[kv.Key] = kv.Value
+		return true
+	})
+	return attrs
+}
+
+// jsonLines は JSON Lines の出力を map のスライスで返す
+func jsonLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var m map[string]any
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("invalid JSON line %s: %v", line, err)
+		}
+		lines = append(lines, m)
+	}
+	return lines
+}
+
+func TestOTELHandlerEmitsLogRecords(t *testing.T) {
+	logger, buf, recorder := newTestLogger(t)
+
+	tp := sdktrace.NewTracerProvider()
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	ctx, span := tp.Tracer("test").Start(context.Background(), "handler")
+	defer span.End()
+	sc := span.SpanContext()
+
+	tests := []struct {
+		level    slog.Level
+		severity log.Severity
+	}{
+		{level: slog.LevelDebug, severity: log.SeverityDebug},
+		{level: slog.LevelInfo, severity: log.SeverityInfo},
+		{level: slog.LevelWarn, severity: log.SeverityWarn},
+		{level: slog.LevelError, severity: log.SeverityError},
+	}
+	for _, tt := range tests {
+		logger.Log(ctx, tt.level, "article fetched", slog.String("article.id", "article-123"), slog.Int("status", 200))
+	}
+
+	records := recorder.get()
+	if len(records) != len(tests) {
+		t.Fatalf("exported %d log records, want %d", len(records), len(tests))
+	}
+	for i, tt := range tests {
+		r := records[i]
+		if r.Severity() != tt.severity {
+			t.Errorf("%s: severity = %v, want %v", tt.level, r.Severity(), tt.severity)
+		}
+		if r.TraceID() != sc.TraceID() || r.SpanID() != sc.SpanID() {
+			t.Errorf("%s: trace context = %s/%s, want %s/%s", tt.level, r.TraceID(), r.SpanID(), sc.TraceID(), sc.SpanID())
+		}
+		if r.Body().AsString() != "article fetched" {
+			t.Errorf("%s: body = %v, want the message", tt.level, r.Body())
+		}
+		attrs := recordAttrs(r)
+		if attrs["article.id"].AsString() != "article-123" || attrs["status"].AsInt64() != 200 {
+			t.Errorf("%s: attributes = %v, want article.id and status", tt.level, attrs)
+		}
+		// NOTE: LogRecord はトレースコンテキストを専用のフィールドで持つため、trace_id / span_id 属性は付与しない
+		if _, ok := attrs["trace_id"]; ok {
+			t.Errorf("%s: LogRecord has a trace_id attribute", tt.level)
+		}
+	}
+
+	// NOTE: slog の JSON 出力には trace_id / span_id 属性が付与される
+	lines := jsonLines(t, buf)
+	if len(lines) != len(tests) {
+		t.Fatalf("wrote %d JSON lines, want %d", len(lines), len(tests))
+	}
+	if lines[0]["trace_id"] != sc.TraceID().String() || lines[0]["span_id"] != sc.SpanID().String() {
+		t.Errorf("JSON line = %v, want trace_id and span_id of the active span", lines[0])
+	}
+}
+
+func TestOTELHandlerWithoutSpan(t *testing.T) {
+	logger, buf, recorder := newTestLogger(t)
+	logger.WithGroup("request").With(slog.String("method", "GET")).InfoContext(context.Background(), "no span")
+
+	records := recorder.get()
+	if len(records) != 1 {
+		t.Fatalf("exported %d log records, want 1", len(records))
+	}
+	if records[0].TraceID().IsValid() || records[0].SpanID().IsValid() {
+		t.Errorf("trace context = %s/%s, want empty", records[0].TraceID(), records[0].SpanID())
+	}
+	if got := recordAttrs(records[0])["request"]; got.Kind() != log.KindMap {
+		t.Errorf("request attribute = %v, want a group", got)
+	}
+	if line := jsonLines(t, buf)[0]; line["trace_id"] != nil {
+		t.Errorf("JSON line = %v, want no trace_id", line)
+	}
+}
@@ -0,0 +1,161 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+)
+
+// newLogExporter は ExporterConfig に応じたログの Exporter を生成する
+//
+// NOTE: slog の JSON 出力が既に標準出力に流れているため、ログは未指定 (空文字) の場合は ExporterNone として扱い、
+// OTel LogRecord としてはエクスポートしない。この場合は nil を返す。
+// queue が nil でない場合、OTLP の送信に一時的なエラーで失敗したリクエストを queue に退避する。
+func newLogExporter(ctx context.Context, cfg ExporterConfig, queue *persistentQueue) (sdklog.Exporter, error) {
+	switch cfg.Type {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return stdoutlog.New(stdoutlog.WithPrettyPrint())
+	case ExporterOTLPGRPC:
+		opts, err := otlpLogGRPCOptions(cfg.OTLP, queue)
+		if err != nil {
+			return nil, err
+		}
+		return otlploggrpc.New(ctx, opts...)
+	case ExporterOTLPHTTP:
+		opts, err := otlpLogHTTPOptions(cfg.OTLP, queue)
+		if err != nil {
+			return nil, err
+		}
+		return otlploghttp.New(ctx, opts...)
+	case ExporterFile:
+		return newFileLogExporter(cfg.File)
+	default:
+		return nil, fmt.Errorf("otel: unsupported log exporter %q", cfg.Type)
+	}
+}
+
+// otlpLogGRPCOptions は OTLPConfig を otlploggrpc のオプションに変換する
+func otlpLogGRPCOptions(c OTLPConfig, queue *persistentQueue) ([]otlploggrpc.Option, error) {
+	if err := c.validateCompression(); err != nil {
+		return nil, err
+	}
+
+	var opts []otlploggrpc.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlploggrpc.WithEndpoint(c.Endpoint))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlploggrpc.WithHeaders(c.Headers))
+	}
+	creds := insecure.NewCredentials()
+	if c.Insecure {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	} else {
+		tlsCfg, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsCfg)
+		opts = append(opts, otlploggrpc.WithTLSCredentials(creds))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlploggrpc.WithCompressor("gzip"))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlploggrpc.WithTimeout(c.Timeout))
+	}
+	if queue != nil {
+		dialOpt, err := queue.grpcDialOption(creds)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, otlploggrpc.WithDialOption(dialOpt))
+	}
+	return opts, nil
+}
+
+// otlpLogHTTPOptions は OTLPConfig を otlploghttp のオプションに変換する
+func otlpLogHTTPOptions(c OTLPConfig, queue *persistentQueue) ([]otlploghttp.Option, error) {
+	if err := c.validateCompression(); err != nil {
+		return nil, err
+	}
+	if err := c.validateEncoding(); err != nil {
+		return nil, err
+	}
+	tlsCfg, err := c.httpTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []otlploghttp.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlploghttp.WithEndpoint(c.Endpoint))
+	}
+	if c.URLPath != "" {
+		opts = append(opts, otlploghttp.WithURLPath(c.URLPath))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlploghttp.WithHeaders(c.Headers))
+	}
+	if c.Insecure {
+		opts = append(opts, otlploghttp.WithInsecure())
+	} else {
+		opts = append(opts, otlploghttp.WithTLSClientConfig(tlsCfg))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlploghttp.WithCompression(otlploghttp.GzipCompression))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlploghttp.WithTimeout(c.Timeout))
+	}
+	if c.needsHTTPClient(queue) {
+		opts = append(opts, otlploghttp.WithHTTPClient(c.httpClient(tlsCfg, func() proto.Message {
+			return &collogspb.ExportLogsServiceRequest{}
+		}, func() proto.Message {
+			return &collogspb.ExportLogsServiceResponse{}
+		}, queue)))
+	}
+	return opts, nil
+}
+
+// fileLogExporter は JSON Lines 形式で LogRecord をファイルに出力する sdklog.Exporter
+type fileLogExporter struct {
+	sdklog.Exporter
+	file *rotatingFile
+}
+
+// newFileLogExporter は fileLogExporter を生成する
+func newFileLogExporter(cfg FileConfig) (sdklog.Exporter, error) {
+	file, err := newRotatingFile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	exp, err := stdoutlog.New(stdoutlog.WithWriter(file))
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &fileLogExporter{Exporter: exp, file: file}, nil
+}
+
+// ForceFlush は書き込み済みのデータをディスクに同期する
+func (e *fileLogExporter) ForceFlush(ctx context.Context) error {
+	return errors.Join(e.Exporter.ForceFlush(ctx), e.file.Sync())
+}
+
+// Shutdown はエクスポーターを停止してからファイルを閉じる
+func (e *fileLogExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.Exporter.Shutdown(ctx), e.file.Close())
+}
@@ -0,0 +1,175 @@
+package otel
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+// readableMetricEncoder はメトリクスを人が読みやすい形式に変換して JSON 出力する stdoutmetric.Encoder
+//
+// stdoutmetric の標準の Encoder から以下を変更し、それ以外は同じ形式で出力する:
+//   - 指数ヒストグラム: カウントが1以上のバケットについて範囲を計算し、Buckets として追加する (exponential_histogram.go 参照)
+//   - Exemplar: TraceID / SpanID をバイト列 (Base64) ではなく、トレースのバックエンドやログと同じ16進数の文字列で出力する (exemplar.go 参照)
+//
+// 例: scale=2 で 0.3秒を1回記録した場合
+//
+//	"Buckets": [{"LowerBound": 0.2973, "UpperBound": 0.3535, "Count": 1}]
+//
+// 例: スパン内で Record した場合
+//
+//	"Exemplars": [{"FilteredAttributes": null, "Time": "...", "Value": 0.3, "TraceID": "4bf92f3577b34da6a3ce929d0e0e4736", "SpanID": "00f067aa0ba902b7"}]
+type readableMetricEncoder struct {
+	enc *json.Encoder
+}
+
+// newReadableMetricEncoder は w に出力する readableMetricEncoder を生成する
+func newReadableMetricEncoder(w io.Writer) *readableMetricEncoder {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return &readableMetricEncoder{enc: enc}
+}
+
+// Encode は v (*metricdata.ResourceMetrics) を JSON で出力する
+func (e *readableMetricEncoder) Encode(v any) error {
+	rm, ok := v.(*metricdata.ResourceMetrics)
+	if !ok {
+		return e.enc.Encode(v)
+	}
+
+	// NOTE: metricdata.Metrics.Data は SDK 外の型を代入できないため、同じフィールド名を持つ構造体に詰め替えて出力する
+	type metrics struct {
+		Name        string
+		Description string
+		Unit        string
+		Data        any
+	}
+	type scopeMetrics struct {
+		Scope   instrumentation.Scope
+		Metrics []metrics
+	}
+	out := struct {
+		Resource     *resource.Resource
+		ScopeMetrics []scopeMetrics
+	}{Resource: rm.Resource}
+
+	for _, sm := range rm.ScopeMetrics {
+		s := scopeMetrics{Scope: sm.Scope}
+		for _, m := range sm.Metrics {
+			s.Metrics = append(s.Metrics, metrics{
+				Name:        m.Name,
+				Description: m.Description,
+				Unit:        m.Unit,
+				Data:        readableData(m.Data),
+			})
+		}
+		out.ScopeMetrics = append(out.ScopeMetrics, s)
+	}
+	return e.enc.Encode(out)
+}
+
+// readableData は data を出力用の形式に変換する。変換の対象外の集約はそのまま返す
+func readableData(data metricdata.Aggregation) any {
+	switch d := data.(type) {
+	case metricdata.Gauge[int64]:
+		return readableGauge(d)
+	case metricdata.Gauge[float64]:
+		return readableGauge(d)
+	case metricdata.Sum[int64]:
+		return readableSum(d)
+	case metricdata.Sum[float64]:
+		return readableSum(d)
+	case metricdata.Histogram[int64]:
+		return readableHistogram(d)
+	case metricdata.Histogram[float64]:
+		return readableHistogram(d)
+	case metricdata.ExponentialHistogram[int64]:
+		return readableExponentialHistogram(d)
+	case metricdata.ExponentialHistogram[float64]:
+		return readableExponentialHistogram(d)
+	default:
+		return data
+	}
+}
+
+// readableDataPoint は Exemplar を読める形式に変換した Gauge / Sum のデータポイント
+//
+// NOTE: 埋め込んだ DataPoint の Exemplars より浅い階層で同名のフィールドを定義し、JSON 出力時に置き換える。
+type readableDataPoint[N int64 | float64] struct {
+	metricdata.DataPoint[N]
+	Exemplars []readableExemplar[N] `json:",omitempty"`
+}
+
+// readableDataPoints は points の Exemplar を読める形式に変換する
+func readableDataPoints[N int64 | float64](points []metricdata.DataPoint[N]) []readableDataPoint[N] {
+	out := make([]readableDataPoint[N], 0, len(points))
+	for _, dp := range points {
+		out = append(out, readableDataPoint[N]{DataPoint: dp, Exemplars: readableExemplars(dp.Exemplars)})
+	}
+	return out
+}
+
+// readableGauge は Exemplar を読める形式に変換した Gauge を返す
+func readableGauge[N int64 | float64](g metricdata.Gauge[N]) any {
+	return struct {
+		DataPoints []readableDataPoint[N]
+	}{DataPoints: readableDataPoints(g.DataPoints)}
+}
+
+// readableSum は Exemplar を読める形式に変換した Sum を返す
+func readableSum[N int64 | float64](s metricdata.Sum[N]) any {
+	return struct {
+		DataPoints  []readableDataPoint[N]
+		Temporality metricdata.Temporality
+		IsMonotonic bool
+	}{DataPoints: readableDataPoints(s.DataPoints), Temporality: s.Temporality, IsMonotonic: s.IsMonotonic}
+}
+
+// readableHistogram は Exemplar を読める形式に変換したヒストグラムを返す
+func readableHistogram[N int64 | float64](h metricdata.Histogram[N]) any {
+	type dataPoint struct {
+		metricdata.HistogramDataPoint[N]
+		Exemplars []readableExemplar[N] `json:",omitempty"`
+	}
+	points := make([]dataPoint, 0, len(h.DataPoints))
+	for _, dp := range h.DataPoints {
+		points = append(points, dataPoint{HistogramDataPoint: dp, Exemplars: readableExemplars(dp.Exemplars)})
+	}
+	return struct {
+		DataPoints  []dataPoint
+		Temporality metricdata.Temporality
+	}{DataPoints: points, Temporality: h.Temporality}
+}
+
+// readableExemplar は TraceID / SpanID を16進数の文字列で出力する Exemplar
+type readableExemplar[N int64 | float64] struct {
+	FilteredAttributes []attribute.KeyValue
+	Time               time.Time
+	Value              N
+	TraceID            string `json:",omitempty"`
+	SpanID             string `json:",omitempty"`
+}
+
+// readableExemplars は exemplars の TraceID / SpanID を16進数の文字列に変換する
+func readableExemplars[N int64 | float64](exemplars []metricdata.Exemplar[N]) []readableExemplar[N] {
+	if len(exemplars) == 0 {
+		return nil
+	}
+	out := make([]readableExemplar[N], 0, len(exemplars))
+	for _, e := range exemplars {
+		out = append(out, readableExemplar[N]{
+			FilteredAttributes: e.FilteredAttributes,
+			Time:               e.Time,
+			Value:              e.Value,
+			TraceID:            hex.EncodeToString(e.TraceID),
+			SpanID:             hex.EncodeToString(e.SpanID),
+		})
+	}
+	return out
+}
@@ -0,0 +1,403 @@
+package otel
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NOTE: テレメトリの送信経路 (パイプライン) 自体を観測するための内部メトリクス。
+// スパンやログがバッチのキューから溢れて破棄されていないか、エクスポートが失敗していないかを MeterProvider 経由で記録する。
+//   - otel.exporter.items.exported  : エクスポートに成功したアイテム数 (スパン / データポイント / LogRecord)
+//   - otel.exporter.items.failed    : エクスポートに失敗したアイテム数
+//   - otel.exporter.export.duration : 1回のエクスポートにかかった時間 (success 属性で成否を区別する)
+//   - otel.processor.items.dropped  : バッチのキューが満杯のため破棄したアイテム数
+//   - otel.processor.queue.size     : バッチのキューに溜まっているアイテム数
+//   - otel.processor.queue.capacity : バッチのキューの上限
+//   - otel.errors                   : otel.Handle に報告された SDK 内部のエラー数
+//
+// いずれも signal 属性 (traces / metrics / logs) で信号ごとに、pipeline 属性 (TracePipeline.Name 等) でエクスポート先ごとに区別する。
+// メトリクスはキューを持たないため processor.* は記録されない。
+
+const (
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+	signalLogs    = "logs"
+
+	// defaultMaxQueueSize はバッチのキューの上限 (SDK のデフォルト値と同じ)
+	defaultMaxQueueSize = 2048
+)
+
+// pipelineMetrics はテレメトリのパイプラインを観測する計器
+type pipelineMetrics struct {
+	meter metric.Meter
+
+	exported      metric.Int64Counter
+	failed        metric.Int64Counter
+	duration      metric.Float64Histogram
+	dropped       metric.Int64Counter
+	queueSize     metric.Int64ObservableGauge
+	queueCapacity metric.Int64ObservableGauge
+	errors        metric.Int64Counter
+}
+
+// newPipelineMetrics は mp 上に pipelineMetrics の計器を作成する
+func newPipelineMetrics(mp metric.MeterProvider) (*pipelineMetrics, error) {
+	m := &pipelineMetrics{meter: mp.Meter(instrumentationName)}
+
+	var err error
+	m.exported, err = m.meter.Int64Counter(
+		"otel.exporter.items.exported",
+		metric.WithDescription("エクスポートに成功したアイテム数"),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.failed, err = m.meter.Int64Counter(
+		"otel.exporter.items.failed",
+		metric.WithDescription("エクスポートに失敗したアイテム数"),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.duration, err = m.meter.Float64Histogram(
+		"otel.exporter.export.duration",
+		metric.WithDescription("1回のエクスポートにかかった時間"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.dropped, err = m.meter.Int64Counter(
+		"otel.processor.items.dropped",
+		metric.WithDescription("バッチのキューが満杯のため破棄したアイテム数"),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.queueSize, err = m.meter.Int64ObservableGauge(
+		"otel.processor.queue.size",
+		metric.WithDescription("バッチのキューに溜まっているアイテム数"),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.queueCapacity, err = m.meter.Int64ObservableGauge(
+		"otel.processor.queue.capacity",
+		metric.WithDescription("バッチのキューの上限"),
+		metric.WithUnit("{item}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.errors, err = m.meter.Int64Counter(
+		"otel.errors",
+		metric.WithDescription("SDK 内部で発生したエラー数"),
+		metric.WithUnit("{error}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// recordExport はパイプライン pipeline の1回のエクスポートの結果を記録する
+func (m *pipelineMetrics) recordExport(ctx context.Context, signal, pipeline string, items int, start time.Time, err error) {
+	attrs := metric.WithAttributes(attribute.String("signal", signal), attribute.String("pipeline", pipeline))
+	if err != nil {
+		m.failed.Add(ctx, int64(items), attrs)
+	} else {
+		m.exported.Add(ctx, int64(items), attrs)
+	}
+	m.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(
+		attribute.String("signal", signal),
+		attribute.String("pipeline", pipeline),
+		attribute.Bool("success", err == nil),
+	))
+}
+
+// =======================================================
+// バッチのキュー
+// =======================================================
+
+// queueGate はバッチプロセッサーに渡してからエクスポートされるまでのアイテム数を数え、上限を超えるアイテムを破棄する
+//
+// NOTE: SDK のバッチプロセッサーはキューの長さや破棄したアイテム数を公開していないため、手前で同じ上限のキューを模擬する。
+// queueGate が数えるアイテム数は常にバッチプロセッサー内部のキュー以上になるため、破棄は必ず queueGate 側で発生し、正確に数えられる。
+type queueGate struct {
+	signal   string
+	pipeline string
+	capacity int64
+	size     atomic.Int64
+
+	metrics      *pipelineMetrics
+	registration metric.Registration
+}
+
+// newQueueGate はパイプライン pipeline の capacity を上限とする queueGate を生成し、キューの長さを観測するコールバックを登録する
+func (m *pipelineMetrics) newQueueGate(signal, pipeline string, capacity int) (*queueGate, error) {
+	g := &queueGate{signal: signal, pipeline: pipeline, capacity: int64(capacity), metrics: m}
+
+	attrs := metric.WithAttributes(attribute.String("signal", signal), attribute.String("pipeline", pipeline))
+	var err error
+	g.registration, err = m.meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(m.queueSize, g.size.Load(), attrs)
+		o.ObserveInt64(m.queueCapacity, g.capacity, attrs)
+		return nil
+	}, m.queueSize, m.queueCapacity)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// acquire はアイテムをキューに積めるかを返す。上限に達している場合は破棄したものとして記録し false を返す
+func (g *queueGate) acquire(ctx context.Context) bool {
+	if g.size.Add(1) > g.capacity {
+		g.size.Add(-1)
+		g.metrics.dropped.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("signal", g.signal),
+			attribute.String("pipeline", g.pipeline),
+		))
+		return false
+	}
+	return true
+}
+
+// release はエクスポートに渡した n 件のアイテムをキューから取り除く
+func (g *queueGate) release(n int) {
+	g.size.Add(-int64(n))
+}
+
+// =======================================================
+// トレース
+// =======================================================
+
+// observedSpanProcessor は queueGate を通過したスパンだけをバッチプロセッサーに渡す sdktrace.SpanProcessor
+type observedSpanProcessor struct {
+	sdktrace.SpanProcessor
+	gate *queueGate
+}
+
+// OnEnd はキューに空きがある場合のみスパンを渡す
+//
+// NOTE: バッチプロセッサーはサンプリングされていないスパンを無視するため、キューの長さにも数えない。
+func (p *observedSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() && !p.gate.acquire(context.Background()) {
+		return
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+// Shutdown はコールバックの登録を解除してからバッチプロセッサーをシャットダウンする
+func (p *observedSpanProcessor) Shutdown(ctx context.Context) error {
+	_ = p.gate.registration.Unregister()
+	return p.SpanProcessor.Shutdown(ctx)
+}
+
+// observedSpanExporter はエクスポートの結果を記録する sdktrace.SpanExporter
+type observedSpanExporter struct {
+	sdktrace.SpanExporter
+	gate *queueGate
+}
+
+// ExportSpans はスパンをキューから取り除いてからエクスポートし、結果を記録する
+func (e *observedSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.gate.release(len(spans))
+	start := time.Now()
+	err := e.SpanExporter.ExportSpans(ctx, spans)
+	e.gate.metrics.recordExport(ctx, signalTraces, e.gate.pipeline, len(spans), start, err)
+	return err
+}
+
+// newObservedBatchSpanProcessor はパイプライン pipeline の exporter のエクスポート結果とキューの状態を記録する BatchSpanProcessor を生成する
+func (m *pipelineMetrics) newObservedBatchSpanProcessor(pipeline string, exporter sdktrace.SpanExporter, batch BatchConfig) (sdktrace.SpanProcessor, error) {
+	queueSize := batch.queueSize()
+	gate, err := m.newQueueGate(signalTraces, pipeline, queueSize)
+	if err != nil {
+		return nil, err
+	}
+	bsp := sdktrace.NewBatchSpanProcessor(&observedSpanExporter{SpanExporter: exporter, gate: gate}, batch.spanOptions()...)
+	return &observedSpanProcessor{SpanProcessor: bsp, gate: gate}, nil
+}
+
+// =======================================================
+// ログ
+// =======================================================
+
+// observedLogProcessor は queueGate を通過した LogRecord だけをバッチプロセッサーに渡す sdklog.Processor
+type observedLogProcessor struct {
+	sdklog.Processor
+	gate *queueGate
+}
+
+// OnEmit はキューに空きがある場合のみ LogRecord を渡す
+func (p *observedLogProcessor) OnEmit(ctx context.Context, r *sdklog.Record) error {
+	if !p.gate.acquire(ctx) {
+		return nil
+	}
+	return p.Processor.OnEmit(ctx, r)
+}
+
+// Shutdown はコールバックの登録を解除してからバッチプロセッサーをシャットダウンする
+func (p *observedLogProcessor) Shutdown(ctx context.Context) error {
+	_ = p.gate.registration.Unregister()
+	return p.Processor.Shutdown(ctx)
+}
+
+// observedLogExporter はエクスポートの結果を記録する sdklog.Exporter
+type observedLogExporter struct {
+	sdklog.Exporter
+	gate *queueGate
+}
+
+// Export は LogRecord をキューから取り除いてからエクスポートし、結果を記録する
+func (e *observedLogExporter) Export(ctx context.Context, records []sdklog.Record) error {
+	e.gate.release(len(records))
+	start := time.Now()
+	err := e.Exporter.Export(ctx, records)
+	e.gate.metrics.recordExport(ctx, signalLogs, e.gate.pipeline, len(records), start, err)
+	return err
+}
+
+// newObservedBatchLogProcessor はパイプライン pipeline の exporter のエクスポート結果とキューの状態を記録する BatchProcessor を生成する
+func (m *pipelineMetrics) newObservedBatchLogProcessor(pipeline string, exporter sdklog.Exporter, batch BatchConfig) (sdklog.Processor, error) {
+	queueSize := batch.queueSize()
+	gate, err := m.newQueueGate(signalLogs, pipeline, queueSize)
+	if err != nil {
+		return nil, err
+	}
+	bp := sdklog.NewBatchProcessor(&observedLogExporter{Exporter: exporter, gate: gate}, batch.logOptions()...)
+	return &observedLogProcessor{Processor: bp, gate: gate}, nil
+}
+
+// =======================================================
+// メトリクス
+// =======================================================
+
+// observedMetricExporter はエクスポートの結果を記録する sdkmetric.Exporter
+//
+// NOTE: 計器を作成する MeterProvider 自体がこのエクスポーターを使用するため、計器は MeterProvider の作成後に setMetrics で設定する。
+// PeriodicReader のゴルーチンから参照されるため atomic.Pointer で保持し、設定前のエクスポートは記録しない。
+type observedMetricExporter struct {
+	sdkmetric.Exporter
+	pipeline string
+	metrics  atomic.Pointer[pipelineMetrics]
+}
+
+// setMetrics はエクスポート結果を記録する計器を設定する
+func (e *observedMetricExporter) setMetrics(m *pipelineMetrics) {
+	e.metrics.Store(m)
+}
+
+// Export はメトリクスをエクスポートし、データポイント数と結果を記録する
+func (e *observedMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	if m := e.metrics.Load(); m != nil {
+		m.recordExport(ctx, signalMetrics, e.pipeline, dataPointCount(rm), start, err)
+	}
+	return err
+}
+
+// dataPointCount は rm に含まれるデータポイントの総数を返す
+func dataPointCount(rm *metricdata.ResourceMetrics) int {
+	var n int
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			switch data := m.Data.(type) {
+			case metricdata.Gauge[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Gauge[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Sum[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.Histogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[int64]:
+				n += len(data.DataPoints)
+			case metricdata.ExponentialHistogram[float64]:
+				n += len(data.DataPoints)
+			case metricdata.Summary:
+				n += len(data.DataPoints)
+			}
+		}
+	}
+	return n
+}
+
+// =======================================================
+// エラーハンドラ
+// =======================================================
+
+const (
+	// errorLogInterval / errorLogBurst は SDK 内部のエラーをログ出力する頻度の上限 (errorLogInterval ごとに errorLogBurst 件まで)
+	errorLogInterval = time.Minute
+	errorLogBurst    = 5
+)
+
+// errorHandler は otel.Handle に報告された SDK 内部のエラーを記録する otel.ErrorHandler
+//
+// エラーは otel.errors として数えた上で slog に出力する。
+// NOTE: Collector の停止中などはエクスポートのたびにエラーが発生し、ログの OTel エクスポートも失敗してさらにエラーを生むため、
+// ログ出力は errorLogInterval ごとに errorLogBurst 件までに制限し、抑制した件数を次の期間の最初のログに付与する。
+type errorHandler struct {
+	metrics *pipelineMetrics
+
+	mu          sync.Mutex
+	windowStart time.Time
+	logged      int
+	suppressed  int
+}
+
+// newErrorHandler は errorHandler を生成する
+func newErrorHandler(m *pipelineMetrics) *errorHandler {
+	return &errorHandler{metrics: m}
+}
+
+// Handle はエラーを記録し、頻度の上限内であれば slog に出力する
+func (h *errorHandler) Handle(err error) {
+	ctx := context.Background()
+	h.metrics.errors.Add(ctx, 1)
+
+	// NOTE: slog の出力先 (OTELHandler) から再び otel.Handle が呼ばれてもデッドロックしないよう、ロックの外でログを出力する
+	h.mu.Lock()
+	now := time.Now()
+	if now.Sub(h.windowStart) >= errorLogInterval {
+		h.windowStart = now
+		h.logged = 0
+	}
+	if h.logged >= errorLogBurst {
+		h.suppressed++
+		h.mu.Unlock()
+		return
+	}
+	h.logged++
+	suppressed := h.suppressed
+	h.suppressed = 0
+	h.mu.Unlock()
+
+	attrs := []any{slog.String("error", err.Error())}
+	if suppressed > 0 {
+		attrs = append(attrs, slog.Int("suppressed", suppressed))
+	}
+	slog.ErrorContext(ctx, "otel: telemetry pipeline error", attrs...)
+}
@@ -0,0 +1,258 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// int64Value は reader から name の計器の attrs と完全に一致するデータポイントの値を返す (存在しない場合は0)
+func int64Value(t *testing.T, reader sdkmetric.Reader, name string, attrs ...attribute.KeyValue) int64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	want := attribute.NewSet(attrs...)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name != name {
+				continue
+			}
+			var points []metricdata.DataPoint[int64]
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				points = data.DataPoints
+			case metricdata.Gauge[int64]:
+				points = data.DataPoints
+			default:
+				t.Fatalf("%s has unexpected data %T", name, m.Data)
+			}
+			for _, dp := range points {
+				if dp.Attributes.Equals(&want) {
+					return dp.Value
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// newTestPipelineMetrics は ManualReader で読み取れる pipelineMetrics を生成する
+func newTestPipelineMetrics(t *testing.T) (*pipelineMetrics, *sdkmetric.ManualReader) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	m, err := newPipelineMetrics(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, reader
+}
+
+// stubSpanExporter は err を返す sdktrace.SpanExporter
+type stubSpanExporter struct{ err error }
+
+func (e stubSpanExporter) ExportSpans(context.Context, []sdktrace.ReadOnlySpan) error { return e.err }
+func (e stubSpanExporter) Shutdown(context.Context) error                             { return nil }
+
+// stubLogExporter は err を返す sdklog.Exporter
+type stubLogExporter struct{ err error }
+
+func (e stubLogExporter) Export(context.Context, []sdklog.Record) error { return e.err }
+func (e stubLogExporter) Shutdown(context.Context) error                { return nil }
+func (e stubLogExporter) ForceFlush(context.Context) error              { return nil }
+
+// stubMetricExporter は Export で err を返す sdkmetric.Exporter (Export 以外は使用しない)
+type stubMetricExporter struct {
+	sdkmetric.Exporter
+	err error
+}
+
+func (e stubMetricExporter) Export(context.Context, *metricdata.ResourceMetrics) error { return e.err }
+
+var errExport = errors.New("export failed")
+
+func TestObservedSpanPipelineCounters(t *testing.T) {
+	m, reader := newTestPipelineMetrics(t)
+	attrs := []attribute.KeyValue{attribute.String("signal", signalTraces), attribute.String("pipeline", "primary")}
+
+	// NOTE: キューの上限を2とし、3件目のスパンは破棄される
+	gate, err := m.newQueueGate(signalTraces, "primary", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(&observedSpanProcessor{SpanProcessor: recorder, gate: gate}))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	for range 3 {
+		_, span := tp.Tracer("test").Start(context.Background(), "span")
+		span.End()
+	}
+	if got := len(recorder.Ended()); got != 2 {
+		t.Fatalf("batch processor received %d spans, want 2", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.items.dropped", attrs...); got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.queue.size", attrs...); got != 2 {
+		t.Errorf("queue.size = %d, want 2", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.queue.capacity", attrs...); got != 2 {
+		t.Errorf("queue.capacity = %d, want 2", got)
+	}
+
+	spans := recorder.Ended()
+	if err := (&observedSpanExporter{SpanExporter: stubSpanExporter{}, gate: gate}).ExportSpans(context.Background(), spans[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&observedSpanExporter{SpanExporter: stubSpanExporter{err: errExport}, gate: gate}).ExportSpans(context.Background(), spans[1:]); !errors.Is(err, errExport) {
+		t.Fatalf("ExportSpans error = %v, want %v", err, errExport)
+	}
+	if got := int64Value(t, reader, "otel.exporter.items.exported", attrs...); got != 1 {
+		t.Errorf("exported = %d, want 1", got)
+	}
+	if got := int64Value(t, reader, "otel.exporter.items.failed", attrs...); got != 1 {
+		t.Errorf("failed = %d, want 1", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.queue.size", attrs...); got != 0 {
+		t.Errorf("queue.size after export = %d, want 0", got)
+	}
+}
+
+func TestObservedBatchSpanProcessorExports(t *testing.T) {
+	m, reader := newTestPipelineMetrics(t)
+	exporter := tracetest.NewInMemoryExporter()
+	bsp, err := m.newObservedBatchSpanProcessor("primary", exporter, BatchConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(bsp))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	for range 3 {
+		_, span := tp.Tracer("test").Start(context.Background(), "span")
+		span.End()
+	}
+	if err := tp.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	attrs := []attribute.KeyValue{attribute.String("signal", signalTraces), attribute.String("pipeline", "primary")}
+	if got := len(exporter.GetSpans()); got != 3 {
+		t.Fatalf("exporter received %d spans, want 3", got)
+	}
+	if got := int64Value(t, reader, "otel.exporter.items.exported", attrs...); got != 3 {
+		t.Errorf("exported = %d, want 3", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.queue.capacity", attrs...); got != defaultMaxQueueSize {
+		t.Errorf("queue.capacity = %d, want %d", got, defaultMaxQueueSize)
+	}
+}
+
+func TestObservedLogPipelineCounters(t *testing.T) {
+	m, reader := newTestPipelineMetrics(t)
+	attrs := []attribute.KeyValue{attribute.String("signal", signalLogs), attribute.String("pipeline", "primary")}
+
+	gate, err := m.newQueueGate(signalLogs, "primary", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := &logRecorder{}
+	lp := sdklog.NewLoggerProvider(sdklog.WithProcessor(&observedLogProcessor{Processor: sdklog.NewSimpleProcessor(recorder), gate: gate}))
+	t.Cleanup(func() { _ = lp.Shutdown(context.Background()) })
+	for range 3 {
+		var r log.Record
+		r.SetBody(log.StringValue("message"))
+		lp.Logger("test").Emit(context.Background(), r)
+	}
+	if got := len(recorder.get()); got != 2 {
+		t.Fatalf("batch processor received %d records, want 2", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.items.dropped", attrs...); got != 1 {
+		t.Errorf("dropped = %d, want 1", got)
+	}
+
+	records := recorder.get()
+	if err := (&observedLogExporter{Exporter: stubLogExporter{}, gate: gate}).Export(context.Background(), records[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := (&observedLogExporter{Exporter: stubLogExporter{err: errExport}, gate: gate}).Export(context.Background(), records[1:]); !errors.Is(err, errExport) {
+		t.Fatalf("Export error = %v, want %v", err, errExport)
+	}
+	if got := int64Value(t, reader, "otel.exporter.items.exported", attrs...); got != 1 {
+		t.Errorf("exported = %d, want 1", got)
+	}
+	if got := int64Value(t, reader, "otel.exporter.items.failed", attrs...); got != 1 {
+		t.Errorf("failed = %d, want 1", got)
+	}
+	if got := int64Value(t, reader, "otel.processor.queue.size", attrs...); got != 0 {
+		t.Errorf("queue.size after export = %d, want 0", got)
+	}
+}
+
+func TestObservedMetricExporterCounters(t *testing.T) {
+	m, reader := newTestPipelineMetrics(t)
+	attrs := []attribute.KeyValue{attribute.String("signal", signalMetrics), attribute.String("pipeline", "primary")}
+
+	ok := &observedMetricExporter{Exporter: stubMetricExporter{}, pipeline: "primary"}
+	failing := &observedMetricExporter{Exporter: stubMetricExporter{err: errExport}, pipeline: "primary"}
+
+	// NOTE: setMetrics の前のエクスポートは記録しない
+	if err := ok.Export(context.Background(), testResourceMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	ok.setMetrics(m)
+	failing.setMetrics(m)
+	if err := ok.Export(context.Background(), testResourceMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	if err := failing.Export(context.Background(), testResourceMetrics()); !errors.Is(err, errExport) {
+		t.Fatalf("Export error = %v, want %v", err, errExport)
+	}
+
+	// NOTE: testResourceMetrics のデータポイントは1件
+	if got := int64Value(t, reader, "otel.exporter.items.exported", attrs...); got != 1 {
+		t.Errorf("exported = %d, want 1", got)
+	}
+	if got := int64Value(t, reader, "otel.exporter.items.failed", attrs...); got != 1 {
+		t.Errorf("failed = %d, want 1", got)
+	}
+}
+
+func TestErrorHandlerRateLimitsLogs(t *testing.T) {
+	logs := captureSlog(t)
+	m, reader := newTestPipelineMetrics(t)
+	h := newErrorHandler(m)
+
+	for range errorLogBurst + 2 {
+		h.Handle(errExport)
+	}
+	lines := strings.Count(logs.String(), "telemetry pipeline error")
+	if lines != errorLogBurst {
+		t.Errorf("logged %d errors within the interval, want %d", lines, errorLogBurst)
+	}
+	if got := int64Value(t, reader, "otel.errors"); got != errorLogBurst+2 {
+		t.Errorf("otel.errors = %d, want %d (every error is counted)", got, errorLogBurst+2)
+	}
+
+	// NOTE: 次の期間の最初のログに抑制した件数を付与する
+	h.mu.Lock()
+	h.windowStart = h.windowStart.Add(-errorLogInterval)
+	h.mu.Unlock()
+	logs.Reset()
+	h.Handle(errExport)
+	if out := logs.String(); !strings.Contains(out, "telemetry pipeline error") || !strings.Contains(out, "suppressed=2") {
+		t.Errorf("log after the interval = %q, want the error with suppressed=2", out)
+	}
+}
@@ -0,0 +1,54 @@
+package oteltest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// LogLines は Logger に出力されたログを1行ずつ JSON としてパースして返す
+func (p *Provider) LogLines(t testing.TB) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	scanner := bufio.NewScanner(bytes.NewReader(p.logs.Bytes()))
+	for scanner.Scan() {
+		var line map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("oteltest: parse log line %q: %v", scanner.Text(), err)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Logs は msg のログを出力した順に返す
+func (p *Provider) Logs(t testing.TB, msg string) []map[string]any {
+	t.Helper()
+	var lines []map[string]any
+	for _, line := range p.LogLines(t) {
+		if line["msg"] == msg {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// AssertLogHasTraceID は msg のログのいずれかが、span の最後に終了したスパンの trace_id を持つことを検証する
+func (p *Provider) AssertLogHasTraceID(t testing.TB, msg, span string) {
+	t.Helper()
+	traceID := p.Span(t, span).SpanContext().TraceID().String()
+	lines := p.Logs(t, msg)
+	if len(lines) == 0 {
+		t.Errorf("oteltest: log %q not found", msg)
+		return
+	}
+	var got []any
+	for _, line := range lines {
+		if line["trace_id"] == traceID {
+			return
+		}
+		got = append(got, line["trace_id"])
+	}
+	t.Errorf("oteltest: log %q does not carry trace_id %s of span %q (trace_id: %v)", msg, traceID, span, got)
+}
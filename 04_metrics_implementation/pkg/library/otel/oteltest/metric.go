@@ -0,0 +1,155 @@
+package oteltest
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Collect は記録されたメトリクスを収集して返す
+//
+// NOTE: Temporality は Cumulative のため、Collect を複数回呼んでも値はテストの開始時からの累計となる。
+func (p *Provider) Collect(t testing.TB) metricdata.ResourceMetrics {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := p.Reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("oteltest: collect metrics: %v", err)
+	}
+	return rm
+}
+
+// Metric は name のメトリクスを収集して返す。存在しない場合はテストを終了する
+func (p *Provider) Metric(t testing.TB, name string) metricdata.Metrics {
+	t.Helper()
+	rm := p.Collect(t)
+	var names []string
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+			names = append(names, m.Name)
+		}
+	}
+	t.Fatalf("oteltest: metric %q not found (metrics: %v)", name, names)
+	return metricdata.Metrics{}
+}
+
+// AssertMetricPoint は name のメトリクスに attrs を全て含むデータポイントが存在することを検証する
+func (p *Provider) AssertMetricPoint(t testing.TB, name string, attrs ...attribute.KeyValue) {
+	t.Helper()
+	m := p.Metric(t, name)
+	if _, ok := findPoint(m.Data, attrs); !ok {
+		t.Errorf("oteltest: metric %q has no point with %s (points: %v)", name, formatAttributes(attrs), pointAttributes(m.Data))
+	}
+}
+
+// AssertHistogramPoint は name のヒストグラムに attrs を全て含むデータポイントが存在することを検証する
+func (p *Provider) AssertHistogramPoint(t testing.TB, name string, attrs ...attribute.KeyValue) {
+	t.Helper()
+	m := p.Metric(t, name)
+	switch m.Data.(type) {
+	case metricdata.Histogram[int64], metricdata.Histogram[float64],
+		metricdata.ExponentialHistogram[int64], metricdata.ExponentialHistogram[float64]:
+	default:
+		t.Fatalf("oteltest: metric %q is %T, not a histogram", name, m.Data)
+	}
+	if _, ok := findPoint(m.Data, attrs); !ok {
+		t.Errorf("oteltest: histogram %q has no point with %s (points: %v)", name, formatAttributes(attrs), pointAttributes(m.Data))
+	}
+}
+
+// Value は name の Sum / Gauge のうち attrs を全て含むデータポイントの値を返す。
+// ヒストグラムの場合は記録回数 (Count) を返す。データポイントが存在しない場合はテストを終了する
+func (p *Provider) Value(t testing.TB, name string, attrs ...attribute.KeyValue) float64 {
+	t.Helper()
+	m := p.Metric(t, name)
+	v, ok := findPoint(m.Data, attrs)
+	if !ok {
+		t.Fatalf("oteltest: metric %q has no point with %s (points: %v)", name, formatAttributes(attrs), pointAttributes(m.Data))
+	}
+	return v
+}
+
+// point はデータポイントの属性と値
+type point struct {
+	attrs attribute.Set
+	value float64
+}
+
+// points は data の全てのデータポイントを返す
+func points(data metricdata.Aggregation) []point {
+	var out []point
+	switch d := data.(type) {
+	case metricdata.Gauge[int64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, float64(dp.Value)})
+		}
+	case metricdata.Gauge[float64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, dp.Value})
+		}
+	case metricdata.Sum[int64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, float64(dp.Value)})
+		}
+	case metricdata.Sum[float64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, dp.Value})
+		}
+	case metricdata.Histogram[int64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, float64(dp.Count)})
+		}
+	case metricdata.Histogram[float64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, float64(dp.Count)})
+		}
+	case metricdata.ExponentialHistogram[int64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, float64(dp.Count)})
+		}
+	case metricdata.ExponentialHistogram[float64]:
+		for _, dp := range d.DataPoints {
+			out = append(out, point{dp.Attributes, float64(dp.Count)})
+		}
+	}
+	return out
+}
+
+// findPoint は attrs を全て含む最初のデータポイントの値を返す
+func findPoint(data metricdata.Aggregation, attrs []attribute.KeyValue) (float64, bool) {
+	for _, pt := range points(data) {
+		if containsAll(pt.attrs, attrs) {
+			return pt.value, true
+		}
+	}
+	return 0, false
+}
+
+// containsAll は set が attrs を全て含むかを返す
+func containsAll(set attribute.Set, attrs []attribute.KeyValue) bool {
+	for _, kv := range attrs {
+		if v, ok := set.Value(kv.Key); !ok || v != kv.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// pointAttributes は data の各データポイントの属性を返す (失敗時のメッセージ用)
+func pointAttributes(data metricdata.Aggregation) []string {
+	var out []string
+	for _, pt := range points(data) {
+		out = append(out, pt.attrs.Encoded(attribute.DefaultEncoder()))
+	}
+	return out
+}
+
+// formatAttributes は attrs を "key1=value1,key2=value2" 形式の文字列に変換する (失敗時のメッセージ用)
+func formatAttributes(attrs []attribute.KeyValue) string {
+	set := attribute.NewSet(attrs...)
+	return set.Encoded(attribute.DefaultEncoder())
+}
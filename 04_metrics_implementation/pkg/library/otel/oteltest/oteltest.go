@@ -0,0 +1,103 @@
+// Package oteltest はテレメトリをメモリ上に記録し、テストから検証するためのヘルパーを提供する
+//
+// NewProvider で生成した Provider の Telemetry をコンストラクタに渡すと、スパン・メトリクス・ログが Provider ごとに独立して記録される。
+// グローバルの Provider を変更しないため、複数のテストを並行して実行できる。
+//
+//	p := oteltest.NewProvider(t)
+//	repo := repository.NewArticleRepository(p.Telemetry())
+//	uc, err := usecase.NewArticleUsecase(repo, p.Telemetry())
+//	...
+//	p.AssertSpanHasEvent(t, "ArticleUsecase.Create", "validation_completed")
+//	p.AssertHistogramPoint(t, "article.create.duration", attribute.String("status", "error"))
+//	p.AssertLogHasTraceID(t, "failed to create article", "ArticleUsecase.Create")
+package oteltest
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/tamaco489/otel_sample/04_metrics_implementation/pkg/library/otel"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Provider はテレメトリをメモリ上に記録する TracerProvider / MeterProvider / slog.Logger の組
+type Provider struct {
+	TracerProvider *sdktrace.TracerProvider
+	MeterProvider  *sdkmetric.MeterProvider
+
+	// Logger は OTELHandler (trace_id / span_id を付与する) を通して JSON Lines 形式で Logs に出力する
+	Logger *slog.Logger
+
+	// Spans は終了したスパンを記録する
+	// NOTE: SpanRecorder は SpanProcessor として同期的に記録するため、span.End() の直後から参照できる
+	Spans *tracetest.SpanRecorder
+
+	// Reader は Collect の呼び出し時にメトリクスを収集する ManualReader
+	Reader *sdkmetric.ManualReader
+
+	logs *buffer
+}
+
+// NewProvider は Provider を生成する。Provider はテストの終了時にシャットダウンされる
+func NewProvider(t testing.TB) *Provider {
+	t.Helper()
+
+	logs := &buffer{}
+	p := &Provider{
+		Spans:  tracetest.NewSpanRecorder(),
+		Reader: sdkmetric.NewManualReader(),
+		Logger: slog.New(otel.NewOTELHandler(slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+		logs:   logs,
+	}
+	p.TracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithSpanProcessor(p.Spans),
+	)
+	p.MeterProvider = sdkmetric.NewMeterProvider(sdkmetric.WithReader(p.Reader))
+
+	t.Cleanup(func() {
+		ctx := context.Background()
+		if err := p.TracerProvider.Shutdown(ctx); err != nil {
+			t.Errorf("oteltest: shutdown tracer provider: %v", err)
+		}
+		if err := p.MeterProvider.Shutdown(ctx); err != nil {
+			t.Errorf("oteltest: shutdown meter provider: %v", err)
+		}
+	})
+	return p
+}
+
+// Telemetry は repository / usecase / handler 等のコンストラクタに渡す otel.Telemetry を返す
+func (p *Provider) Telemetry() otel.Telemetry {
+	return otel.Telemetry{
+		TracerProvider: p.TracerProvider,
+		MeterProvider:  p.MeterProvider,
+		Logger:         p.Logger,
+	}
+}
+
+// buffer は複数のゴルーチンから書き込まれるログを保持する io.Writer
+type buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write は p を追記する
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// Bytes は書き込まれた内容のコピーを返す
+func (b *buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return bytes.Clone(b.buf.Bytes())
+}
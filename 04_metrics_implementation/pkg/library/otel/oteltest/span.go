@@ -0,0 +1,100 @@
+package oteltest
+
+import (
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SpansNamed は name の終了したスパンを終了した順に返す
+func (p *Provider) SpansNamed(name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range p.Spans.Ended() {
+		if s.Name() == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Span は name の終了したスパンのうち最後に終了したものを返す。存在しない場合はテストを終了する
+func (p *Provider) Span(t testing.TB, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	spans := p.SpansNamed(name)
+	if len(spans) == 0 {
+		t.Fatalf("oteltest: span %q not found (ended spans: %v)", name, p.spanNames())
+	}
+	return spans[len(spans)-1]
+}
+
+// AssertSpanHasEvent は name のスパンのいずれかがイベント event を持つことを検証する
+func (p *Provider) AssertSpanHasEvent(t testing.TB, name, event string) {
+	t.Helper()
+	var got []string
+	for _, s := range p.SpansNamed(name) {
+		for _, e := range s.Events() {
+			if e.Name == event {
+				return
+			}
+			if !slices.Contains(got, e.Name) {
+				got = append(got, e.Name)
+			}
+		}
+	}
+	t.Errorf("oteltest: span %q has no event %q (events: %v)", name, event, got)
+}
+
+// AssertSpanAttribute は name のスパンのいずれかが属性 want を持つことを検証する
+func (p *Provider) AssertSpanAttribute(t testing.TB, name string, want attribute.KeyValue) {
+	t.Helper()
+	var got []string
+	for _, s := range p.SpansNamed(name) {
+		for _, kv := range s.Attributes() {
+			if kv.Key != want.Key {
+				continue
+			}
+			if kv.Value == want.Value {
+				return
+			}
+			if !slices.Contains(got, kv.Value.Emit()) {
+				got = append(got, kv.Value.Emit())
+			}
+		}
+	}
+	t.Errorf("oteltest: span %q has no attribute %s=%s (values of %s: %v)", name, want.Key, want.Value.Emit(), want.Key, got)
+}
+
+// AssertSpanStatus は name の最後に終了したスパンのステータスが code であることを検証する
+func (p *Provider) AssertSpanStatus(t testing.TB, name string, code codes.Code) {
+	t.Helper()
+	if got := p.Span(t, name).Status().Code; got != code {
+		t.Errorf("oteltest: span %q status = %s, want %s", name, got, code)
+	}
+}
+
+// AssertSpanParent は child の最後に終了したスパンの親が parent のスパンであることを検証する
+func (p *Provider) AssertSpanParent(t testing.TB, child, parent string) {
+	t.Helper()
+	c := p.Span(t, child)
+	for _, s := range p.SpansNamed(parent) {
+		if s.SpanContext().SpanID() == c.Parent().SpanID() {
+			return
+		}
+	}
+	t.Errorf("oteltest: parent of span %q is not %q", child, parent)
+}
+
+// spanNames は終了したスパンの名前を重複なしで返す
+func (p *Provider) spanNames() []string {
+	var names []string
+	for _, s := range p.Spans.Ended() {
+		if !slices.Contains(names, s.Name()) {
+			names = append(names, s.Name())
+		}
+	}
+	return names
+}
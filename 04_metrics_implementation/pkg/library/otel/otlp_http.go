@@ -0,0 +1,304 @@
+package otel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// validateEncoding は Encoding の値を検証する
+func (c OTLPConfig) validateEncoding() error {
+	switch c.Encoding {
+	case "", OTLPEncodingProtobuf, OTLPEncodingJSON:
+		return nil
+	default:
+		return fmt.Errorf("otel: unsupported OTLP encoding %q", c.Encoding)
+	}
+}
+
+// httpTLSConfig は Insecure でない場合に tls.Config を返す (Insecure の場合は nil)
+func (c OTLPConfig) httpTLSConfig() (*tls.Config, error) {
+	if c.Insecure {
+		return nil, nil
+	}
+	return c.tlsConfig()
+}
+
+// needsHTTPClient は SDK デフォルトの http.Client の代わりに httpClient を使用する必要があるかを返す
+func (c OTLPConfig) needsHTTPClient(queue *persistentQueue) bool {
+	return c.Encoding == OTLPEncodingJSON || queue != nil
+}
+
+// httpClient は Encoding と退避キューに応じた Transport を持つ http.Client を生成する
+//
+// NOTE: SDK の otlptracehttp / otlpmetrichttp は protobuf エンコーディングのみをサポートしているため、
+// JSON の場合は Transport 層で ExportXxxServiceRequest を protojson に詰め替え、JSON のレスポンスを protobuf に詰め替える。
+// (SDK は Content-Type が protobuf のレスポンスのみを解釈するため、詰め替えないと partial success が無視される)
+// 退避キューを使用する場合は最も外側の Transport で失敗したリクエストを protobuf のまま退避する (persistent_queue.go 参照)。
+// WithHTTPClient を指定すると WithTLSClientConfig / WithTimeout は無視されるため、ここで Transport と Timeout に反映する。
+func (c OTLPConfig) httpClient(tlsCfg *tls.Config, newRequest, newResponse func() proto.Message, queue *persistentQueue) *http.Client {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = tlsCfg
+	var transport http.RoundTripper = base
+	if c.Encoding == OTLPEncodingJSON {
+		transport = &jsonTranscoder{base: base, newRequest: newRequest, newResponse: newResponse}
+	}
+	if queue != nil {
+		transport = queue.httpTransport(transport)
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   c.Timeout,
+	}
+}
+
+// otlpTraceHTTPOptions は OTLPConfig を otlptracehttp のオプションに変換する
+func otlpTraceHTTPOptions(c OTLPConfig, queue *persistentQueue) ([]otlptracehttp.Option, error) {
+	if err := c.validateCompression(); err != nil {
+		return nil, err
+	}
+	if err := c.validateEncoding(); err != nil {
+		return nil, err
+	}
+	tlsCfg, err := c.httpTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []otlptracehttp.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlptracehttp.WithEndpoint(c.Endpoint))
+	}
+	if c.URLPath != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(c.URLPath))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+	}
+	if c.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	} else {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tlsCfg))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(c.Timeout))
+	}
+	if c.needsHTTPClient(queue) {
+		opts = append(opts, otlptracehttp.WithHTTPClient(c.httpClient(tlsCfg, func() proto.Message {
+			return &coltracepb.ExportTraceServiceRequest{}
+		}, func() proto.Message {
+			return &coltracepb.ExportTraceServiceResponse{}
+		}, queue)))
+	}
+	return opts, nil
+}
+
+// otlpMetricHTTPOptions は OTLPConfig を otlpmetrichttp のオプションに変換する
+func otlpMetricHTTPOptions(c OTLPConfig, queue *persistentQueue) ([]otlpmetrichttp.Option, error) {
+	if err := c.validateCompression(); err != nil {
+		return nil, err
+	}
+	if err := c.validateEncoding(); err != nil {
+		return nil, err
+	}
+	tlsCfg, err := c.httpTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []otlpmetrichttp.Option
+	if c.Endpoint != "" {
+		opts = append(opts, otlpmetrichttp.WithEndpoint(c.Endpoint))
+	}
+	if c.URLPath != "" {
+		opts = append(opts, otlpmetrichttp.WithURLPath(c.URLPath))
+	}
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlpmetrichttp.WithHeaders(c.Headers))
+	}
+	if c.Insecure {
+		opts = append(opts, otlpmetrichttp.WithInsecure())
+	} else {
+		opts = append(opts, otlpmetrichttp.WithTLSClientConfig(tlsCfg))
+	}
+	if c.Compression == "gzip" {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, otlpmetrichttp.WithTimeout(c.Timeout))
+	}
+	if c.needsHTTPClient(queue) {
+		opts = append(opts, otlpmetrichttp.WithHTTPClient(c.httpClient(tlsCfg, func() proto.Message {
+			return &colmetricpb.ExportMetricsServiceRequest{}
+		}, func() proto.Message {
+			return &colmetricpb.ExportMetricsServiceResponse{}
+		}, queue)))
+	}
+	return opts, nil
+}
+
+// jsonTranscoder は protobuf のリクエストボディを OTLP/JSON に、JSON のレスポンスボディを protobuf に変換する http.RoundTripper
+type jsonTranscoder struct {
+	base        http.RoundTripper
+	newRequest  func() proto.Message
+	newResponse func() proto.Message
+}
+
+// RoundTrip はリクエストボディを OTLP/JSON に変換してから base に委譲し、成功時の JSON のレスポンスを protobuf に変換する
+func (t *jsonTranscoder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	gzipped := req.Header.Get("Content-Encoding") == "gzip"
+	if gzipped {
+		if body, err = gunzip(body); err != nil {
+			return nil, err
+		}
+	}
+
+	msg := t.newRequest()
+	if err := proto.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("otel: decode OTLP protobuf payload: %w", err)
+	}
+	out, err := marshalOTLPJSON(msg)
+	if err != nil {
+		return nil, err
+	}
+	if gzipped {
+		if out, err = gzipBytes(out); err != nil {
+			return nil, err
+		}
+	}
+
+	// NOTE: RoundTripper は元のリクエストを変更してはならないため、複製したリクエストに差し替える
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(out))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(out)), nil }
+	r.ContentLength = int64(len(out))
+	r.Header.Set("Content-Type", "application/json")
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	return t.transcodeResponse(resp)
+}
+
+// transcodeResponse は成功 (2xx) した JSON のレスポンスボディを protobuf に変換する
+//
+// NOTE: 変換できないボディ (空・不正な JSON) の場合は SDK と同様に無視できるよう、元のレスポンスのまま返す
+func (t *jsonTranscoder) transcodeResponse(resp *http.Response) (*http.Response, error) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || mediaType != "application/json" {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if len(bytes.TrimSpace(body)) == 0 {
+		return resp, nil
+	}
+
+	msg := t.newResponse()
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(body, msg); err != nil {
+		return resp, nil
+	}
+	out, err := proto.Marshal(msg)
+	if err != nil {
+		return resp, nil
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(out))
+	resp.ContentLength = int64(len(out))
+	resp.Header = resp.Header.Clone()
+	resp.Header.Set("Content-Type", "application/x-protobuf")
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// marshalOTLPJSON は OTLP/JSON の仕様に従って proto.Message を JSON に変換する
+//
+// OTLP/JSON は protojson の標準形式と以下の点が異なる:
+//   - enum は名前ではなく整数値で表現する
+//   - traceId / spanId / parentSpanId は base64 ではなく16進文字列で表現する
+func marshalOTLPJSON(msg proto.Message) ([]byte, error) {
+	raw, err := protojson.MarshalOptions{UseEnumNumbers: true}.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("otel: encode OTLP JSON payload: %w", err)
+	}
+
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	hexEncodeIDs(v)
+	return json.Marshal(v)
+}
+
+// hexEncodeIDs は JSON ツリーを走査し、ID フィールドを base64 から16進文字列に変換する
+func hexEncodeIDs(v any) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			switch k {
+			case "traceId", "spanId", "parentSpanId":
+				if s, ok := child.(string); ok {
+					if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+						t[k] = hex.EncodeToString(b)
+					}
+				}
+			default:
+				hexEncodeIDs(child)
+			}
+		}
+	case []any:
+		for _, child := range t {
+			hexEncodeIDs(child)
+		}
+	}
+}
+
+// gunzip は gzip で圧縮されたバイト列を展開する
+func gunzip(b []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// gzipBytes はバイト列を gzip で圧縮する
+func gzipBytes(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,363 @@
+package otel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// httpRequest は受信側で記録した1回のエクスポートリクエスト
+type httpRequest struct {
+	path            string
+	contentType     string
+	contentEncoding string
+	header          http.Header
+	body            []byte // NOTE: gzip の場合は展開後のボディ
+}
+
+// httpReceiver は OTLP/HTTP のテスト用サーバー
+type httpReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []httpRequest
+	// respond はレスポンスを書き込む (nil の場合はボディなしの 200)
+	respond func(w http.ResponseWriter, r httpRequest)
+}
+
+// startHTTPReceiver は httpReceiver を起動する
+func startHTTPReceiver(t *testing.T) *httpReceiver {
+	t.Helper()
+	r := &httpReceiver{}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		if req.Header.Get("Content-Encoding") == "gzip" {
+			if body, err = gunzip(body); err != nil {
+				t.Error(err)
+			}
+		}
+		rec := httpRequest{
+			path:            req.URL.Path,
+			contentType:     req.Header.Get("Content-Type"),
+			contentEncoding: req.Header.Get("Content-Encoding"),
+			header:          req.Header.Clone(),
+			body:            body,
+		}
+		r.mu.Lock()
+		r.requests = append(r.requests, rec)
+		respond := r.respond
+		r.mu.Unlock()
+		if respond != nil {
+			respond(w, rec)
+		}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// otlp はこのサーバーに送信する OTLPConfig を返す
+func (r *httpReceiver) otlp(encoding OTLPEncoding) OTLPConfig {
+	u, _ := url.Parse(r.URL)
+	return OTLPConfig{
+		Endpoint:    u.Host,
+		Insecure:    true,
+		Encoding:    encoding,
+		Headers:     map[string]string{"Authorization": "Bearer token"},
+		Compression: "gzip",
+	}
+}
+
+func (r *httpReceiver) received() []httpRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]httpRequest(nil), r.requests...)
+}
+
+// testSpans はエクスポートするスパンを返す
+func testSpans() []sdktrace.ReadOnlySpan {
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	spanID, _ := trace.SpanIDFromHex("1112131415161718")
+	parentID, _ := trace.SpanIDFromHex("2122232425262728")
+	start := time.Unix(1700000000, 0)
+	return tracetest.SpanStubs{{
+		Name:        "ArticleRepository.FindByID",
+		SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}),
+		Parent:      trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: parentID, TraceFlags: trace.FlagsSampled}),
+		SpanKind:    trace.SpanKindClient,
+		StartTime:   start,
+		EndTime:     start.Add(time.Millisecond),
+		Attributes:  []attribute.KeyValue{attribute.String("db.system", "postgresql")},
+		Resource:    resource.NewSchemaless(attribute.String("service.name", "article-api")),
+	}}.Snapshots()
+}
+
+// testResourceMetrics はエクスポートするメトリクスを返す
+func testResourceMetrics() *metricdata.ResourceMetrics {
+	now := time.Unix(1700000000, 0)
+	return &metricdata.ResourceMetrics{
+		Resource: resource.NewSchemaless(attribute.String("service.name", "article-api")),
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope: instrumentation.Scope{Name: "test"},
+			Metrics: []metricdata.Metrics{{
+				Name: "article.views.total",
+				Data: metricdata.Sum[int64]{
+					Temporality: metricdata.CumulativeTemporality,
+					IsMonotonic: true,
+					DataPoints:  []metricdata.DataPoint[int64]{{StartTime: now, Time: now, Value: 3}},
+				},
+			}},
+		}},
+	}
+}
+
+func TestOTLPHTTPTraceExporterEncodings(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("protobuf", func(t *testing.T) {
+		r := startHTTPReceiver(t)
+		exp, err := newTraceExporter(ctx, ExporterConfig{Type: ExporterOTLPHTTP, OTLP: r.otlp(OTLPEncodingProtobuf)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exp.ExportSpans(ctx, testSpans()); err != nil {
+			t.Fatal(err)
+		}
+		_ = exp.Shutdown(ctx)
+
+		got := r.received()
+		if len(got) != 1 {
+			t.Fatalf("received %d requests, want 1", len(got))
+		}
+		req := got[0]
+		if req.path != "/v1/traces" || req.contentType != "application/x-protobuf" || req.contentEncoding != "gzip" {
+			t.Errorf("path=%q content-type=%q content-encoding=%q", req.path, req.contentType, req.contentEncoding)
+		}
+		if v := req.header.Get("Authorization"); v != "Bearer token" {
+			t.Errorf("Authorization = %q", v)
+		}
+		var msg coltracepb.ExportTraceServiceRequest
+		if err := proto.Unmarshal(req.body, &msg); err != nil {
+			t.Fatal(err)
+		}
+		span := msg.GetResourceSpans()[0].GetScopeSpans()[0].GetSpans()[0]
+		if span.GetName() != "ArticleRepository.FindByID" {
+			t.Errorf("name = %q", span.GetName())
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		r := startHTTPReceiver(t)
+		exp, err := newTraceExporter(ctx, ExporterConfig{Type: ExporterOTLPHTTP, OTLP: r.otlp(OTLPEncodingJSON)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exp.ExportSpans(ctx, testSpans()); err != nil {
+			t.Fatal(err)
+		}
+		_ = exp.Shutdown(ctx)
+
+		got := r.received()
+		if len(got) != 1 {
+			t.Fatalf("received %d requests, want 1", len(got))
+		}
+		req := got[0]
+		if req.path != "/v1/traces" || req.contentType != "application/json" || req.contentEncoding != "gzip" {
+			t.Errorf("path=%q content-type=%q content-encoding=%q", req.path, req.contentType, req.contentEncoding)
+		}
+		if v := req.header.Get("Authorization"); v != "Bearer token" {
+			t.Errorf("Authorization = %q", v)
+		}
+
+		// NOTE: OTLP/JSON では ID は16進文字列、enum は整数値で表現される
+		var payload struct {
+			ResourceSpans []struct {
+				Resource struct {
+					Attributes []struct {
+						Key   string `json:"key"`
+						Value struct {
+							StringValue string `json:"stringValue"`
+						} `json:"value"`
+					} `json:"attributes"`
+				} `json:"resource"`
+				ScopeSpans []struct {
+					Spans []struct {
+						TraceID           string `json:"traceId"`
+						SpanID            string `json:"spanId"`
+						ParentSpanID      string `json:"parentSpanId"`
+						Name              string `json:"name"`
+						Kind              int    `json:"kind"`
+						StartTimeUnixNano string `json:"startTimeUnixNano"`
+					} `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		if err := json.Unmarshal(req.body, &payload); err != nil {
+			t.Fatalf("decode OTLP/JSON: %v\n%s", err, req.body)
+		}
+		rs := payload.ResourceSpans[0]
+		if a := rs.Resource.Attributes[0]; a.Key != "service.name" || a.Value.StringValue != "article-api" {
+			t.Errorf("resource attribute = %+v", a)
+		}
+		span := rs.ScopeSpans[0].Spans[0]
+		want := struct {
+			TraceID, SpanID, ParentSpanID, Name, Start string
+			Kind                                       int
+		}{"0102030405060708090a0b0c0d0e0f10", "1112131415161718", "2122232425262728", "ArticleRepository.FindByID", "1700000000000000000", 3}
+		if span.TraceID != want.TraceID || span.SpanID != want.SpanID || span.ParentSpanID != want.ParentSpanID ||
+			span.Name != want.Name || span.Kind != want.Kind || span.StartTimeUnixNano != want.Start {
+			t.Errorf("span = %+v, want %+v", span, want)
+		}
+	})
+}
+
+func TestOTLPHTTPMetricExporterJSON(t *testing.T) {
+	ctx := context.Background()
+	r := startHTTPReceiver(t)
+	exp, err := newMetricExporter(ctx, ExporterConfig{Type: ExporterOTLPHTTP, OTLP: r.otlp(OTLPEncodingJSON)}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Export(ctx, testResourceMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	_ = exp.Shutdown(ctx)
+
+	got := r.received()
+	if len(got) != 1 {
+		t.Fatalf("received %d requests, want 1", len(got))
+	}
+	if got[0].path != "/v1/metrics" || got[0].contentType != "application/json" {
+		t.Errorf("path=%q content-type=%q", got[0].path, got[0].contentType)
+	}
+	var payload struct {
+		ResourceMetrics []struct {
+			ScopeMetrics []struct {
+				Metrics []struct {
+					Name string `json:"name"`
+					Sum  struct {
+						AggregationTemporality int  `json:"aggregationTemporality"`
+						IsMonotonic            bool `json:"isMonotonic"`
+						DataPoints             []struct {
+							AsInt string `json:"asInt"`
+						} `json:"dataPoints"`
+					} `json:"sum"`
+				} `json:"metrics"`
+			} `json:"scopeMetrics"`
+		} `json:"resourceMetrics"`
+	}
+	if err := json.Unmarshal(got[0].body, &payload); err != nil {
+		t.Fatalf("decode OTLP/JSON: %v\n%s", err, got[0].body)
+	}
+	m := payload.ResourceMetrics[0].ScopeMetrics[0].Metrics[0]
+	if m.Name != "article.views.total" || m.Sum.AggregationTemporality != 2 || !m.Sum.IsMonotonic || m.Sum.DataPoints[0].AsInt != "3" {
+		t.Errorf("metric = %+v", m)
+	}
+}
+
+func TestOTLPHTTPPartialSuccess(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		encoding OTLPEncoding
+		respond  func(w http.ResponseWriter, r httpRequest)
+	}{
+		{
+			name:     "json",
+			encoding: OTLPEncodingJSON,
+			respond: func(w http.ResponseWriter, r httpRequest) {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				if r.path == "/v1/traces" {
+					_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedSpans":"1","errorMessage":"span rejected by collector"}}`))
+				} else {
+					_, _ = w.Write([]byte(`{"partialSuccess":{"rejectedDataPoints":"1","errorMessage":"data point rejected by collector"}}`))
+				}
+			},
+		},
+		{
+			name:     "protobuf",
+			encoding: OTLPEncodingProtobuf,
+			respond: func(w http.ResponseWriter, r httpRequest) {
+				var msg proto.Message = &coltracepb.ExportTraceServiceResponse{PartialSuccess: &coltracepb.ExportTracePartialSuccess{
+					RejectedSpans: 1, ErrorMessage: "span rejected by collector",
+				}}
+				if r.path != "/v1/traces" {
+					msg = &colmetricpb.ExportMetricsServiceResponse{PartialSuccess: &colmetricpb.ExportMetricsPartialSuccess{
+						RejectedDataPoints: 1, ErrorMessage: "data point rejected by collector",
+					}}
+				}
+				b, _ := proto.Marshal(msg)
+				w.Header().Set("Content-Type", "application/x-protobuf")
+				_, _ = w.Write(b)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := startHTTPReceiver(t)
+			r.respond = tt.respond
+			cfg := ExporterConfig{Type: ExporterOTLPHTTP, OTLP: r.otlp(tt.encoding)}
+
+			spanExp, err := newTraceExporter(ctx, cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = spanExp.ExportSpans(ctx, testSpans())
+			if err == nil || !strings.Contains(err.Error(), "span rejected by collector") {
+				t.Errorf("ExportSpans error = %v, want partial success", err)
+			}
+			_ = spanExp.Shutdown(ctx)
+
+			metricExp, err := newMetricExporter(ctx, cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = metricExp.Export(ctx, testResourceMetrics())
+			if err == nil || !strings.Contains(err.Error(), "data point rejected by collector") {
+				t.Errorf("Export error = %v, want partial success", err)
+			}
+			_ = metricExp.Shutdown(ctx)
+		})
+	}
+}
+
+func TestOTLPHTTPJSONIgnoresEmptyAndInvalidResponses(t *testing.T) {
+	ctx := context.Background()
+	for _, body := range []string{"", "{}", "not json"} {
+		r := startHTTPReceiver(t)
+		r.respond = func(w http.ResponseWriter, _ httpRequest) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(body))
+		}
+		exp, err := newTraceExporter(ctx, ExporterConfig{Type: ExporterOTLPHTTP, OTLP: r.otlp(OTLPEncodingJSON)}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := exp.ExportSpans(ctx, testSpans()); err != nil {
+			t.Errorf("response %q: ExportSpans error = %v, want nil", body, err)
+		}
+		_ = exp.Shutdown(ctx)
+	}
+}
@@ -0,0 +1,733 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+)
+
+// NOTE: ディスクへの退避キュー (write-ahead queue)
+// Collector の停止中はバッチのキューが満杯になり、スパン・ログは破棄される (メトリクスはエクスポートのたびに失敗する)。
+// 退避キューを有効にすると、OTLP エクスポーターの送信が一時的なエラー (接続失敗・タイムアウト・429 / 502 / 503 / 504 等) で
+// 失敗した場合に、送信しようとした OTLP のリクエスト (protobuf) をそのままディスクに書き込み、エクスポート自体は成功として扱う。
+// 書き込んだリクエストはバックグラウンドで古い順に再送され、送信先が復旧すると自動的に解消される。
+// ディスク上のファイルはプロセスの再起動後も残り、起動時に再送を再開する。
+//
+// OTLP のリクエストを HTTP の Transport / gRPC の Interceptor で横取りするため、SDK のバッチやリトライの仕組みはそのまま利用できる。
+// 送信先ごとに <Dir>/<signal>/<pipeline> のディレクトリを使用する。
+//   - ファイルの合計サイズが MaxSize を超えた場合は古い順に削除する
+//   - MaxAge を超えたファイルは再送せずに削除する (バックエンドが古いデータを受け付けない場合があるため)
+//   - 送信先が 400 等の再送しても成功しないエラーを返した場合は削除する
+//
+// NOTE: 認証トークン等のヘッダーはディスクに書き込まず、再送時に OTLPConfig.Headers から付与する。
+// gRPC の場合は再送用の接続をキューで保持するため、再起動後も最初のエクスポートを待たずに再送を開始する。
+// NOTE: ディレクトリを走査するのは起動時の1回のみで、以降はファイルの一覧と合計サイズをメモリ上で管理する。
+
+// PersistentQueueConfig はエクスポートに失敗したテレメトリをディスクに退避し、送信先の復旧後に再送する設定
+type PersistentQueueConfig struct {
+	// Dir は退避先のディレクトリ。空の場合は退避キューを使用しない。
+	// OTLP (otlp-grpc / otlp-http) のエクスポーターのみが対象で、stdout / file のエクスポーターには適用されない
+	Dir string
+
+	// MaxSize は送信先ごとの退避ファイルの合計サイズの上限 (バイト)。超過した場合は古い順に削除する。0 の場合は100MB
+	MaxSize int64
+
+	// MaxAge は退避したデータを保持する期間。超過したデータは再送せずに削除する。0 の場合は24時間
+	MaxAge time.Duration
+
+	// RetryInitialInterval は再送に失敗した場合の最初の待機時間。失敗するたびに2倍にし、RetryMaxInterval で頭打ちにする。0 の場合は1秒
+	RetryInitialInterval time.Duration
+
+	// RetryMaxInterval は再送の待機時間の上限。0 の場合は1分
+	RetryMaxInterval time.Duration
+}
+
+const (
+	defaultQueueMaxSize              = 100 * 1024 * 1024
+	defaultQueueMaxAge               = 24 * time.Hour
+	defaultQueueRetryInitialInterval = time.Second
+	defaultQueueRetryMaxInterval     = time.Minute
+
+	// defaultQueueGRPCEndpoint は OTLPConfig.Endpoint 未指定時の gRPC の再送先 (SDK のデフォルトと同じ)
+	defaultQueueGRPCEndpoint = "localhost:4317"
+
+	// defaultQueueReplayTimeout は1回の再送のタイムアウト (OTLPConfig.Timeout 未指定時、SDK のデフォルトと同じ)
+	defaultQueueReplayTimeout = 10 * time.Second
+
+	// queueFileExt は退避ファイルの拡張子 (書き込み中のファイルは queueTempExt)
+	queueFileExt = ".otlp"
+	queueTempExt = ".tmp"
+)
+
+// validate は設定値を検証する
+func (c PersistentQueueConfig) validate() error {
+	if c.MaxSize < 0 || c.MaxAge < 0 || c.RetryInitialInterval < 0 || c.RetryMaxInterval < 0 {
+		return fmt.Errorf("otel: persistent queue settings must not be negative: %+v", c)
+	}
+	return nil
+}
+
+// withDefaults は未指定の値をデフォルト値で埋める
+func (c PersistentQueueConfig) withDefaults() PersistentQueueConfig {
+	if c.MaxSize == 0 {
+		c.MaxSize = defaultQueueMaxSize
+	}
+	if c.MaxAge == 0 {
+		c.MaxAge = defaultQueueMaxAge
+	}
+	if c.RetryInitialInterval == 0 {
+		c.RetryInitialInterval = defaultQueueRetryInitialInterval
+	}
+	if c.RetryMaxInterval == 0 {
+		c.RetryMaxInterval = defaultQueueRetryMaxInterval
+	}
+	c.RetryMaxInterval = max(c.RetryMaxInterval, c.RetryInitialInterval)
+	return c
+}
+
+// queueEntryHeader は退避ファイルの先頭行に書き込むリクエストの情報
+type queueEntryHeader struct {
+	// Target は送信先。HTTP の場合は URL、gRPC の場合はメソッド名
+	Target string `json:"target"`
+
+	// ContentType / ContentEncoding は HTTP のリクエストヘッダー (gRPC の場合は空)
+	ContentType     string `json:"content_type,omitempty"`
+	ContentEncoding string `json:"content_encoding,omitempty"`
+}
+
+// queueEntry はディスク上の1件の退避ファイル
+type queueEntry struct {
+	path    string
+	size    int64
+	created time.Time
+}
+
+// permanentExportError は再送しても成功しないエラー (退避ファイルを削除する)
+type permanentExportError struct{ error }
+
+// persistentQueue は1つの送信先の退避キュー
+type persistentQueue struct {
+	cfg      PersistentQueueConfig
+	otlp     OTLPConfig
+	dir      string
+	signal   string
+	pipeline string
+
+	// send は退避したリクエストを再送する (Transport / Interceptor の生成時に設定する)
+	send func(ctx context.Context, h queueEntryHeader, body []byte) error
+
+	mu    sync.Mutex // NOTE: ディレクトリ内のファイルの追加・削除と files / size の更新を直列化する
+	files []queueEntry
+	size  int64
+	seq   atomic.Uint64
+
+	// degraded は退避を開始してから全て再送し終えるまでの間 true になる (ログを状態の変化時のみ出力するため)
+	degraded atomic.Bool
+	dropped  atomic.Int64
+
+	// replayConn は gRPC の再送に使用する接続 (grpcDialOption で生成し、close で閉じる)
+	replayConn *grpc.ClientConn
+
+	pendingCh   chan struct{} // NOTE: 新しいファイルを退避した
+	recoveredCh chan struct{} // NOTE: 通常のエクスポートが成功した (送信先が復旧した可能性がある)
+	ctx         context.Context
+	cancel      context.CancelFunc
+	doneCh      chan struct{}
+	startOnce   sync.Once
+	closeOnce   sync.Once
+}
+
+// newPersistentQueue は exporter に対する退避キューを生成する。無効な場合や OTLP 以外のエクスポーターの場合は nil を返す
+func newPersistentQueue(cfg PersistentQueueConfig, signal, pipeline string, exporter ExporterConfig) (*persistentQueue, error) {
+	if cfg.Dir == "" || (exporter.Type != ExporterOTLPGRPC && exporter.Type != ExporterOTLPHTTP) {
+		return nil, nil
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(cfg.Dir, signal, strings.ReplaceAll(pipeline, string(os.PathSeparator), "_"))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("otel: create persistent queue directory: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &persistentQueue{
+		cfg:         cfg.withDefaults(),
+		otlp:        exporter.OTLP,
+		dir:         dir,
+		signal:      signal,
+		pipeline:    pipeline,
+		pendingCh:   make(chan struct{}, 1),
+		recoveredCh: make(chan struct{}, 1),
+		ctx:         ctx,
+		cancel:      cancel,
+		doneCh:      make(chan struct{}),
+	}
+	q.removeTempFiles()
+	q.files = q.scan()
+	for _, e := range q.files {
+		q.size += e.size
+	}
+	return q, nil
+}
+
+// start は前回の起動時に退避したファイルの再送を含め、バックグラウンドの再送を開始する
+func (q *persistentQueue) start() {
+	q.startOnce.Do(func() {
+		q.mu.Lock()
+		files := len(q.files)
+		q.mu.Unlock()
+		if files > 0 {
+			q.degraded.Store(true)
+			slog.InfoContext(q.ctx, "otel: resuming replay of persisted telemetry", q.logAttrs(slog.Int("files", files))...)
+		}
+		go q.run()
+	})
+}
+
+// close はバックグラウンドの再送を停止する。未送信のファイルはディスクに残り、次回の起動時に再送される
+func (q *persistentQueue) close() {
+	if q == nil {
+		return
+	}
+	q.closeOnce.Do(func() {
+		q.cancel()
+		q.startOnce.Do(func() { close(q.doneCh) }) // NOTE: start 前に閉じた場合は待機しない
+		<-q.doneCh
+		if q.replayConn != nil {
+			_ = q.replayConn.Close()
+		}
+	})
+}
+
+// logAttrs はログに付与する属性を返す
+func (q *persistentQueue) logAttrs(attrs ...any) []any {
+	return append([]any{
+		slog.String("signal", q.signal),
+		slog.String("pipeline", q.pipeline),
+		slog.String("dir", q.dir),
+	}, attrs...)
+}
+
+// notify は ch にシグナルを送る (既に送信済みの場合は何もしない)
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// =======================================================
+// 退避ファイル
+// =======================================================
+
+// persist はリクエストをディスクに書き込む
+//
+// NOTE: 一時ファイルに書き込んでからリネームするため、書き込み中にプロセスが停止しても不完全なファイルは再送されない。
+func (q *persistentQueue) persist(h queueEntryHeader, body []byte, cause error) error {
+	header, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%020d-%06d", now.UnixNano(), q.seq.Add(1)%1000000)
+	tmp := filepath.Join(q.dir, name+queueTempExt)
+	data := slices.Concat(header, []byte("\n"), body)
+	entry := queueEntry{path: filepath.Join(q.dir, name+queueFileExt), size: int64(len(data)), created: now}
+
+	q.mu.Lock()
+	err = os.WriteFile(tmp, data, 0o600)
+	if err == nil {
+		err = os.Rename(tmp, entry.path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		q.mu.Unlock()
+		return fmt.Errorf("otel: write persistent queue: %w", err)
+	}
+	// NOTE: 同時に退避した場合も古い順を保つよう、ファイル名の順の位置に追加する
+	i, _ := slices.BinarySearchFunc(q.files, entry, compareQueueEntries)
+	q.files = slices.Insert(q.files, i, entry)
+	q.size += entry.size
+	q.enforceLimitsLocked()
+	q.mu.Unlock()
+
+	if !q.degraded.Swap(true) {
+		slog.WarnContext(q.ctx, "otel: export failed; persisting telemetry to disk until the endpoint recovers",
+			q.logAttrs(slog.String("error", cause.Error()))...)
+	}
+	notify(q.pendingCh)
+	return nil
+}
+
+// scan はディレクトリを走査し、退避ファイルを古い順に返す (起動時のみ呼ぶ)
+func (q *persistentQueue) scan() []queueEntry {
+	dirEntries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil
+	}
+	var entries []queueEntry
+	for _, de := range dirEntries {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, queueFileExt) {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		nanos, _, _ := strings.Cut(name, "-")
+		n, err := strconv.ParseInt(nanos, 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, queueEntry{
+			path:    filepath.Join(q.dir, name),
+			size:    info.Size(),
+			created: time.Unix(0, n),
+		})
+	}
+	// NOTE: ファイル名は作成時刻 (ナノ秒) を0埋めしているため、名前順が古い順になる
+	slices.SortFunc(entries, compareQueueEntries)
+	return entries
+}
+
+// compareQueueEntries は退避ファイルを古い順に並べる比較関数
+func compareQueueEntries(a, b queueEntry) int { return strings.Compare(a.path, b.path) }
+
+// oldest は最も古い退避ファイルを返す
+func (q *persistentQueue) oldest() (queueEntry, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.files) == 0 {
+		return queueEntry{}, false
+	}
+	return q.files[0], true
+}
+
+// enforceLimitsLocked は MaxAge を超えたファイルと、MaxSize を超えた分の古いファイルを削除する (q.mu を保持して呼ぶ)
+//
+// NOTE: 削除に失敗したファイルも一覧からは除外する (ディスクに残ったファイルは次回の起動時に再送または削除される)
+func (q *persistentQueue) enforceLimitsLocked() {
+	now := time.Now()
+	var dropped int
+	for _, e := range q.files {
+		if now.Sub(e.created) <= q.cfg.MaxAge && q.size <= q.cfg.MaxSize {
+			break
+		}
+		_ = os.Remove(e.path)
+		q.size -= e.size
+		dropped++
+	}
+	q.files = slices.Delete(q.files, 0, dropped)
+	if dropped > 0 && q.dropped.Add(int64(dropped)) == int64(dropped) {
+		// NOTE: 送信先が停止している間は退避のたびに削除が発生するため、復旧するまでの最初の1回のみ警告する
+		slog.WarnContext(q.ctx, "otel: persistent queue exceeded its size or age limit; dropping the oldest telemetry",
+			q.logAttrs(slog.Int64("max_size", q.cfg.MaxSize), slog.Duration("max_age", q.cfg.MaxAge))...)
+	}
+}
+
+// remove は退避ファイルを削除する (上限の超過で削除済みの場合は何もしない)
+func (q *persistentQueue) remove(e queueEntry) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, ok := slices.BinarySearchFunc(q.files, e, compareQueueEntries)
+	if !ok {
+		return
+	}
+	_ = os.Remove(e.path)
+	q.files = slices.Delete(q.files, i, i+1)
+	q.size -= e.size
+}
+
+// removeTempFiles は書き込み中にプロセスが停止した一時ファイルを削除する
+func (q *persistentQueue) removeTempFiles() {
+	tmps, _ := filepath.Glob(filepath.Join(q.dir, "*"+queueTempExt))
+	for _, tmp := range tmps {
+		_ = os.Remove(tmp)
+	}
+}
+
+// read は退避ファイルのヘッダーとリクエストボディを読み取る
+func (e queueEntry) read() (queueEntryHeader, []byte, error) {
+	var h queueEntryHeader
+	b, err := os.ReadFile(e.path)
+	if err != nil {
+		return h, nil, err
+	}
+	header, body, ok := bytes.Cut(b, []byte("\n"))
+	if !ok {
+		return h, nil, permanentExportError{errors.New("otel: persistent queue file is corrupted")}
+	}
+	if err := json.Unmarshal(header, &h); err != nil {
+		return h, nil, permanentExportError{fmt.Errorf("otel: persistent queue file is corrupted: %w", err)}
+	}
+	return h, body, nil
+}
+
+// =======================================================
+// 再送
+// =======================================================
+
+// run は退避ファイルを古い順に再送する。失敗した場合は指数バックオフで待機してから再試行する
+func (q *persistentQueue) run() {
+	defer close(q.doneCh)
+
+	backoff := q.cfg.RetryInitialInterval
+	for {
+		q.mu.Lock()
+		q.enforceLimitsLocked()
+		q.mu.Unlock()
+
+		entry, ok := q.oldest()
+		if !ok {
+			if q.degraded.Swap(false) {
+				slog.InfoContext(q.ctx, "otel: persisted telemetry has been replayed",
+					q.logAttrs(slog.Int64("dropped_files", q.dropped.Swap(0)))...)
+			}
+			select {
+			case <-q.pendingCh:
+			case <-q.recoveredCh:
+			case <-q.ctx.Done():
+				return
+			}
+			continue
+		}
+
+		err := q.replay(entry)
+		var permanent permanentExportError
+		switch {
+		case err == nil:
+			q.remove(entry)
+			backoff = q.cfg.RetryInitialInterval
+			continue
+		case errors.As(err, &permanent):
+			slog.WarnContext(q.ctx, "otel: dropping persisted telemetry rejected by the endpoint",
+				q.logAttrs(slog.String("error", err.Error()))...)
+			q.remove(entry)
+			continue
+		case q.ctx.Err() != nil:
+			return
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+			backoff = min(backoff*2, q.cfg.RetryMaxInterval)
+		case <-q.recoveredCh:
+			// NOTE: 通常のエクスポートが成功した場合は送信先が復旧したとみなし、待機を打ち切って再送する
+			timer.Stop()
+			backoff = q.cfg.RetryInitialInterval
+		case <-q.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// replay は1件の退避ファイルを再送する
+func (q *persistentQueue) replay(e queueEntry) error {
+	h, body, err := e.read()
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil // NOTE: 上限の超過で削除済み
+		}
+		return err
+	}
+	timeout := q.otlp.Timeout
+	if timeout <= 0 {
+		timeout = defaultQueueReplayTimeout
+	}
+	ctx, cancel := context.WithTimeout(q.ctx, timeout)
+	defer cancel()
+	return q.send(ctx, h, body)
+}
+
+// =======================================================
+// OTLP/HTTP
+// =======================================================
+
+// queueTransport は一時的なエラーで失敗したリクエストを persistentQueue に退避する http.RoundTripper
+type queueTransport struct {
+	next  http.RoundTripper
+	queue *persistentQueue
+}
+
+// httpTransport は next の手前で失敗したリクエストを退避する http.RoundTripper を返し、再送にも next を使用する
+func (q *persistentQueue) httpTransport(next http.RoundTripper) http.RoundTripper {
+	q.send = func(ctx context.Context, h queueEntryHeader, body []byte) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Target, bytes.NewReader(body))
+		if err != nil {
+			return permanentExportError{err}
+		}
+		for k, v := range q.otlp.Headers {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Type", h.ContentType)
+		if h.ContentEncoding != "" {
+			req.Header.Set("Content-Encoding", h.ContentEncoding)
+		}
+		resp, err := next.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+			return nil
+		case retryableHTTPStatus(resp.StatusCode):
+			return fmt.Errorf("otel: replay persisted telemetry: %s", resp.Status)
+		default:
+			return permanentExportError{fmt.Errorf("otel: replay persisted telemetry: %s", resp.Status)}
+		}
+	}
+	return &queueTransport{next: next, queue: q}
+}
+
+// retryableHTTPStatus は再送すれば成功する可能性があるステータスコードかを返す (SDK のリトライ対象と同じ)
+func retryableHTTPStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// RoundTrip はリクエストを送信し、接続エラーまたは再送対象のステータスコードの場合はディスクに退避して成功のレスポンスを返す
+func (t *queueTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: RoundTripper は元のリクエストを変更してはならないため、複製したリクエストにボディを設定する
+	r := req.Clone(req.Context())
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	resp, err := t.next.RoundTrip(r)
+	if err == nil && !retryableHTTPStatus(resp.StatusCode) {
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			notify(t.queue.recoveredCh)
+		}
+		return resp, nil
+	}
+
+	cause := err
+	if err == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		cause = fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	h := queueEntryHeader{
+		Target:          req.URL.String(),
+		ContentType:     req.Header.Get("Content-Type"),
+		ContentEncoding: req.Header.Get("Content-Encoding"),
+	}
+	if perr := t.queue.persist(h, body, cause); perr != nil {
+		return nil, errors.Join(cause, perr)
+	}
+	// NOTE: 空のボディは SDK で部分的な成功 (partial success) なしとして扱われる
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
+
+// =======================================================
+// OTLP/gRPC
+// =======================================================
+
+// queueReplayKey は再送中の呼び出しであることを Interceptor に伝える context のキー
+type queueReplayKey struct{}
+
+// grpcDialOption は失敗したリクエストを退避する Interceptor を設定する grpc.DialOption を返す
+//
+// NOTE: エクスポーターの接続は最初のエクスポートまで取得できないため、再送には creds で接続する専用の接続を使用する。
+// grpc.NewClient は接続を遅延して確立するため、送信先が停止していてもエラーにならない。
+func (q *persistentQueue) grpcDialOption(creds credentials.TransportCredentials) (grpc.DialOption, error) {
+	endpoint := q.otlp.Endpoint
+	if endpoint == "" {
+		endpoint = defaultQueueGRPCEndpoint
+	}
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("otel: create persistent queue gRPC connection: %w", err)
+	}
+	q.replayConn = conn
+
+	newRequest, newResponse := otlpMessages(q.signal)
+	q.send = func(ctx context.Context, h queueEntryHeader, body []byte) error {
+		req := newRequest()
+		if err := proto.Unmarshal(body, req); err != nil {
+			return permanentExportError{fmt.Errorf("otel: decode persisted telemetry: %w", err)}
+		}
+		ctx = context.WithValue(ctx, queueReplayKey{}, true)
+		if len(q.otlp.Headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(q.otlp.Headers))
+		}
+		var opts []grpc.CallOption
+		if q.otlp.Compression == "gzip" {
+			opts = append(opts, grpc.UseCompressor("gzip"))
+		}
+		err := q.replayConn.Invoke(ctx, h.Target, req, newResponse(), opts...)
+		if err != nil && !retryableGRPCCode(status.Code(err)) {
+			return permanentExportError{err}
+		}
+		return err
+	}
+	return grpc.WithChainUnaryInterceptor(q.unaryInterceptor), nil
+}
+
+// retryableGRPCCode は再送すれば成功する可能性があるステータスコードかを返す (SDK のリトライ対象と同じ)
+func retryableGRPCCode(code codes.Code) bool {
+	switch code {
+	case codes.Canceled, codes.DeadlineExceeded, codes.Aborted, codes.OutOfRange,
+		codes.Unavailable, codes.DataLoss, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
+
+// unaryInterceptor はリクエストを送信し、再送対象のエラーの場合はディスクに退避して成功として扱う
+func (q *persistentQueue) unaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if ctx.Value(queueReplayKey{}) != nil {
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if err == nil {
+		notify(q.recoveredCh)
+		return nil
+	}
+	msg, ok := req.(proto.Message)
+	if !ok || !retryableGRPCCode(status.Code(err)) {
+		return err
+	}
+	body, merr := proto.Marshal(msg)
+	if merr != nil {
+		return errors.Join(err, merr)
+	}
+	if perr := q.persist(queueEntryHeader{Target: method}, body, err); perr != nil {
+		return errors.Join(err, perr)
+	}
+	return nil
+}
+
+// otlpMessages はシグナルごとの OTLP のリクエストとレスポンスの型を返す
+func otlpMessages(signal string) (newRequest, newResponse func() proto.Message) {
+	switch signal {
+	case signalMetrics:
+		return func() proto.Message { return &colmetricpb.ExportMetricsServiceRequest{} },
+			func() proto.Message { return &colmetricpb.ExportMetricsServiceResponse{} }
+	case signalLogs:
+		return func() proto.Message { return &collogspb.ExportLogsServiceRequest{} },
+			func() proto.Message { return &collogspb.ExportLogsServiceResponse{} }
+	default:
+		return func() proto.Message { return &coltracepb.ExportTraceServiceRequest{} },
+			func() proto.Message { return &coltracepb.ExportTraceServiceResponse{} }
+	}
+}
+
+// =======================================================
+// エクスポーター
+// =======================================================
+
+// NOTE: エクスポーターのシャットダウン時に再送を停止する。未送信のファイルはディスクに残り、次回の起動時に再送される。
+
+// wrapSpanExporter は再送を開始し、シャットダウン時に再送を停止するよう e をラップする (q が nil の場合は e をそのまま返す)
+func (q *persistentQueue) wrapSpanExporter(e sdktrace.SpanExporter) sdktrace.SpanExporter {
+	if q == nil {
+		return e
+	}
+	q.start()
+	return &queuedSpanExporter{SpanExporter: e, queue: q}
+}
+
+// wrapMetricExporter は再送を開始し、シャットダウン時に再送を停止するよう e をラップする (q が nil の場合は e をそのまま返す)
+func (q *persistentQueue) wrapMetricExporter(e sdkmetric.Exporter) sdkmetric.Exporter {
+	if q == nil {
+		return e
+	}
+	q.start()
+	return &queuedMetricExporter{Exporter: e, queue: q}
+}
+
+// wrapLogExporter は再送を開始し、シャットダウン時に再送を停止するよう e をラップする (q が nil の場合は e をそのまま返す)
+func (q *persistentQueue) wrapLogExporter(e sdklog.Exporter) sdklog.Exporter {
+	if q == nil {
+		return e
+	}
+	q.start()
+	return &queuedLogExporter{Exporter: e, queue: q}
+}
+
+// queuedSpanExporter はシャットダウン時に退避キューの再送を停止する sdktrace.SpanExporter
+type queuedSpanExporter struct {
+	sdktrace.SpanExporter
+	queue *persistentQueue
+}
+
+// Shutdown は再送を停止してからエクスポーターを停止する
+func (e *queuedSpanExporter) Shutdown(ctx context.Context) error {
+	e.queue.close()
+	return e.SpanExporter.Shutdown(ctx)
+}
+
+// queuedMetricExporter はシャットダウン時に退避キューの再送を停止する sdkmetric.Exporter
+type queuedMetricExporter struct {
+	sdkmetric.Exporter
+	queue *persistentQueue
+}
+
+// Shutdown は再送を停止してからエクスポーターを停止する
+func (e *queuedMetricExporter) Shutdown(ctx context.Context) error {
+	e.queue.close()
+	return e.Exporter.Shutdown(ctx)
+}
+
+// queuedLogExporter はシャットダウン時に退避キューの再送を停止する sdklog.Exporter
+type queuedLogExporter struct {
+	sdklog.Exporter
+	queue *persistentQueue
+}
+
+// Shutdown は再送を停止してからエクスポーターを停止する
+func (e *queuedLogExporter) Shutdown(ctx context.Context) error {
+	e.queue.close()
+	return e.Exporter.Shutdown(ctx)
+}
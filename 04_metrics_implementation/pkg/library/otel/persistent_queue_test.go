@@ -0,0 +1,195 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/proto"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// newTestQueue は dir を退避先とする OTLP の送信先の退避キューを生成する
+func newTestQueue(t *testing.T, cfg PersistentQueueConfig, exporter ExporterConfig) *persistentQueue {
+	t.Helper()
+	q, err := newPersistentQueue(cfg, signalTraces, "otlp", exporter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(q.close)
+	return q
+}
+
+// waitUntil は cond が true になるまで待機する
+func waitUntil(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// queueFiles はディスク上の退避ファイルの数と合計サイズを返す
+func queueFiles(t *testing.T, q *persistentQueue) (int, int64) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(q.dir, "*"+queueFileExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size += info.Size()
+	}
+	return len(paths), size
+}
+
+// queueLen はメモリ上で管理している退避ファイルの数を返す
+func queueLen(q *persistentQueue) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.files)
+}
+
+func TestPersistentQueueTracksFilesInMemory(t *testing.T) {
+	// NOTE: 1件のファイルはヘッダー {"target":"t"} (14バイト) + 改行 + ボディ 100バイト = 115バイト
+	cfg := PersistentQueueConfig{Dir: t.TempDir(), MaxSize: 250}
+	exporter := ExporterConfig{Type: ExporterOTLPHTTP}
+	q := newTestQueue(t, cfg, exporter)
+
+	body := make([]byte, 100)
+	for range 3 {
+		if err := q.persist(queueEntryHeader{Target: "t"}, body, errors.New("unavailable")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, size := queueFiles(t, q)
+	if files != 2 || size != 230 {
+		t.Fatalf("disk has %d files (%d bytes), want 2 files (230 bytes) after dropping the oldest", files, size)
+	}
+	q.mu.Lock()
+	if len(q.files) != files || q.size != size {
+		t.Errorf("in-memory index has %d files (%d bytes), want %d files (%d bytes)", len(q.files), q.size, files, size)
+	}
+	q.mu.Unlock()
+
+	// NOTE: 再起動後は起動時の1回の走査で一覧を復元し、書き込み中の一時ファイルは削除する
+	tmp := filepath.Join(q.dir, "00000000000000000001-000001"+queueTempExt)
+	if err := os.WriteFile(tmp, body, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	restarted := newTestQueue(t, cfg, exporter)
+	restarted.mu.Lock()
+	if len(restarted.files) != files || restarted.size != size {
+		t.Errorf("restarted queue has %d files (%d bytes), want %d files (%d bytes)", len(restarted.files), restarted.size, files, size)
+	}
+	restarted.mu.Unlock()
+	if _, err := os.Stat(tmp); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("temporary file was not removed at startup: %v", err)
+	}
+}
+
+func TestPersistentQueueReplaysHTTPWithBackoff(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	q := newTestQueue(t, PersistentQueueConfig{Dir: t.TempDir(), RetryInitialInterval: 10 * time.Millisecond}, ExporterConfig{Type: ExporterOTLPHTTP})
+	q.httpTransport(http.DefaultTransport)
+	h := queueEntryHeader{Target: srv.URL + "/v1/traces", ContentType: "application/x-protobuf"}
+	if err := q.persist(h, []byte("spans"), errors.New("unavailable")); err != nil {
+		t.Fatal(err)
+	}
+	q.start()
+
+	waitUntil(t, func() bool { return queueLen(q) == 0 })
+	if got := requests.Load(); got != 2 {
+		t.Errorf("endpoint received %d requests, want 2 (503 then 200)", got)
+	}
+	if files, _ := queueFiles(t, q); files != 0 {
+		t.Errorf("disk still has %d files after the replay", files)
+	}
+}
+
+// traceReceiver は受信した OTLP/gRPC のトレースのリクエストを ch に送る TraceServiceServer
+type traceReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+	ch chan *coltracepb.ExportTraceServiceRequest
+}
+
+// Export は受信したリクエストを ch に送る
+func (r *traceReceiver) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	r.ch <- req
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+func TestPersistentQueueReplaysGRPCWithoutLiveExport(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := &traceReceiver{ch: make(chan *coltracepb.ExportTraceServiceRequest, 1)}
+	srv := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(srv, receiver)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	cfg := PersistentQueueConfig{Dir: t.TempDir()}
+	exporter := ExporterConfig{
+		Type: ExporterOTLPGRPC,
+		OTLP: OTLPConfig{Endpoint: lis.Addr().String(), Insecure: true},
+	}
+
+	// NOTE: 前回の起動時に退避したリクエスト
+	body, err := proto.Marshal(&coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{SchemaUrl: "persisted"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := newTestQueue(t, cfg, exporter)
+	if err := previous.persist(queueEntryHeader{Target: "/opentelemetry.proto.collector.trace.v1.TraceService/Export"}, body, errors.New("unavailable")); err != nil {
+		t.Fatal(err)
+	}
+	previous.close()
+
+	q := newTestQueue(t, cfg, exporter)
+	if _, err := q.grpcDialOption(insecure.NewCredentials()); err != nil {
+		t.Fatal(err)
+	}
+	q.start()
+
+	select {
+	case req := <-receiver.ch:
+		if got := req.GetResourceSpans()[0].GetSchemaUrl(); got != "persisted" {
+			t.Errorf("replayed schema_url = %q, want %q", got, "persisted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("persisted request was not replayed before any live export")
+	}
+	waitUntil(t, func() bool { return queueLen(q) == 0 })
+}
@@ -0,0 +1,573 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NOTE: エクスポートのパイプライン
+// 1つのシグナルを複数のエクスポート先に同時に送信する (ファンアウト) ための設定。
+// パイプラインごとに独立したバッチ (キュー) と PeriodicReader を持つため、1つのエクスポート先が停止・遅延しても他のエクスポート先には影響しない。
+//
+// 例: 開発環境で標準出力とローカルの Collector の両方に送信し、エラーのスパンだけをファイルにも残す
+//
+//	TracePipelines: []TracePipeline{
+//	    {Exporter: ExporterConfig{Type: ExporterStdout}},
+//	    {Exporter: ExporterConfig{Type: ExporterOTLPGRPC, OTLP: OTLPConfig{Insecure: true}}},
+//	    {Name: "errors", Exporter: ExporterConfig{Type: ExporterFile, File: FileConfig{Path: "errors.jsonl"}},
+//	        Filter: SpanFilter{Statuses: []SpanStatus{SpanStatusError}}},
+//	}
+//
+// パイプラインを指定しない場合は Config.TraceExporter / MetricExporter / LogExporter の1つのパイプラインとして扱う。
+// リダクション・テールサンプリング・Baggage の付与は全てのパイプラインに共通で、パイプラインに振り分ける前に適用される。
+
+// TracePipeline はスパンのエクスポート先ごとの設定
+type TracePipeline struct {
+	// Name は内部メトリクスの pipeline 属性とエラーメッセージに使用する名前。未指定の場合は Exporter.Type (空の場合は stdout)
+	Name string
+
+	// Exporter はエクスポート先。ExporterNone の場合はパイプラインを作成しない
+	Exporter ExporterConfig
+
+	// Batch はバッチの設定。未指定の場合は5秒または512件ごとにエクスポートする
+	Batch BatchConfig
+
+	// Filter はこのパイプラインでエクスポートするスパンの条件。未指定の場合は全てのスパンをエクスポートする
+	Filter SpanFilter
+}
+
+// MetricPipeline はメトリクスのエクスポート先ごとの設定
+type MetricPipeline struct {
+	// Name は内部メトリクスの pipeline 属性とエラーメッセージに使用する名前。未指定の場合は Exporter.Type (空の場合は stdout)
+	Name string
+
+	// Exporter はエクスポート先。ExporterNone の場合はパイプラインを作成しない
+	Exporter ExporterConfig
+
+	// Interval はこのパイプラインの収集・エクスポート間隔。0 の場合は Config.MetricExportInterval
+	Interval time.Duration
+
+	// Filter はこのパイプラインでエクスポートするメトリクスの条件。未指定の場合は全てのメトリクスをエクスポートする
+	Filter MetricFilter
+}
+
+// LogPipeline はログ (OTel LogRecord) のエクスポート先ごとの設定
+type LogPipeline struct {
+	// Name は内部メトリクスの pipeline 属性とエラーメッセージに使用する名前。未指定の場合は Exporter.Type
+	Name string
+
+	// Exporter はエクスポート先。ExporterNone の場合はパイプラインを作成しない
+	Exporter ExporterConfig
+
+	// Batch はバッチの設定。未指定の場合は1秒または512件ごとにエクスポートする
+	Batch BatchConfig
+}
+
+// BatchConfig はバッチプロセッサーの設定
+type BatchConfig struct {
+	// Timeout は最後のエクスポートからバッチをフラッシュするまでの時間。0 の場合はトレースは5秒、ログは1秒
+	Timeout time.Duration
+
+	// MaxExportBatchSize はこの件数に達した時点で即座にエクスポートする件数。0 の場合は512
+	MaxExportBatchSize int
+
+	// MaxQueueSize はエクスポート待ちのキューの上限。超過したアイテムは破棄される。0 の場合は2048
+	MaxQueueSize int
+}
+
+const (
+	defaultSpanBatchTimeout   = 5 * time.Second
+	defaultLogBatchTimeout    = time.Second
+	defaultMaxExportBatchSize = 512
+)
+
+// validate は負の値が指定されていないかを検証する
+func (c BatchConfig) validate() error {
+	if c.Timeout < 0 || c.MaxExportBatchSize < 0 || c.MaxQueueSize < 0 {
+		return fmt.Errorf("batch settings must not be negative: %+v", c)
+	}
+	return nil
+}
+
+// queueSize はキューの上限を返す
+func (c BatchConfig) queueSize() int {
+	if c.MaxQueueSize == 0 {
+		return defaultMaxQueueSize
+	}
+	return c.MaxQueueSize
+}
+
+// batchSize は1回のエクスポートの最大件数を返す
+func (c BatchConfig) batchSize() int {
+	if c.MaxExportBatchSize == 0 {
+		return defaultMaxExportBatchSize
+	}
+	return c.MaxExportBatchSize
+}
+
+// spanOptions は BatchSpanProcessor のオプションに変換する
+func (c BatchConfig) spanOptions() []sdktrace.BatchSpanProcessorOption {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultSpanBatchTimeout
+	}
+	return []sdktrace.BatchSpanProcessorOption{
+		sdktrace.WithBatchTimeout(timeout),
+		sdktrace.WithMaxExportBatchSize(c.batchSize()),
+		sdktrace.WithMaxQueueSize(c.queueSize()),
+	}
+}
+
+// logOptions は ログの BatchProcessor のオプションに変換する
+func (c BatchConfig) logOptions() []sdklog.BatchProcessorOption {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultLogBatchTimeout
+	}
+	return []sdklog.BatchProcessorOption{
+		sdklog.WithExportInterval(timeout),
+		sdklog.WithExportMaxBatchSize(c.batchSize()),
+		sdklog.WithMaxQueueSize(c.queueSize()),
+	}
+}
+
+// pipelineName はパイプラインの名前を返す。未指定の場合はエクスポーターの種類
+func pipelineName(name string, exporter ExporterConfig) string {
+	switch {
+	case name != "":
+		return name
+	case exporter.Type != "":
+		return string(exporter.Type)
+	default:
+		return string(ExporterStdout)
+	}
+}
+
+// tracePipelines は TracePipelines を返す。未指定の場合は TraceExporter の1つのパイプライン
+func (c Config) tracePipelines() []TracePipeline {
+	if len(c.TracePipelines) > 0 {
+		return c.TracePipelines
+	}
+	return []TracePipeline{{Exporter: c.TraceExporter}}
+}
+
+// metricPipelines は MetricPipelines を返す。未指定の場合は MetricExporter の1つのパイプライン
+func (c Config) metricPipelines() []MetricPipeline {
+	if len(c.MetricPipelines) > 0 {
+		return c.MetricPipelines
+	}
+	return []MetricPipeline{{Exporter: c.MetricExporter}}
+}
+
+// logPipelines は LogPipelines を返す。未指定の場合は LogExporter の1つのパイプライン
+func (c Config) logPipelines() []LogPipeline {
+	if len(c.LogPipelines) > 0 {
+		return c.LogPipelines
+	}
+	return []LogPipeline{{Exporter: c.LogExporter}}
+}
+
+// =======================================================
+// フィルタ
+// =======================================================
+
+// SpanStatus はスパンのステータス
+type SpanStatus string
+
+const (
+	// SpanStatusUnset はステータスが設定されていないスパン (正常終了したスパンの大半)
+	SpanStatusUnset SpanStatus = "unset"
+	// SpanStatusOK は明示的に OK が設定されたスパン
+	SpanStatusOK SpanStatus = "ok"
+	// SpanStatusError はエラーが設定されたスパン
+	SpanStatusError SpanStatus = "error"
+)
+
+// code は SpanStatus を codes.Code に変換する
+func (s SpanStatus) code() (codes.Code, error) {
+	switch s {
+	case SpanStatusUnset:
+		return codes.Unset, nil
+	case SpanStatusOK:
+		return codes.Ok, nil
+	case SpanStatusError:
+		return codes.Error, nil
+	default:
+		return 0, fmt.Errorf("unsupported span status %q", s)
+	}
+}
+
+// SpanFilter はパイプラインでエクスポートするスパンの条件。指定した条件の全てに一致するスパンのみをエクスポートする
+type SpanFilter struct {
+	// Names はスパン名のパターン。いずれかに一致するスパンのみを対象とする。
+	// "*" (任意の文字列) と "?" (任意の1文字) のワイルドカードが使用できる (例: "Article*", "GET /articles/*")
+	Names []string
+
+	// Statuses はステータス。いずれかに一致するスパンのみを対象とする (例: エラーのスパンのみの場合は SpanStatusError)
+	Statuses []SpanStatus
+}
+
+// MetricFilter はパイプラインでエクスポートするメトリクスの条件
+type MetricFilter struct {
+	// Instruments は計器名 (View で Rename した場合は変更後の名前) のパターン。いずれかに一致するメトリクスのみをエクスポートする。
+	// MetricView.Instrument と同じく "*" と "?" のワイルドカードが使用できる (例: "article.*", "http.server.*")
+	Instruments []string
+}
+
+// compileWildcards は "*" と "?" のワイルドカードを含むパターンを1つの正規表現に変換する (パターンが無い場合は nil)
+func compileWildcards(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	alternatives := make([]string, len(patterns))
+	for i, p := range patterns {
+		if p == "" {
+			return nil, errors.New("pattern must not be empty")
+		}
+		quoted := regexp.QuoteMeta(p)
+		quoted = strings.ReplaceAll(quoted, `\*`, `.*`)
+		quoted = strings.ReplaceAll(quoted, `\?`, `.`)
+		alternatives[i] = quoted
+	}
+	return regexp.Compile(`^(?:` + strings.Join(alternatives, "|") + `)$`)
+}
+
+// spanFilter は SpanFilter を判定できる形に変換したもの
+type spanFilter struct {
+	names    *regexp.Regexp
+	statuses []codes.Code
+}
+
+// compile は SpanFilter を検証して spanFilter に変換する。条件が無い場合は nil を返す
+func (f SpanFilter) compile() (*spanFilter, error) {
+	if len(f.Names) == 0 && len(f.Statuses) == 0 {
+		return nil, nil
+	}
+	names, err := compileWildcards(f.Names)
+	if err != nil {
+		return nil, fmt.Errorf("span name filter: %w", err)
+	}
+	c := &spanFilter{names: names}
+	for _, s := range f.Statuses {
+		code, err := s.code()
+		if err != nil {
+			return nil, err
+		}
+		c.statuses = append(c.statuses, code)
+	}
+	return c, nil
+}
+
+// match はスパンが全ての条件に一致するかを返す
+func (f *spanFilter) match(s sdktrace.ReadOnlySpan) bool {
+	if f.names != nil && !f.names.MatchString(s.Name()) {
+		return false
+	}
+	if len(f.statuses) > 0 {
+		code := s.Status().Code
+		for _, c := range f.statuses {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// filterSpanProcessor は spanFilter に一致したスパンだけを next に渡す sdktrace.SpanProcessor
+//
+// NOTE: ステータスはスパンの終了時に確定するため、判定は OnEnd で行う。OnStart は全てのスパンを next に渡す。
+type filterSpanProcessor struct {
+	sdktrace.SpanProcessor
+	filter *spanFilter
+}
+
+// OnEnd は条件に一致したスパンのみを渡す
+func (p *filterSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.filter.match(s) {
+		p.SpanProcessor.OnEnd(s)
+	}
+}
+
+// filterMetricExporter は条件に一致したメトリクスだけをエクスポートする sdkmetric.Exporter
+type filterMetricExporter struct {
+	sdkmetric.Exporter
+	instruments *regexp.Regexp
+}
+
+// Export は条件に一致したメトリクスのみを含む ResourceMetrics をエクスポートする
+//
+// NOTE: PeriodicReader は rm を次回の収集で再利用するため、rm 自体は変更せずにコピーを渡す。
+func (e *filterMetricExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	filtered := &metricdata.ResourceMetrics{Resource: rm.Resource}
+	for _, sm := range rm.ScopeMetrics {
+		var metrics []metricdata.Metrics
+		for _, m := range sm.Metrics {
+			if e.instruments.MatchString(m.Name) {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) > 0 {
+			filtered.ScopeMetrics = append(filtered.ScopeMetrics, metricdata.ScopeMetrics{Scope: sm.Scope, Metrics: metrics})
+		}
+	}
+	return e.Exporter.Export(ctx, filtered)
+}
+
+// =======================================================
+// パイプラインの生成
+// =======================================================
+
+// newSpanPipelines はパイプラインごとに BatchSpanProcessor を生成し、全てのパイプラインにスパンを渡す1つの SpanProcessor にまとめる
+//
+// ExporterNone のパイプラインは作成せず、全てのパイプラインが ExporterNone の場合は nil を返す。
+// queue が有効な場合は OTLP のパイプラインごとに退避キューを使用する (persistent_queue.go 参照)。
+func (m *pipelineMetrics) newSpanPipelines(ctx context.Context, pipelines []TracePipeline, queue PersistentQueueConfig) (sdktrace.SpanProcessor, error) {
+	var processors fanoutSpanProcessor
+	names := make(map[string]struct{}, len(pipelines))
+	for _, pl := range pipelines {
+		if pl.Exporter.Type == ExporterNone {
+			continue
+		}
+		name := pipelineName(pl.Name, pl.Exporter)
+		processor, err := m.newSpanPipeline(ctx, name, pl, queue, names)
+		if err != nil {
+			_ = processors.Shutdown(ctx)
+			return nil, fmt.Errorf("otel: trace pipeline %q: %w", name, err)
+		}
+		processors = append(processors, processor)
+	}
+	switch len(processors) {
+	case 0:
+		return nil, nil
+	case 1:
+		return processors[0], nil
+	default:
+		return processors, nil
+	}
+}
+
+// newSpanPipeline は1つのパイプラインの SpanProcessor を生成する
+func (m *pipelineMetrics) newSpanPipeline(ctx context.Context, name string, pl TracePipeline, queueCfg PersistentQueueConfig, names map[string]struct{}) (sdktrace.SpanProcessor, error) {
+	if _, ok := names[name]; ok {
+		return nil, errors.New("duplicate pipeline name")
+	}
+	names[name] = struct{}{}
+	if err := pl.Batch.validate(); err != nil {
+		return nil, err
+	}
+	filter, err := pl.Filter.compile()
+	if err != nil {
+		return nil, err
+	}
+	queue, err := newPersistentQueue(queueCfg, signalTraces, name, pl.Exporter)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newTraceExporter(ctx, pl.Exporter, queue)
+	if err != nil {
+		queue.close() // NOTE: エクスポーターを作成できない場合はキューの goroutine が残らないよう閉じる
+		return nil, err
+	}
+	exporter = queue.wrapSpanExporter(exporter)
+	processor, err := m.newObservedBatchSpanProcessor(name, exporter, pl.Batch)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return nil, err
+	}
+	if filter != nil {
+		processor = &filterSpanProcessor{SpanProcessor: processor, filter: filter}
+	}
+	return processor, nil
+}
+
+// fanoutSpanProcessor は全てのパイプラインの SpanProcessor にスパンを渡す sdktrace.SpanProcessor
+//
+// NOTE: TracerProvider に複数の SpanProcessor を登録しても同じ動作になるが、リダクションやテールサンプリングの
+// SpanProcessor は次の SpanProcessor を1つだけ受け取るため、パイプラインを1つにまとめてから渡す。
+type fanoutSpanProcessor []sdktrace.SpanProcessor
+
+// OnStart は全てのパイプラインに開始したスパンを渡す
+func (p fanoutSpanProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	for _, sp := range p {
+		sp.OnStart(parent, s)
+	}
+}
+
+// OnEnd は全てのパイプラインに終了したスパンを渡す
+func (p fanoutSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	for _, sp := range p {
+		sp.OnEnd(s)
+	}
+}
+
+// Shutdown は全てのパイプラインをシャットダウンする。いずれかが失敗しても残りのシャットダウンを試みる
+func (p fanoutSpanProcessor) Shutdown(ctx context.Context) error {
+	var errs []error
+	for _, sp := range p {
+		errs = append(errs, sp.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// ForceFlush は全てのパイプラインのスパンを即座にエクスポートする
+func (p fanoutSpanProcessor) ForceFlush(ctx context.Context) error {
+	var errs []error
+	for _, sp := range p {
+		errs = append(errs, sp.ForceFlush(ctx))
+	}
+	return errors.Join(errs...)
+}
+
+// metricPipelineOptions は全てのメトリクスのパイプラインに共通の設定
+type metricPipelineOptions struct {
+	interval    time.Duration // NOTE: MetricPipeline.Interval 未指定時の間隔
+	aggregation AggregationType
+	exp         ExponentialHistogramConfig
+	overflow    *overflowDetector // NOTE: 全てのパイプラインで共有し、カーディナリティ上限の警告を計器ごとに1回にまとめる
+	queue       PersistentQueueConfig
+}
+
+// metricPipeline は1つのパイプラインの PeriodicReader に渡す Exporter
+type metricPipeline struct {
+	exporter sdkmetric.Exporter
+	observed *observedMetricExporter
+	interval time.Duration
+}
+
+// newMetricPipelines はパイプラインごとの Exporter を生成する。ExporterNone のパイプラインは作成しない
+//
+// NOTE: Exporter はヒストグラムの集約方法の差し替え、エクスポート結果の記録、フィルタ、カーディナリティ上限の検出の順にラップする。
+// エクスポート結果はフィルタ後のデータポイント数で記録され、カーディナリティ上限はフィルタ前の全ての計器で検出される。
+func newMetricPipelines(ctx context.Context, pipelines []MetricPipeline, opts metricPipelineOptions) ([]metricPipeline, error) {
+	var created []metricPipeline
+	names := make(map[string]struct{}, len(pipelines))
+	for _, pl := range pipelines {
+		if pl.Exporter.Type == ExporterNone {
+			continue
+		}
+		name := pipelineName(pl.Name, pl.Exporter)
+		c, err := newMetricPipeline(ctx, name, pl, opts, names)
+		if err != nil {
+			shutdownMetricPipelines(ctx, created)
+			return nil, fmt.Errorf("otel: metric pipeline %q: %w", name, err)
+		}
+		created = append(created, c)
+	}
+	return created, nil
+}
+
+// shutdownMetricPipelines は PeriodicReader に登録する前のパイプラインの Exporter をシャットダウンする
+func shutdownMetricPipelines(ctx context.Context, pipelines []metricPipeline) {
+	for _, pl := range pipelines {
+		_ = pl.exporter.Shutdown(ctx)
+	}
+}
+
+// newMetricPipeline は1つのパイプラインの Exporter を生成する
+func newMetricPipeline(ctx context.Context, name string, pl MetricPipeline, opts metricPipelineOptions, names map[string]struct{}) (metricPipeline, error) {
+	if _, ok := names[name]; ok {
+		return metricPipeline{}, errors.New("duplicate pipeline name")
+	}
+	names[name] = struct{}{}
+	if pl.Interval < 0 {
+		return metricPipeline{}, fmt.Errorf("interval must not be negative: %s", pl.Interval)
+	}
+	instruments, err := compileWildcards(pl.Filter.Instruments)
+	if err != nil {
+		return metricPipeline{}, fmt.Errorf("instrument filter: %w", err)
+	}
+
+	queue, err := newPersistentQueue(opts.queue, signalMetrics, name, pl.Exporter)
+	if err != nil {
+		return metricPipeline{}, err
+	}
+	exporter, err := newMetricExporter(ctx, pl.Exporter, queue)
+	if err != nil {
+		queue.close() // NOTE: エクスポーターを作成できない場合はキューの goroutine が残らないよう閉じる
+		return metricPipeline{}, err
+	}
+	exporter = queue.wrapMetricExporter(exporter)
+	// NOTE: ヒストグラムのデフォルトの集約方法は PeriodicReader が Exporter に問い合わせるため、Exporter をラップして差し替える
+	aggregated, err := withHistogramAggregation(exporter, opts.aggregation, opts.exp)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return metricPipeline{}, err
+	}
+	observed := &observedMetricExporter{Exporter: aggregated, pipeline: name}
+	c := metricPipeline{exporter: observed, observed: observed, interval: pl.Interval}
+	if c.interval == 0 {
+		c.interval = opts.interval
+	}
+	if instruments != nil {
+		c.exporter = &filterMetricExporter{Exporter: observed, instruments: instruments}
+	}
+	c.exporter = &overflowWarningExporter{Exporter: c.exporter, detector: opts.overflow}
+	return c, nil
+}
+
+// newLogPipelines はパイプラインごとに BatchProcessor を生成する。ExporterNone のパイプラインは作成しない
+func (m *pipelineMetrics) newLogPipelines(ctx context.Context, pipelines []LogPipeline, queue PersistentQueueConfig) ([]sdklog.Processor, error) {
+	var processors []sdklog.Processor
+	shutdown := func() {
+		for _, p := range processors {
+			_ = p.Shutdown(ctx)
+		}
+	}
+	names := make(map[string]struct{}, len(pipelines))
+	for _, pl := range pipelines {
+		// NOTE: ログはエクスポーター未指定の場合も出力しないため、ExporterNone と同様にパイプラインを作成しない
+		if pl.Exporter.Type == "" || pl.Exporter.Type == ExporterNone {
+			continue
+		}
+		name := pipelineName(pl.Name, pl.Exporter)
+		processor, err := m.newLogPipeline(ctx, name, pl, queue, names)
+		if err != nil {
+			shutdown()
+			return nil, fmt.Errorf("otel: log pipeline %q: %w", name, err)
+		}
+		if processor != nil {
+			processors = append(processors, processor)
+		}
+	}
+	return processors, nil
+}
+
+// newLogPipeline は1つのパイプラインの BatchProcessor を生成する。エクスポートしない場合は nil を返す
+func (m *pipelineMetrics) newLogPipeline(ctx context.Context, name string, pl LogPipeline, queueCfg PersistentQueueConfig, names map[string]struct{}) (sdklog.Processor, error) {
+	if _, ok := names[name]; ok {
+		return nil, errors.New("duplicate pipeline name")
+	}
+	names[name] = struct{}{}
+	if err := pl.Batch.validate(); err != nil {
+		return nil, err
+	}
+	queue, err := newPersistentQueue(queueCfg, signalLogs, name, pl.Exporter)
+	if err != nil {
+		return nil, err
+	}
+	exporter, err := newLogExporter(ctx, pl.Exporter, queue)
+	if err != nil || exporter == nil {
+		queue.close() // NOTE: エクスポーターを作成できない場合はキューの goroutine が残らないよう閉じる
+		return nil, err
+	}
+	exporter = queue.wrapLogExporter(exporter)
+	processor, err := m.newObservedBatchLogProcessor(name, exporter, pl.Batch)
+	if err != nil {
+		_ = exporter.Shutdown(ctx)
+		return nil, err
+	}
+	return processor, nil
+}
@@ -0,0 +1,79 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+)
+
+// PrometheusConfig は Prometheus の scrape 用エンドポイント (/metrics) の設定
+//
+// PeriodicReader (push 型) とは別に、Prometheus が任意のタイミングで取得しにくる pull 型の Reader を追加する。
+// メトリクス名は Prometheus の命名規則に変換される (例: article.create.duration (unit: s) → article_create_duration_seconds)。
+type PrometheusConfig struct {
+	// Enabled が true の場合に Prometheus Reader を追加する
+	Enabled bool
+
+	// Addr は /metrics を公開する専用ポートのアドレス (例: ":9464")。
+	// 空の場合は専用サーバーを起動せず、Provider.MetricsHandler をアプリケーションの ServeMux に登録して使用する
+	Addr string
+}
+
+// newPrometheusReader は Prometheus Reader と、専用のレジストリを公開する http.Handler を生成する
+//
+// NOTE: prometheus.DefaultRegisterer を使うと Go ランタイムのメトリクス等と混在し、Provider を複数生成した場合に登録が衝突するため専用のレジストリを使用する。
+// overflow を指定した場合は scrape のたびにカーディナリティ上限を超えた計器を検出する。
+func newPrometheusReader(overflow *overflowDetector) (*otelprom.Exporter, http.Handler, error) {
+	registry := prometheus.NewRegistry()
+	reader, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+	var gatherer prometheus.Gatherer = registry
+	if overflow != nil {
+		gatherer = &overflowWarningGatherer{Gatherer: registry, detector: overflow}
+	}
+	return reader, promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}), nil
+}
+
+// prometheusServer は /metrics を専用ポートで公開する HTTP サーバー
+type prometheusServer struct {
+	server *http.Server
+}
+
+// startPrometheusServer は addr で Listen し、/metrics を公開するサーバーを別 goroutine で起動する
+func startPrometheusServer(addr string, handler http.Handler) (*prometheusServer, error) {
+	// NOTE: ポートの競合等を NewProvider のエラーとして返せるよう、Listen までは同期的に行う
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	s := &prometheusServer{
+		server: &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+
+	go func() {
+		if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("prometheus metrics server error", slog.String("error", err.Error()))
+		}
+	}()
+	return s, nil
+}
+
+// Shutdown はサーバーを停止する
+func (s *prometheusServer) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,81 @@
+package otel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+)
+
+func TestPrometheusScrapeTranslatesNamesAndUnits(t *testing.T) {
+	p, err := NewProvider(t.Context(), Config{
+		ServiceName:    "article-api",
+		TraceExporter:  ExporterConfig{Type: ExporterNone},
+		MetricExporter: ExporterConfig{Type: ExporterNone},
+		Prometheus:     PrometheusConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+
+	// NOTE: usecase/interface.go と同じ名前・単位の計器
+	meter := p.MeterProvider.Meter("article")
+	views, err := meter.Int64Counter("article.views.total", metric.WithUnit("{view}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	duration, err := meter.Float64Histogram("article.create.duration", metric.WithUnit("s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := meter.Int64ObservableGauge("article.active.count", metric.WithUnit("{article}"),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			o.Observe(3)
+			return nil
+		}),
+	); err != nil {
+		t.Fatal(err)
+	}
+	views.Add(t.Context(), 2)
+	duration.Record(t.Context(), 0.25)
+
+	srv := httptest.NewServer(p.MetricsHandler)
+	t.Cleanup(srv.Close)
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("scrape status = %s", resp.Status)
+	}
+
+	// NOTE: "." は "_" に、単位 s は _seconds に変換され、{} の注釈は名前に付与されない。Counter には _total が1回だけ付く
+	for _, want := range []string{
+		"# TYPE article_views_total counter",
+		"article_views_total{",
+		"# TYPE article_create_duration_seconds histogram",
+		"article_create_duration_seconds_bucket{",
+		"article_create_duration_seconds_sum{",
+		"# TYPE article_active_count gauge",
+		"article_active_count{",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("scrape output does not contain %q:\n%s", want, body)
+		}
+	}
+	for _, unwanted := range []string{"article_views_total_total", "article_views_view", "article_active_count_article"} {
+		if strings.Contains(string(body), unwanted) {
+			t.Errorf("scrape output contains %q", unwanted)
+		}
+	}
+}
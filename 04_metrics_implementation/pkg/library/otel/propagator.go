@@ -0,0 +1,80 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// Propagator はトレースコンテキストを伝搬するヘッダー形式の名前 (OTEL_PROPAGATORS と同じ値)
+type Propagator string
+
+const (
+	// PropagatorTraceContext は W3C Trace Context (traceparent / tracestate ヘッダー)
+	PropagatorTraceContext Propagator = "tracecontext"
+
+	// PropagatorBaggage は W3C Baggage (baggage ヘッダー)
+	PropagatorBaggage Propagator = "baggage"
+
+	// PropagatorB3 は Zipkin B3 の単一ヘッダー形式 (b3 ヘッダー)。受信時は複数ヘッダー形式も読み取る
+	PropagatorB3 Propagator = "b3"
+
+	// PropagatorB3Multi は Zipkin B3 の複数ヘッダー形式 (X-B3-TraceId / X-B3-SpanId / X-B3-Sampled 等)。受信時は単一ヘッダー形式も読み取る
+	PropagatorB3Multi Propagator = "b3multi"
+
+	// PropagatorJaeger は Jaeger (uber-trace-id ヘッダー)
+	PropagatorJaeger Propagator = "jaeger"
+
+	// PropagatorXRay は AWS X-Ray (X-Amzn-Trace-Id ヘッダー)
+	// NOTE: X-Ray のバックエンドはトレース ID の先頭32ビットを UNIX 時刻として扱うため、本サービスで開始したトレースを
+	// X-Ray に直接送信する場合は xray.NewIDGenerator を併用する必要がある (上流のトレースに参加するだけであれば不要)。
+	PropagatorXRay Propagator = "xray"
+
+	// PropagatorNone はトレースコンテキストを伝搬しない (単独でのみ指定できる)
+	PropagatorNone Propagator = "none"
+)
+
+// defaultPropagators は Config.Propagators 未指定時の伝搬形式 (OpenTelemetry 仕様のデフォルト)
+var defaultPropagators = []Propagator{PropagatorTraceContext, PropagatorBaggage}
+
+// newPropagator は names の形式を全て扱う TextMapPropagator を返す
+//
+// 送信時は全ての形式のヘッダーを付与し、受信時は names の順に読み取る (後に読み取った形式のトレースコンテキストが優先される)。
+// 異なる形式を送信する複数の上流からのリクエストを、同じサービスで受け付けられるようになる。
+func newPropagator(names []Propagator) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = defaultPropagators
+	}
+	if slices.Contains(names, PropagatorNone) {
+		if len(names) > 1 {
+			return nil, errors.New("otel: propagator none cannot be combined with other propagators")
+		}
+		return propagation.NewCompositeTextMapPropagator(), nil
+	}
+
+	var propagators []propagation.TextMapPropagator
+	for _, name := range names {
+		switch name {
+		case PropagatorTraceContext:
+			propagators = append(propagators, propagation.TraceContext{}) // traceparent, tracestate ヘッダー
+		case PropagatorBaggage:
+			propagators = append(propagators, propagation.Baggage{}) // 追加のコンテキスト情報
+		case PropagatorB3:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3SingleHeader)))
+		case PropagatorB3Multi:
+			propagators = append(propagators, b3.New(b3.WithInjectEncoding(b3.B3MultipleHeader)))
+		case PropagatorJaeger:
+			propagators = append(propagators, jaeger.Jaeger{})
+		case PropagatorXRay:
+			propagators = append(propagators, xray.Propagator{})
+		default:
+			return nil, fmt.Errorf("otel: unsupported propagator %q", name)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(propagators...), nil
+}
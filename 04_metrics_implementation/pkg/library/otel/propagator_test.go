@@ -0,0 +1,124 @@
+package otel
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// testSpanContext はサンプリング済みのリモートのスパンコンテキスト
+var testSpanContext = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{0x5b, 0x8e, 0xfe, 0xd8, 0x2a, 0x9f, 0x4c, 0x1d, 0x91, 0x3e, 0x7a, 0x6b, 0x0c, 0x2d, 0x4f, 0x81},
+	SpanID:     trace.SpanID{0x53, 0x99, 0x5c, 0x3f, 0x42, 0xcd, 0x8a, 0xd8},
+	TraceFlags: trace.FlagsSampled,
+	Remote:     true,
+})
+
+func TestPropagatorRoundTrip(t *testing.T) {
+	tests := []struct {
+		propagator Propagator
+		headers    []string
+	}{
+		{propagator: PropagatorTraceContext, headers: []string{"traceparent"}},
+		{propagator: PropagatorB3, headers: []string{"b3"}},
+		{propagator: PropagatorB3Multi, headers: []string{"x-b3-traceid", "x-b3-spanid", "x-b3-sampled"}},
+		{propagator: PropagatorJaeger, headers: []string{"uber-trace-id"}},
+		{propagator: PropagatorXRay, headers: []string{"x-amzn-trace-id"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.propagator), func(t *testing.T) {
+			p, err := newPropagator([]Propagator{tt.propagator})
+			if err != nil {
+				t.Fatal(err)
+			}
+			carrier := propagation.HeaderCarrier(http.Header{})
+			p.Inject(trace.ContextWithSpanContext(context.Background(), testSpanContext), carrier)
+			for _, h := range tt.headers {
+				if carrier.Get(h) == "" {
+					t.Errorf("header %q not injected (headers: %v)", h, carrier.Keys())
+				}
+			}
+			if len(carrier.Keys()) != len(tt.headers) {
+				t.Errorf("injected headers = %v, want only %v", carrier.Keys(), tt.headers)
+			}
+
+			got := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+			if !got.Equal(testSpanContext) {
+				t.Errorf("extracted %+v, want %+v", got, testSpanContext)
+			}
+		})
+	}
+}
+
+func TestPropagatorBaggageRoundTrip(t *testing.T) {
+	p, err := newPropagator([]Propagator{PropagatorBaggage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenant, err := baggage.NewMember("tenant.id", "acme")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app, err := baggage.NewMember("client.app", "ios")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := baggage.New(tenant, app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	carrier := propagation.HeaderCarrier(http.Header{})
+	p.Inject(baggage.ContextWithBaggage(context.Background(), b), carrier)
+	if carrier.Get("baggage") == "" {
+		t.Fatalf("baggage header not injected (headers: %v)", carrier.Keys())
+	}
+
+	got := baggage.FromContext(p.Extract(context.Background(), carrier))
+	if got.Member("tenant.id").Value() != "acme" || got.Member("client.app").Value() != "ios" {
+		t.Errorf("extracted baggage = %s, want %s", got, b)
+	}
+}
+
+func TestCompositePropagatorExtractsEveryFormat(t *testing.T) {
+	// NOTE: cmd/main.go のデフォルトと同じ組み合わせ
+	p, err := newPropagator([]Propagator{PropagatorB3, PropagatorXRay, PropagatorTraceContext, PropagatorBaggage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []Propagator{PropagatorB3, PropagatorB3Multi, PropagatorXRay, PropagatorTraceContext} {
+		t.Run(string(name), func(t *testing.T) {
+			upstream, err := newPropagator([]Propagator{name})
+			if err != nil {
+				t.Fatal(err)
+			}
+			carrier := propagation.HeaderCarrier(http.Header{})
+			upstream.Inject(trace.ContextWithSpanContext(context.Background(), testSpanContext), carrier)
+
+			got := trace.SpanContextFromContext(p.Extract(context.Background(), carrier))
+			if !got.Equal(testSpanContext) {
+				t.Errorf("extracted %+v, want %+v", got, testSpanContext)
+			}
+		})
+	}
+}
+
+func TestPropagatorNone(t *testing.T) {
+	p, err := newPropagator([]Propagator{PropagatorNone})
+	if err != nil {
+		t.Fatal(err)
+	}
+	carrier := propagation.HeaderCarrier(http.Header{})
+	p.Inject(trace.ContextWithSpanContext(context.Background(), testSpanContext), carrier)
+	if len(carrier.Keys()) != 0 {
+		t.Errorf("injected headers = %v, want none", carrier.Keys())
+	}
+
+	if _, err := newPropagator([]Propagator{PropagatorNone, PropagatorB3}); err == nil {
+		t.Error("expected error when none is combined with other propagators")
+	}
+}
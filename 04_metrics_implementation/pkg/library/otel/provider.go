@@ -4,15 +4,20 @@ package otel
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
 	"time"
 
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
-	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
-	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/log/global"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
 
+	sdklog "go.opentelemetry.io/otel/sdk/log"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
@@ -23,12 +28,111 @@ type Config struct {
 	ServiceName    string
 	ServiceVersion string
 	Environment    string
+
+	// ResourceAttributes は全テレメトリ共通のリソースに追加する任意の属性 (例: "team": "article")
+	ResourceAttributes map[string]string
+
+	// ResourceDetectors はリソースに付与する実行環境の情報 (ホスト・プロセス・コンテナ・Kubernetes・ビルド情報等) の検出を有効化する
+	ResourceDetectors ResourceDetectorsConfig
+
+	// TraceExporter / MetricExporter はシグナルごとのエクスポート先。未指定の場合は標準出力、ExporterNone の場合はそのシグナルを無効化する
+	TraceExporter  ExporterConfig
+	MetricExporter ExporterConfig
+
+	// TracePipelines / MetricPipelines は複数のエクスポート先に同時に送信する場合のパイプライン (pipeline.go 参照)。
+	// パイプラインごとにバッチの設定とスパン名・ステータス・計器名のフィルタを指定できる。指定した場合は TraceExporter / MetricExporter は使用されない
+	TracePipelines  []TracePipeline
+	MetricPipelines []MetricPipeline
+
+	// MetricViews は計器ごとにエクスポート時の名前・集約・バケット境界・属性を上書きするルール
+	MetricViews []MetricView
+
+	// HistogramAggregation は MetricViews で集約方法を指定していないヒストグラム計器の集約方法。
+	// 未指定 (explicit_bucket_histogram) の場合は計器で指定したバケット境界、base2_exponential_bucket_histogram の場合は Base2 指数ヒストグラムで集約する
+	HistogramAggregation AggregationType
+
+	// ExponentialHistogram は Base2 指数ヒストグラムの設定 (HistogramAggregation と MetricViews の両方に適用される)
+	ExponentialHistogram ExponentialHistogramConfig
+
+	// Exemplars はヒストグラム等のデータポイントに記録時のトレース ID・スパン ID を保持する Exemplar の収集条件。未指定の場合は trace_based
+	Exemplars ExemplarFilter
+
+	// MetricCardinalityLimit は計器ごとの時系列 (属性の組み合わせ) の数の上限。0 の場合は2000、負の値の場合は上限なし。
+	// 上限を超えた記録は otel.metric.overflow=true の時系列にまとめられ、計器ごとに初回のみ slog で警告する
+	MetricCardinalityLimit int
+
+	// LogExporter は slog のログを OTel LogRecord としてエクスポートする場合の送信先。未指定の場合はエクスポートしない
+	LogExporter ExporterConfig
+
+	// LogPipelines は LogRecord を複数のエクスポート先に同時に送信する場合のパイプライン。指定した場合は LogExporter は使用されない
+	LogPipelines []LogPipeline
+
+	// PersistentQueue が設定されている場合、OTLP の送信に失敗したスパン・メトリクス・ログをディスクに退避し、
+	// 送信先の復旧後 (プロセスの再起動後を含む) に再送する (persistent_queue.go 参照)
+	PersistentQueue PersistentQueueConfig
+
+	// LogFile が設定されている場合、slog の出力先として JSON Lines 形式のローテーション付きファイルを Provider.LogWriter で提供する
+	LogFile FileConfig
+
+	// Sampler はトレースのサンプリング戦略。未指定の場合は全スパンを記録する
+	Sampler SamplerConfig
+
+	// TailSampling はトレース全体を見てから記録するかを判定するテールサンプリングの設定
+	TailSampling TailSamplingConfig
+
+	// Redaction はエクスポート前のスパンとログの属性から個人情報をマスク・ハッシュ化・削除するルール
+	Redaction RedactionConfig
+
+	// Propagators はリクエストのヘッダーからトレースコンテキストを読み取り・付与する形式 (OTEL_PROPAGATORS)。
+	// 未指定の場合は tracecontext, baggage
+	Propagators []Propagator
+
+	// Baggage は伝搬された Baggage のうちスパン・メトリクスの属性としてコピーするメンバーの許可リスト
+	Baggage BaggageConfig
+
+	// Prometheus は PeriodicReader と併用する Prometheus の scrape 用エンドポイントの設定
+	Prometheus PrometheusConfig
+
+	// RuntimeMetrics が true の場合、Go ランタイム (ゴルーチン・メモリ・GC) とプロセス (CPU 時間・ファイルディスクリプタ・稼働時間) のメトリクスを記録する
+	RuntimeMetrics bool
+
+	// MetricExportInterval は PeriodicReader の収集・エクスポート間隔。0 の場合は10秒
+	MetricExportInterval time.Duration
+
+	// Disabled が true の場合はエクスポーターを作成せず、テレメトリを一切出力しない (OTEL_SDK_DISABLED)
+	Disabled bool
 }
 
+// instrumentationName は本パッケージ自身が生成するテレメトリ (内部メトリクスやログのブリッジ) の計装スコープ名
+const instrumentationName = "pkg/library/otel"
+
 // Provider は OTEL の各種 Provider を保持
 type Provider struct {
 	TracerProvider *sdktrace.TracerProvider
 	MeterProvider  *sdkmetric.MeterProvider
+	LoggerProvider *sdklog.LoggerProvider
+
+	// MetricsHandler は Prometheus 形式でメトリクスを返す /metrics 用のハンドラ (Prometheus 無効時は nil)
+	MetricsHandler http.Handler
+
+	// LogWriter は Config.LogFile に出力する io.Writer (LogFile.Path 未指定時は nil)。slog.NewJSONHandler の出力先として使用する
+	LogWriter io.Writer
+
+	// Propagators は Config.Propagators の形式を全て扱う TextMapPropagator (グローバルにも設定される)
+	Propagators propagation.TextMapPropagator
+
+	// meterProvider は MeterProvider に Config.Baggage.MetricKeys の属性を追加するラップを適用したもの (Telemetry とグローバルに設定する)
+	meterProvider metric.MeterProvider
+
+	// Redactor は Config.Redaction のルール (ルール未指定時は nil)。OTELHandler に WithRedactor で渡し、ログにもスパンと同じルールを適用する
+	Redactor *Redactor
+
+	promServer     *prometheusServer
+	logFile        *rotatingFile
+	runtimeMetrics *runtimeMetrics
+
+	shutdownOnce sync.Once
+	shutdownErr  error
 }
 
 // NewProvider は OTEL Provider を初期化
@@ -36,71 +140,97 @@ func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
 	// =======================================================
 	// 1. リソースの定義 (全テレメトリ共通)
 	// =======================================================
-	res, err := resource.New(ctx,
-		resource.WithAttributes(
-			semconv.ServiceName(cfg.ServiceName),
-			semconv.ServiceVersion(cfg.ServiceVersion),
-			attribute.String("deployment.environment", cfg.Environment),
-		),
-	)
+	attrs := []attribute.KeyValue{
+		semconv.ServiceName(cfg.ServiceName),
+		semconv.ServiceVersion(cfg.ServiceVersion),
+		attribute.String("deployment.environment", cfg.Environment),
+	}
+	for k, v := range cfg.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	// NOTE: ホスト・コンテナ・Kubernetes 等の実行環境の情報は cfg.ResourceDetectors で有効化した Detector で付与する
+	res, err := newResource(ctx, cfg.ResourceDetectors, attrs)
 	if err != nil {
 		return nil, err
 	}
 
-	// =======================================================
-	// 2. Trace Exporter の作成 (開発環境のため標準出力に出力)
-	// =======================================================
-	traceExporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+	// NOTE: SDK 無効化時もログは出力されるため、ルールは無効化の判定より前に検証する
+	redactor, err := NewRedactor(cfg.Redaction)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Baggage.validate(); err != nil {
+		return nil, err
+	}
+	propagator, err := newPropagator(cfg.Propagators)
 	if err != nil {
 		return nil, err
 	}
-	// 本番環境では otlptracegrpc.New() を使用して OTLP Collector に送信する。※バイナリ形式で送信する方が効率が良い
-	// traceExporter, err := otlptracegrpc.New(ctx,
-	//     otlptracegrpc.WithEndpoint("otel-collector:4317"),
-	//     otlptracegrpc.WithInsecure(), // TLS なしの場合
-	// )
 
-	// =======================================================
-	// 3. TracerProvider の作成
-	// =======================================================
-	// - WithBatcher: スパンを即時エクスポートせず、バッチに溜めてからまとめて送信する。
-	//   SimpleSpanProcessor (即時送信) もあるが、本番ではバッチが推奨。
-	//
-	//   - WithBatchTimeout(5s): 最後のエクスポートから5秒経過したらバッチをフラッシュする。
-	//     スパンが少量でも一定間隔でエクスポートされることを保証する。
-	//
-	//   - WithMaxExportBatchSize(512): バッチに512件溜まった時点で即座にエクスポートする。
-	//     高トラフィック時にメモリ上にスパンが溜まりすぎるのを防ぐ。
-	//     タイムアウトとバッチサイズの「どちらか先に到達した方」でエクスポートが発火する。
-	//
-	// - WithSampler: どのスパンを記録するかを制御する。
-	//   AlwaysSample() は全リクエストのスパンを記録する (開発環境向け)。
-	//   本番環境では TraceIDRatioBased(0.1) 等で10%だけ記録するなど、
-	//   データ量とコストを抑えるサンプリング戦略を選択する。
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithResource(res),
-		sdktrace.WithBatcher(traceExporter,
-			sdktrace.WithBatchTimeout(5*time.Second), // NOTE: 5秒間隔でトレースを出力
-			sdktrace.WithMaxExportBatchSize(512),     // NOTE: または512件溜まったら出力
-		),
-		sdktrace.WithSampler(sdktrace.AlwaysSample()),
-	)
+	// NOTE: SDK 無効化時はエクスポーターを持たない Provider を登録する。
+	// 計装コード側の tracer.Start() や Counter.Add() はそのまま呼べるが、何も記録・出力されない。
+	if cfg.Disabled {
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.NeverSample()),
+		)
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithResource(res))
+		lp := sdklog.NewLoggerProvider(sdklog.WithResource(res))
+		otel.SetTracerProvider(tp)
+		otel.SetMeterProvider(mp)
+		global.SetLoggerProvider(lp)
+		// NOTE: スパンを記録しない場合も、上流から受け取ったトレースコンテキストや Baggage は下流へ伝搬させる
+		otel.SetTextMapPropagator(propagator)
+		return &Provider{
+			TracerProvider: tp,
+			MeterProvider:  mp,
+			LoggerProvider: lp,
+			Propagators:    propagator,
+			Redactor:       redactor,
+		}, nil
+	}
+
+	sampler, err := newSampler(cfg.Sampler)
+	if err != nil {
+		return nil, err
+	}
 
 	// =======================================================
-	// 4. Metric Exporter の作成 (開発環境のため標準出力に出力)
+	// 2. Metric Exporter の作成
 	// =======================================================
-	metricExporter, err := stdoutmetric.New(stdoutmetric.WithPrettyPrint())
+	// 開発環境では標準出力 (stdout) に出力し、本番環境では OTLP Collector に送信する。※バイナリ形式で送信する方が効率が良い
+	// どちらを使うかは cfg.MetricExporter.Type で切り替える。
+	// 複数のエクスポート先に同時に送信する場合は cfg.MetricPipelines でパイプラインごとに指定する (pipeline.go 参照)。
+	//
+	// NOTE: MeterProvider は TracerProvider 側の内部メトリクス (テールサンプリングの判定数等) の記録にも使用するため、先に作成する。
+	interval := cfg.MetricExportInterval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	// NOTE: View は計器の作成時に適用されるため、MeterProvider の作成時に渡す必要がある
+	views, err := newViews(cfg.MetricViews, cfg.ExponentialHistogram)
+	if err != nil {
+		return nil, err
+	}
+	exemplarFilter, err := cfg.Exemplars.filter()
+	if err != nil {
+		return nil, err
+	}
+	limit := cardinalityLimit(cfg.MetricCardinalityLimit)
+	overflow := newOverflowDetector(limit)
+	metricPipelines, err := newMetricPipelines(ctx, cfg.metricPipelines(), metricPipelineOptions{
+		interval:    interval,
+		aggregation: cfg.HistogramAggregation,
+		exp:         cfg.ExponentialHistogram,
+		overflow:    overflow,
+		queue:       cfg.PersistentQueue,
+	})
 	if err != nil {
 		return nil, err
 	}
-	// 本番環境では otlpmetricgrpc.New() を使用して OTLP Collector に送信する。※バイナリ形式で送信する方が効率が良い
-	// metricExporter, err := otlpmetricgrpc.New(ctx,
-	//     otlpmetricgrpc.WithEndpoint("otel-collector:4317"),
-	//     otlpmetricgrpc.WithInsecure(), // TLS なしの場合
-	// )
 
 	// =======================================================
-	// 5. MeterProvider の作成
+	// 3. MeterProvider の作成
 	// =======================================================
 
 	/*
@@ -142,36 +272,273 @@ func NewProvider(ctx context.Context, cfg Config) (*Provider, error) {
 				- 違いは「途中のエクスポートが欠落した場合に復元できるか」という信頼性の面にある。
 				- Temporality を変更するには WithTemporalitySelector オプションを PeriodicReader に渡す。
 	*/
-	mp := sdkmetric.NewMeterProvider(
+	mpOpts := []sdkmetric.Option{
 		sdkmetric.WithResource(res),
-		sdkmetric.WithReader(
-			sdkmetric.NewPeriodicReader(metricExporter, // NOTE: デフォルトで CumulativeTemporality (累積) が適用される
-				sdkmetric.WithInterval(10*time.Second), // NOTE: 10秒間隔でメトリクスを収集・エクスポート
+		sdkmetric.WithView(views...),
+		sdkmetric.WithCardinalityLimit(limit),        // NOTE: 計器ごとに時系列数の上限を適用する (cardinality.go 参照)
+		sdkmetric.WithExemplarFilter(exemplarFilter), // NOTE: データポイントからトレースに辿れるよう Exemplar を収集する (exemplar.go 参照)
+	}
+	// NOTE: パイプラインごとに PeriodicReader を登録し、それぞれの間隔で収集・エクスポートする
+	for _, pl := range metricPipelines {
+		mpOpts = append(mpOpts, sdkmetric.WithReader(
+			sdkmetric.NewPeriodicReader(pl.exporter, // NOTE: デフォルトで CumulativeTemporality (累積) が適用される
+				sdkmetric.WithInterval(pl.interval), // NOTE: デフォルトは10秒間隔でメトリクスを収集・エクスポート
 			),
-		),
-	)
+		))
+	}
+
+	// NOTE: Prometheus Reader (pull 型) は PeriodicReader (push 型) と併用できる。
+	// 1つの MeterProvider に複数の Reader を登録すると、同じ計器の値がそれぞれの Reader から独立して収集される。
+	var metricsHandler http.Handler
+	if cfg.Prometheus.Enabled {
+		// NOTE: カーディナリティ上限の超過は PeriodicReader が無い場合のみ scrape 時に検出する (cardinality.go 参照)
+		var promOverflow *overflowDetector
+		if len(metricPipelines) == 0 {
+			promOverflow = overflow
+		}
+		promReader, handler, err := newPrometheusReader(promOverflow)
+		if err != nil {
+			shutdownMetricPipelines(ctx, metricPipelines)
+			return nil, err
+		}
+		mpOpts = append(mpOpts, sdkmetric.WithReader(promReader))
+		metricsHandler = handler
+	}
+	mp := sdkmetric.NewMeterProvider(mpOpts...)
+
+	// パイプライン自体の内部メトリクス (エクスポート件数・失敗数・キューの状態等) を MeterProvider に登録する
+	pipeline, err := newPipelineMetrics(mp)
+	if err != nil {
+		_ = mp.Shutdown(ctx)
+		return nil, err
+	}
+	for _, pl := range metricPipelines {
+		pl.observed.setMetrics(pipeline)
+	}
+
+	// Go ランタイムとプロセスのメトリクス (runtime_metrics.go 参照)
+	// NOTE: GC の停止時間を記録する goroutine は、以降の初期化が全て成功してから起動する
+	var runtime *runtimeMetrics
+	if cfg.RuntimeMetrics {
+		runtime, err = newRuntimeMetrics(mp)
+		if err != nil {
+			_ = mp.Shutdown(ctx)
+			return nil, err
+		}
+	}
+
+	// =======================================================
+	// 4. Trace Exporter の作成
+	// =======================================================
+	// メトリクスと同様に cfg.TraceExporter.Type で stdout / OTLP を切り替える。
+	// cfg.TracePipelines を指定した場合はパイプラインごとに Exporter と Batcher を作成し、全てのパイプラインにスパンを渡す。
+	// パイプラインのフィルタ (スパン名・ステータス) は各パイプラインの Batcher の手前で判定する。
+
+	// =======================================================
+	// 5. TracerProvider の作成
+	// =======================================================
+	// - NewBatchSpanProcessor (WithBatcher と同等): スパンを即時エクスポートせず、バッチに溜めてからまとめて送信する。
+	//   SimpleSpanProcessor (即時送信) もあるが、本番ではバッチが推奨。
+	//   以下はパイプラインの Batch を指定しない場合のデフォルト値 (TracePipeline.Batch で変更できる)。
+	//
+	//   - WithBatchTimeout(5s): 最後のエクスポートから5秒経過したらバッチをフラッシュする。
+	//     スパンが少量でも一定間隔でエクスポートされることを保証する。
+	//
+	//   - WithMaxExportBatchSize(512): バッチに512件溜まった時点で即座にエクスポートする。
+	//     高トラフィック時にメモリ上にスパンが溜まりすぎるのを防ぐ。
+	//     タイムアウトとバッチサイズの「どちらか先に到達した方」でエクスポートが発火する。
+	//
+	// - WithSampler: どのスパンを記録するかを制御する。
+	//   AlwaysSample() は全リクエストのスパンを記録する (開発環境向け、cfg.Sampler 未指定時のデフォルト)。
+	//   本番環境では TraceIDRatioBased(0.1) 等で10%だけ記録するなど、
+	//   データ量とコストを抑えるサンプリング戦略を cfg.Sampler で選択する。
+	//
+	// - cfg.TailSampling.Enabled の場合は Batcher の手前に TailSamplingProcessor を挟み、
+	//   ルートスパン終了時にエラー・レイテンシ・割合でトレース単位に記録するかを判定する。
+	//
+	// - Batcher はキューの状態とエクスポート結果を内部メトリクスとして記録するようラップする (observability.go 参照)。
+	//
+	// - cfg.Redaction のルールがある場合は Batcher の手前に RedactionProcessor を挟み、エクスポート前に個人情報を置き換える。
+	//   テールサンプリングの判定は置き換え前のスパンで行われる。
+	//
+	// - 全てのパイプラインが ExporterNone の場合は SpanProcessor を登録しない (トレースを無効化する)。
+	spanProcessor, err := pipeline.newSpanPipelines(ctx, cfg.tracePipelines(), cfg.PersistentQueue)
+	if err != nil {
+		_ = mp.Shutdown(ctx)
+		return nil, err
+	}
+	if spanProcessor != nil && redactor != nil {
+		spanProcessor = NewRedactionProcessor(spanProcessor, redactor)
+	}
+	if spanProcessor != nil && cfg.TailSampling.Enabled {
+		tailSampler, err := NewTailSamplingProcessor(spanProcessor, cfg.TailSampling, mp)
+		if err != nil {
+			_ = spanProcessor.Shutdown(ctx)
+			_ = mp.Shutdown(ctx)
+			return nil, err
+		}
+		spanProcessor = tailSampler
+	}
+	tpOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sampler),
+	}
+	// NOTE: SpanProcessor の OnStart は登録順に呼ばれるため、Baggage の属性はテールサンプリングや置き換えの前に追加される
+	if len(cfg.Baggage.Keys) > 0 {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(NewBaggageSpanProcessor(cfg.Baggage.Keys)))
+	}
+	if spanProcessor != nil {
+		tpOpts = append(tpOpts, sdktrace.WithSpanProcessor(spanProcessor))
+	}
+	tp := sdktrace.NewTracerProvider(tpOpts...)
+
+	// =======================================================
+	// 6. LoggerProvider の作成
+	// =======================================================
+	// slog のログを OTel の LogRecord としてエクスポートする。
+	// OTELHandler に WithLoggerProvider(provider.LoggerProvider) を渡すと、slog の出力がブリッジ経由でこの LoggerProvider に送られる。
+	// LogRecord はトレースコンテキストを持つため、バックエンド側でトレースとログを相互に辿れるようになる。
+	//
+	// NOTE: エクスポーター未指定の場合は Processor を持たない LoggerProvider となり、ブリッジは何も送信しない。
+	// cfg.LogPipelines を指定した場合はパイプラインごとに Processor を登録する。
+	logProcessors, err := pipeline.newLogPipelines(ctx, cfg.logPipelines(), cfg.PersistentQueue)
+	if err != nil {
+		_ = tp.Shutdown(ctx)
+		_ = mp.Shutdown(ctx)
+		return nil, err
+	}
+	lpOpts := []sdklog.LoggerProviderOption{sdklog.WithResource(res)}
+	for _, processor := range logProcessors {
+		lpOpts = append(lpOpts, sdklog.WithProcessor(processor))
+	}
+	lp := sdklog.NewLoggerProvider(lpOpts...)
+
+	// ログファイルの作成
+	var logFile *rotatingFile
+	if cfg.LogFile.Path != "" {
+		logFile, err = newRotatingFile(cfg.LogFile)
+		if err != nil {
+			_ = tp.Shutdown(ctx)
+			_ = lp.Shutdown(ctx)
+			_ = mp.Shutdown(ctx)
+			return nil, err
+		}
+	}
+
+	// Prometheus の /metrics を専用ポートで公開する場合はここで起動する
+	var promServer *prometheusServer
+	if cfg.Prometheus.Enabled && cfg.Prometheus.Addr != "" {
+		promServer, err = startPrometheusServer(cfg.Prometheus.Addr, metricsHandler)
+		if err != nil {
+			if logFile != nil {
+				_ = logFile.Close()
+			}
+			_ = tp.Shutdown(ctx)
+			_ = lp.Shutdown(ctx)
+			_ = mp.Shutdown(ctx)
+			return nil, err
+		}
+	}
 
 	// =======================================================
-	// 6. グローバルに設定
+	// 7. グローバルに設定
 	// =======================================================
+	// NOTE: Baggage のメンバーをメトリクスの属性に追加する場合は、ラップした MeterProvider を計装コードに渡す
+	meterProvider := newBaggageMeterProvider(mp, cfg.Baggage.MetricKeys)
 	otel.SetTracerProvider(tp)
-	otel.SetMeterProvider(mp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
-		propagation.TraceContext{}, // traceparent, tracestate ヘッダー
-		propagation.Baggage{},      // 追加のコンテキスト情報
-	))
+	otel.SetMeterProvider(meterProvider)
+	global.SetLoggerProvider(lp)
+	otel.SetErrorHandler(newErrorHandler(pipeline)) // NOTE: SDK 内部のエラー (エクスポート失敗等) を otel.errors に記録し slog に出力する
+	// NOTE: B3 や X-Ray 等のヘッダーを送信する上流のトレースにも参加できるよう、cfg.Propagators の全ての形式を扱う (propagator.go 参照)
+	otel.SetTextMapPropagator(propagator)
 
-	return &Provider{
+	p := &Provider{
 		TracerProvider: tp,
 		MeterProvider:  mp,
-	}, nil
+		LoggerProvider: lp,
+		MetricsHandler: metricsHandler,
+		Propagators:    propagator,
+		meterProvider:  meterProvider,
+		Redactor:       redactor,
+		promServer:     promServer,
+		logFile:        logFile,
+	}
+	if logFile != nil {
+		p.LogWriter = logFile
+	}
+	if runtime != nil {
+		runtime.start(interval)
+		p.runtimeMetrics = runtime
+	}
+	return p, nil
+}
+
+// ForceFlush は各 Provider がバッファしているテレメトリを即座にエクスポートする
+//
+// NOTE: CLI のバッチ処理やテストなど、プロセスを終了せずにエクスポート結果を確認したい場合に使用する。
+// いずれかの Provider が失敗しても残りの Provider のフラッシュを試み、全てのエラーをまとめて返す。
+// テールサンプリングが有効な場合は、判定待ちのトレースも TracerProvider のフラッシュ時にその時点のスパンで判定してエクスポートする
+// (TailSamplingProcessor.ForceFlush 参照)。
+func (p *Provider) ForceFlush(ctx context.Context) error {
+	var errs []error
+	if err := p.TracerProvider.ForceFlush(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("otel: flush tracer provider: %w", err))
+	}
+	if err := p.LoggerProvider.ForceFlush(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("otel: flush logger provider: %w", err))
+	}
+	if p.runtimeMetrics != nil {
+		p.runtimeMetrics.recordGCPauses(ctx)
+	}
+	if err := p.MeterProvider.ForceFlush(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("otel: flush meter provider: %w", err))
+	}
+	if p.logFile != nil {
+		if err := p.logFile.Sync(); err != nil {
+			errs = append(errs, fmt.Errorf("otel: flush log file: %w", err))
+		}
+	}
+	return errors.Join(errs...)
 }
 
 // Shutdown は Provider を終了
+//
+// いずれかの Provider が失敗しても残りの Provider のシャットダウンを必ず試み、全てのエラーをまとめて返す。
+// ctx の期限を超えた場合、各 Provider は未送信のデータを破棄して ctx.Err() を返す。
+// 複数回呼んでも安全で、2回目以降は何もせず初回の結果を返す。
 func (p *Provider) Shutdown(ctx context.Context) error {
-	// TracerProvider → MeterProvider の順にシャットダウン
+	p.shutdownOnce.Do(func() {
+		p.shutdownErr = p.shutdown(ctx)
+	})
+	return p.shutdownErr
+}
+
+// shutdown は各 Provider を順にシャットダウンする
+func (p *Provider) shutdown(ctx context.Context) error {
+	// TracerProvider → LoggerProvider → Prometheus サーバー → ランタイムメトリクス → MeterProvider → ログファイルの順にシャットダウン
+	// NOTE: MeterProvider は他の Provider の内部メトリクスも記録するため最後に停止する
+	var errs []error
 	if err := p.TracerProvider.Shutdown(ctx); err != nil {
-		return err
+		errs = append(errs, fmt.Errorf("otel: shutdown tracer provider: %w", err))
+	}
+	if err := p.LoggerProvider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("otel: shutdown logger provider: %w", err))
+	}
+	if p.promServer != nil {
+		if err := p.promServer.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("otel: shutdown prometheus server: %w", err))
+		}
+	}
+	if p.runtimeMetrics != nil {
+		p.runtimeMetrics.stop()
+	}
+	if err := p.MeterProvider.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("otel: shutdown meter provider: %w", err))
+	}
+	if p.logFile != nil {
+		if err := p.logFile.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("otel: close log file: %w", err))
+		}
 	}
-	return p.MeterProvider.Shutdown(ctx)
+	return errors.Join(errs...)
 }
@@ -0,0 +1,63 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestProviderForceFlushReleasesTailSampledTraces(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	p, err := NewProvider(t.Context(), Config{
+		ServiceName:    "test",
+		TraceExporter:  ExporterConfig{Type: ExporterFile, File: FileConfig{Path: path}},
+		MetricExporter: ExporterConfig{Type: ExporterNone},
+		TailSampling:   TailSamplingConfig{Enabled: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+
+	ctx, root := p.TracerProvider.Tracer("test").Start(t.Context(), "root")
+	defer root.End()
+	_, child := p.TracerProvider.Tracer("test").Start(ctx, "child")
+	child.End()
+
+	if err := p.ForceFlush(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"Name":"child"`) {
+		t.Errorf("exported spans = %s, want the span buffered for tail sampling", b)
+	}
+}
+
+func TestDisabledProviderSetsGlobalPropagator(t *testing.T) {
+	prev := otel.GetTextMapPropagator()
+	t.Cleanup(func() { otel.SetTextMapPropagator(prev) })
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	p, err := NewProvider(t.Context(), Config{
+		ServiceName: "test",
+		Disabled:    true,
+		Propagators: []Propagator{PropagatorB3Multi},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Shutdown(context.Background())
+
+	if fields := otel.GetTextMapPropagator().Fields(); !slices.Contains(fields, "x-b3-traceid") {
+		t.Errorf("global propagator fields = %v, want the configured b3 headers", fields)
+	}
+}
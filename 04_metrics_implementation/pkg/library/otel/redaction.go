@@ -0,0 +1,415 @@
+package otel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// RedactionConfig はスパンとログから個人情報 (メールアドレス・ユーザー名・記事タイトル等) を取り除くルール
+//
+// ルールはエクスポート前のスパンの属性・イベントの属性 (RecordError の exception.message を含む)・ステータスの説明と、
+// WithRedactor を指定した OTELHandler のログの属性に同じように適用されるため、トレースとログで同じ値は同じ形に置き換えられる。
+//
+// 例: 記事タイトルはハッシュ化してトレースとログを突き合わせられるようにし、メールアドレスは値の中の該当部分をマスクする
+//
+//	RedactionConfig{Rules: []RedactionRule{
+//	    {Keys: []string{"article.title", "title"}, Action: RedactionHash},
+//	    {Keys: []string{"*email*", "user.name"}, Action: RedactionMask},
+//	    {Values: []string{`[\w.+-]+@[\w-]+\.[\w.-]+`}, Action: RedactionMask},
+//	}}
+type RedactionConfig struct {
+	// Rules は先頭から順に照合され、最初に一致したルールのみが適用される
+	Rules []RedactionRule
+
+	// HashKey が設定されている場合、RedactionHash は HMAC-SHA256 でハッシュ化する。
+	// NOTE: 鍵なしの SHA-256 はメールアドレス等の推測しやすい値を総当たりで復元できるため、本番では設定を推奨する
+	HashKey string
+}
+
+// RedactionAction はルールに一致した属性の扱い
+type RedactionAction string
+
+const (
+	// RedactionMask は値を [REDACTED] に置き換える
+	RedactionMask RedactionAction = "mask"
+
+	// RedactionHash は値をハッシュ (sha256:<先頭16桁の16進数>) に置き換える。同じ値は同じハッシュになるため、値を隠したまま集計や突き合わせができる
+	RedactionHash RedactionAction = "hash"
+
+	// RedactionDrop は属性自体を削除する
+	RedactionDrop RedactionAction = "drop"
+)
+
+// RedactionRule は置き換えの対象とする属性と、その扱い
+type RedactionRule struct {
+	// Keys は対象とする属性キーのパターン (path.Match 形式、大文字小文字は区別しない)。例: "user.email", "*email*", "user.*"
+	// 一致した属性は型に関わらず値全体が置き換えられる。
+	// NOTE: ログのグループ内の属性は "group.key" の形式のキーで照合する (WithGroup で指定したグループ名は含まない)
+	Keys []string
+
+	// Values は対象とする文字列の値の正規表現。RedactionMask / RedactionHash の場合は値のうち一致した部分のみが置き換えられる
+	// NOTE: ログの error や fmt.Stringer 等の値 (slog.Any) は文字列に変換してから照合する
+	Values []string
+
+	// Action は一致した属性の扱い
+	Action RedactionAction
+}
+
+// redactedValue は RedactionMask で置き換えた値
+const redactedValue = "[REDACTED]"
+
+// Redactor は RedactionConfig のルールをスパンの属性と slog の属性に適用する
+//
+// NOTE: nil の *Redactor は何も置き換えない。
+type Redactor struct {
+	rules   []compiledRedactionRule
+	hashKey []byte
+}
+
+// compiledRedactionRule はパターンを検証・コンパイル済みの RedactionRule
+type compiledRedactionRule struct {
+	keys   []string
+	values []*regexp.Regexp
+	action RedactionAction
+}
+
+// NewRedactor は cfg のルールを検証して Redactor を生成する。ルールが空の場合は nil を返す
+func NewRedactor(cfg RedactionConfig) (*Redactor, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, nil
+	}
+	r := &Redactor{hashKey: []byte(cfg.HashKey)}
+	for i, rule := range cfg.Rules {
+		switch rule.Action {
+		case RedactionMask, RedactionHash, RedactionDrop:
+		default:
+			return nil, fmt.Errorf("otel: redaction rule %d: unsupported action %q", i, rule.Action)
+		}
+		if len(rule.Keys) == 0 && len(rule.Values) == 0 {
+			return nil, fmt.Errorf("otel: redaction rule %d: keys or values is required", i)
+		}
+		c := compiledRedactionRule{action: rule.Action}
+		for _, k := range rule.Keys {
+			k = strings.ToLower(k)
+			if _, err := path.Match(k, ""); err != nil {
+				return nil, fmt.Errorf("otel: redaction rule %d: invalid key pattern %q: %w", i, k, err)
+			}
+			c.keys = append(c.keys, k)
+		}
+		for _, v := range rule.Values {
+			re, err := regexp.Compile(v)
+			if err != nil {
+				return nil, fmt.Errorf("otel: redaction rule %d: invalid value pattern %q: %w", i, v, err)
+			}
+			c.values = append(c.values, re)
+		}
+		r.rules = append(r.rules, c)
+	}
+	return r, nil
+}
+
+// matchKey は key がいずれかのパターンに一致するかを返す
+func (c compiledRedactionRule) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, k := range c.keys {
+		if ok, _ := path.Match(k, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redact は key と値の文字列表現 value にルールを適用する
+//
+// 置き換え後の値と、値を置き換えたか (changed)、属性を削除するか (drop) を返す。値の正規表現は isString が true の場合のみ照合する。
+func (r *Redactor) redact(key, value string, isString bool) (out string, changed, drop bool) {
+	for _, rule := range r.rules {
+		if key != "" && rule.matchKey(key) {
+			switch rule.action {
+			case RedactionDrop:
+				return "", true, true
+			case RedactionHash:
+				return r.hash(value), true, false
+			default:
+				return redactedValue, true, false
+			}
+		}
+		if !isString {
+			continue
+		}
+		matched := false
+		for _, re := range rule.values {
+			if !re.MatchString(value) {
+				continue
+			}
+			matched = true
+			switch rule.action {
+			case RedactionDrop:
+				return "", true, true
+			case RedactionHash:
+				value = re.ReplaceAllStringFunc(value, r.hash)
+			default:
+				value = re.ReplaceAllLiteralString(value, redactedValue)
+			}
+		}
+		if matched {
+			return value, true, false
+		}
+	}
+	return value, false, false
+}
+
+// hash は value のハッシュを返す
+func (r *Redactor) hash(value string) string {
+	var sum []byte
+	if len(r.hashKey) > 0 {
+		mac := hmac.New(sha256.New, r.hashKey)
+		mac.Write([]byte(value))
+		sum = mac.Sum(nil)
+	} else {
+		s := sha256.Sum256([]byte(value))
+		sum = s[:]
+	}
+	return "sha256:" + hex.EncodeToString(sum[:8])
+}
+
+// text は文字列 (ステータスの説明等) に値の正規表現のルールを適用する
+//
+// NOTE: キーを持たないため Keys のルールは適用されない。RedactionDrop に一致した場合は文字列全体をマスクする。
+func (r *Redactor) text(s string) string {
+	out, changed, drop := r.redact("", s, true)
+	switch {
+	case drop:
+		return redactedValue
+	case changed:
+		return out
+	default:
+		return s
+	}
+}
+
+// attributes は attrs にルールを適用する。置き換えが無い場合は attrs をそのまま返す
+//
+// 削除した属性の数と、置き換えを行ったかを合わせて返す。
+func (r *Redactor) attributes(attrs []attribute.KeyValue) ([]attribute.KeyValue, int, bool) {
+	var out []attribute.KeyValue
+	dropped := 0
+	for i, kv := range attrs {
+		isString := kv.Value.Type() == attribute.STRING
+		v, changed, drop := r.redact(string(kv.Key), kv.Value.Emit(), isString)
+		if !changed {
+			if out != nil {
+				out = append(out, kv)
+			}
+			continue
+		}
+		if out == nil {
+			// NOTE: 最初に置き換えが発生した時点で、それまでの属性をコピーする (元のスライスは SDK が保持しているため変更しない)
+			out = append(make([]attribute.KeyValue, 0, len(attrs)), attrs[:i]...)
+		}
+		if drop {
+			dropped++
+			continue
+		}
+		out = append(out, attribute.String(string(kv.Key), v))
+	}
+	if out == nil {
+		return attrs, 0, false
+	}
+	return out, dropped, true
+}
+
+// =======================================================
+// スパン
+// =======================================================
+
+// RedactionProcessor は終了したスパンに Redactor のルールを適用してから next に渡す sdktrace.SpanProcessor
+//
+// 対象はスパンの属性・イベントの属性 (RecordError の exception.message 等)・ステータスの説明。
+// NOTE: スパンは実行中に SetAttributes 等で変更され得るため、エクスポート直前の OnEnd でまとめて置き換える。
+type RedactionProcessor struct {
+	next     sdktrace.SpanProcessor
+	redactor *Redactor
+}
+
+var _ sdktrace.SpanProcessor = (*RedactionProcessor)(nil)
+
+// NewRedactionProcessor は RedactionProcessor を生成する。next には通常 BatchSpanProcessor を渡す
+func NewRedactionProcessor(next sdktrace.SpanProcessor, redactor *Redactor) *RedactionProcessor {
+	return &RedactionProcessor{next: next, redactor: redactor}
+}
+
+// OnStart は next に委譲する
+func (p *RedactionProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+// OnEnd はルールを適用したスパンを next に渡す
+func (p *RedactionProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	p.next.OnEnd(p.redactor.span(s))
+}
+
+// Shutdown は next をシャットダウンする
+func (p *RedactionProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+// ForceFlush は next をフラッシュする
+func (p *RedactionProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// redactedSpan は属性・イベント・ステータスを置き換えた sdktrace.ReadOnlySpan
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs   []attribute.KeyValue
+	events  []sdktrace.Event
+	status  sdktrace.Status
+	dropped int
+}
+
+// Attributes は置き換え後の属性を返す
+func (s *redactedSpan) Attributes() []attribute.KeyValue { return s.attrs }
+
+// Events は置き換え後のイベントを返す
+func (s *redactedSpan) Events() []sdktrace.Event { return s.events }
+
+// Status は置き換え後のステータスを返す
+func (s *redactedSpan) Status() sdktrace.Status { return s.status }
+
+// DroppedAttributes は SDK の上限で破棄された属性数に、ルールで削除した属性数を加えた値を返す
+func (s *redactedSpan) DroppedAttributes() int {
+	return s.ReadOnlySpan.DroppedAttributes() + s.dropped
+}
+
+// span は s にルールを適用する。置き換えが無い場合は s をそのまま返す
+func (r *Redactor) span(s sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	if r == nil {
+		return s
+	}
+	attrs, dropped, changed := r.attributes(s.Attributes())
+
+	events := s.Events()
+	var redactedEvents []sdktrace.Event
+	for i, e := range events {
+		eventAttrs, eventDropped, eventChanged := r.attributes(e.Attributes)
+		if !eventChanged {
+			if redactedEvents != nil {
+				redactedEvents = append(redactedEvents, e)
+			}
+			continue
+		}
+		if redactedEvents == nil {
+			redactedEvents = append(make([]sdktrace.Event, 0, len(events)), events[:i]...)
+		}
+		e.Attributes = eventAttrs
+		e.DroppedAttributeCount += eventDropped
+		redactedEvents = append(redactedEvents, e)
+	}
+	if redactedEvents != nil {
+		events = redactedEvents
+		changed = true
+	}
+
+	status := s.Status()
+	if desc := r.text(status.Description); desc != status.Description {
+		status.Description = desc
+		changed = true
+	}
+
+	if !changed {
+		return s
+	}
+	return &redactedSpan{ReadOnlySpan: s, attrs: attrs, events: events, status: status, dropped: dropped}
+}
+
+// =======================================================
+// ログ
+// =======================================================
+
+// record は r の属性にルールを適用した slog.Record を返す。置き換えが無い場合は r をそのまま返す
+func (r *Redactor) record(rec slog.Record) slog.Record {
+	if r == nil || rec.NumAttrs() == 0 {
+		return rec
+	}
+	attrs := make([]slog.Attr, 0, rec.NumAttrs())
+	changed := false
+	rec.Attrs(func(a slog.Attr) bool {
+		redacted, ok, c := r.slogAttr("", a)
+		changed = changed || c
+		if ok {
+			attrs = append(attrs, redacted)
+		}
+		return true
+	})
+	if !changed {
+		return rec
+	}
+	out := slog.NewRecord(rec.Time, rec.Level, rec.Message, rec.PC)
+	out.AddAttrs(attrs...)
+	return out
+}
+
+// slogAttrs は attrs にルールを適用する (OTELHandler.WithAttrs 用)
+func (r *Redactor) slogAttrs(attrs []slog.Attr) []slog.Attr {
+	if r == nil {
+		return attrs
+	}
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if redacted, ok, _ := r.slogAttr("", a); ok {
+			out = append(out, redacted)
+		}
+	}
+	return out
+}
+
+// slogAttr は a にルールを適用する。グループの場合は prefix.key の形式のキーで各属性に再帰的に適用する
+//
+// 置き換え後の属性と、属性を残すか (ok)、置き換えを行ったか (changed) を返す。
+func (r *Redactor) slogAttr(prefix string, a slog.Attr) (attr slog.Attr, ok, changed bool) {
+	a.Value = a.Value.Resolve()
+	key := a.Key
+	if prefix != "" {
+		key = prefix + "." + a.Key
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		out := make([]slog.Attr, 0, len(group))
+		for _, ga := range group {
+			redacted, keep, c := r.slogAttr(key, ga)
+			changed = changed || c
+			if keep {
+				out = append(out, redacted)
+			}
+		}
+		if !changed {
+			return a, true, false
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(out...)}, true, true
+	}
+
+	// NOTE: slog.Any("error", err) 等の KindAny の値 (error / fmt.Stringer を含む) も、出力時と同じ文字列表現で値の正規表現を照合する
+	isString := a.Value.Kind() == slog.KindString || a.Value.Kind() == slog.KindAny
+	v, changed, drop := r.redact(key, a.Value.String(), isString)
+	switch {
+	case drop:
+		return slog.Attr{}, false, true
+	case changed:
+		return slog.String(a.Key, v), true, true
+	default:
+		return a, true, false
+	}
+}
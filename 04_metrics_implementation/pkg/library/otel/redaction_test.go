@@ -0,0 +1,206 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/log"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	testEmail        = "alice@example.com"
+	testEmailPattern = `[\w.+-]+@[\w-]+\.[\w.-]+`
+)
+
+// testStringer は fmt.Stringer を実装した値 (ログの slog.Any 用)
+type testStringer string
+
+func (s testStringer) String() string { return string(s) }
+
+// redactionTests は mask / hash / drop のそれぞれで、値の中のメールアドレスの置き換え方を返す
+//
+// want が空文字の場合は、属性が削除されること (RedactionDrop) を表す。
+func redactionTests(t *testing.T) []struct {
+	action   RedactionAction
+	redactor *Redactor
+	want     func(s string) string
+} {
+	t.Helper()
+	newRedactor := func(action RedactionAction) *Redactor {
+		r, err := NewRedactor(RedactionConfig{Rules: []RedactionRule{
+			{Keys: []string{"*email*"}, Values: []string{testEmailPattern}, Action: action},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	hashRedactor := newRedactor(RedactionHash)
+	return []struct {
+		action   RedactionAction
+		redactor *Redactor
+		want     func(s string) string
+	}{
+		{action: RedactionMask, redactor: newRedactor(RedactionMask), want: func(s string) string {
+			return strings.ReplaceAll(s, testEmail, redactedValue)
+		}},
+		{action: RedactionHash, redactor: hashRedactor, want: func(s string) string {
+			return strings.ReplaceAll(s, testEmail, hashRedactor.hash(testEmail))
+		}},
+		{action: RedactionDrop, redactor: newRedactor(RedactionDrop), want: func(string) string { return "" }},
+	}
+}
+
+func TestRedactionProcessor(t *testing.T) {
+	for _, tt := range redactionTests(t) {
+		t.Run(string(tt.action), func(t *testing.T) {
+			recorder := tracetest.NewSpanRecorder()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(NewRedactionProcessor(recorder, tt.redactor)))
+			t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+
+			_, span := tp.Tracer("test").Start(context.Background(), "UserUsecase.FindByEmail")
+			span.SetAttributes(
+				attribute.String("user.email", testEmail),
+				attribute.String("query", "email = "+testEmail),
+				attribute.String("article.id", "article-123"),
+			)
+			span.AddEvent("lookup", trace.WithAttributes(attribute.String("note", "cache miss for "+testEmail)))
+			span.RecordError(errors.New("user " + testEmail + " not found"))
+			span.SetStatus(codes.Error, "user "+testEmail+" not found")
+			span.End()
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(spans))
+			}
+			s := spans[0]
+
+			attrs := attribute.NewSet(s.Attributes()...)
+			if v, _ := attrs.Value("article.id"); v.AsString() != "article-123" {
+				t.Errorf("article.id = %q, want it unchanged", v.Emit())
+			}
+			// NOTE: キーに一致した属性は値全体、値の正規表現に一致した属性は一致した部分のみが置き換えられる
+			checkAttr(t, "span attribute user.email", attrs, "user.email", tt.want(testEmail), tt.action)
+			checkAttr(t, "span attribute query", attrs, "query", tt.want("email = "+testEmail), tt.action)
+			if tt.action == RedactionDrop && s.DroppedAttributes() != 2 {
+				t.Errorf("DroppedAttributes = %d, want 2", s.DroppedAttributes())
+			}
+
+			events := s.Events()
+			if len(events) != 2 {
+				t.Fatalf("recorded %d events, want 2", len(events))
+			}
+			checkAttr(t, "event attribute", attribute.NewSet(events[0].Attributes...), "note", tt.want("cache miss for "+testEmail), tt.action)
+			checkAttr(t, "exception.message", attribute.NewSet(events[1].Attributes...), "exception.message", tt.want("user "+testEmail+" not found"), tt.action)
+
+			// NOTE: ステータスの説明は削除できないため、RedactionDrop の場合は全体をマスクする
+			wantStatus := tt.want("user " + testEmail + " not found")
+			if tt.action == RedactionDrop {
+				wantStatus = redactedValue
+			}
+			if got := s.Status().Description; got != wantStatus {
+				t.Errorf("status description = %q, want %q", got, wantStatus)
+			}
+		})
+	}
+}
+
+func TestOTELHandlerRedactsAttributes(t *testing.T) {
+	for _, tt := range redactionTests(t) {
+		t.Run(string(tt.action), func(t *testing.T) {
+			logger, buf, recorder := newTestLogger(t, WithRedactor(tt.redactor))
+			logger.With(slog.String("query", "email = "+testEmail)).InfoContext(context.Background(), "user lookup failed",
+				slog.String("article.id", "article-123"),
+				slog.Any("error", errors.New("user "+testEmail+" not found")),
+				slog.Any("contact", testStringer("mailto:"+testEmail)),
+				slog.Group("request", slog.Group("user", slog.String("email", testEmail), slog.String("id", "user-1"))),
+			)
+
+			records := recorder.get()
+			if len(records) != 1 {
+				t.Fatalf("exported %d log records, want 1", len(records))
+			}
+			attrs := recordAttrs(records[0])
+			if attrs["article.id"].AsString() != "article-123" {
+				t.Errorf("article.id = %v, want it unchanged", attrs["article.id"])
+			}
+			// NOTE: error / fmt.Stringer の値も文字列として値の正規表現を照合する
+			for key, want := range map[string]string{
+				"query":   tt.want("email = " + testEmail),
+				"error":   tt.want("user " + testEmail + " not found"),
+				"contact": tt.want("mailto:" + testEmail),
+			} {
+				v, ok := attrs[key]
+				if want == "" {
+					if ok {
+						t.Errorf("LogRecord %s = %v, want it dropped", key, v)
+					}
+					continue
+				}
+				if v.AsString() != want {
+					t.Errorf("LogRecord %s = %v, want %q", key, v, want)
+				}
+			}
+
+			// NOTE: グループ内の属性は request.user.email のキーで照合される
+			user := logMapValue(t, logMapValue(t, attrs["request"])["user"])
+			if got := user["id"].AsString(); got != "user-1" {
+				t.Errorf("LogRecord request.user.id = %q, want it unchanged", got)
+			}
+			email, ok := user["email"]
+			switch want := tt.want(testEmail); {
+			case want == "" && ok:
+				t.Errorf("LogRecord request.user.email = %v, want it dropped", email)
+			case want != "" && email.AsString() != want:
+				t.Errorf("LogRecord request.user.email = %v, want %q", email, want)
+			}
+
+			// NOTE: slog の出力にも同じルールが適用される
+			out := buf.String()
+			if strings.Contains(out, testEmail) {
+				t.Errorf("JSON output contains the email:\n%s", out)
+			}
+			line := jsonLines(t, buf)[0]
+			if got, _ := line["error"].(string); got != tt.want("user "+testEmail+" not found") {
+				t.Errorf("JSON error = %v, want %q", line["error"], tt.want("user "+testEmail+" not found"))
+			}
+		})
+	}
+}
+
+// checkAttr は attrs の key の値が want であること (want が空文字の場合は key が削除されていること) を確認する
+func checkAttr(t *testing.T, name string, attrs attribute.Set, key, want string, action RedactionAction) {
+	t.Helper()
+	v, ok := attrs.Value(attribute.Key(key))
+	if want == "" {
+		if ok {
+			t.Errorf("%s = %q, want it dropped by %s", name, v.Emit(), action)
+		}
+		return
+	}
+	if v.AsString() != want {
+		t.Errorf("%s = %q, want %q", name, v.Emit(), want)
+	}
+}
+
+// logMapValue は LogRecord のグループの属性を key → 値の map で返す
+func logMapValue(t *testing.T, v log.Value) map[string]log.Value {
+	t.Helper()
+	if v.Kind() != log.KindMap {
+		t.Fatalf("value = %v, want a group", v)
+	}
+	out := make(map[string]log.Value)
+	for _, kv := range v.AsMap() {
+		out[kv.Key] = kv.Value
+	}
+	return out
+}
@@ -0,0 +1,234 @@
+package otel
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"runtime/debug"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ResourceDetectorsConfig はリソースに付与する実行環境の情報を検出する Detector の有効・無効
+//
+// リソースの属性は全てのスパン・メトリクス・ログに共通で付与されるため、
+// 「どのホスト / コンテナ / Pod のどのビルドが出力したテレメトリか」をバックエンド側で絞り込めるようになる。
+// NOTE: 検出に失敗した属性は付与されないだけで、Provider の初期化自体は継続する。
+type ResourceDetectorsConfig struct {
+	// Host は host.name を付与する
+	Host bool
+
+	// OS は os.type / os.description を付与する
+	OS bool
+
+	// Process は process.pid / process.executable.name / process.runtime.{name, version, description} を付与する
+	// NOTE: コマンドライン引数 (process.command_args) は機密情報を含む可能性があるため付与しない
+	Process bool
+
+	// ServiceInstance は起動ごとに生成した UUID を service.instance.id として付与する。
+	// 同じ service.name の複数インスタンスのメトリクスを区別するために使用する
+	ServiceInstance bool
+
+	// Container は /proc/self/cgroup (cgroup v1) または /proc/self/mountinfo (cgroup v2) からコンテナ ID を検出し、container.id として付与する
+	Container bool
+
+	// Kubernetes は Downward API で設定された環境変数から k8s.pod.name / k8s.pod.uid / k8s.namespace.name / k8s.node.name を付与する
+	// (環境変数は kubernetesEnvs を参照)
+	Kubernetes bool
+
+	// BuildInfo は debug.ReadBuildInfo から取得したビルド元のコミット (vcs.ref.head.revision)、コミット日時 (vcs.time)、
+	// 未コミットの変更の有無 (vcs.modified) を付与する。
+	// NOTE: Go のビルド情報にはビルド日時が含まれないため、ビルドしたコミットの日時をビルド時刻の代わりに使用する
+	BuildInfo bool
+}
+
+// kubernetesEnvs は Kubernetes の属性と、値を読み取る環境変数 (先頭から順に参照する) の対応
+//
+// Deployment のマニフェストで Downward API を使って設定する:
+//
+//	env:
+//	  - name: K8S_POD_NAME
+//	    valueFrom: {fieldRef: {fieldPath: metadata.name}}
+//	  - name: K8S_POD_UID
+//	    valueFrom: {fieldRef: {fieldPath: metadata.uid}}
+//	  - name: K8S_NAMESPACE_NAME
+//	    valueFrom: {fieldRef: {fieldPath: metadata.namespace}}
+//	  - name: K8S_NODE_NAME
+//	    valueFrom: {fieldRef: {fieldPath: spec.nodeName}}
+var kubernetesEnvs = []struct {
+	attr func(string) attribute.KeyValue
+	envs []string
+}{
+	{semconv.K8SPodName, []string{"K8S_POD_NAME", "POD_NAME"}},
+	{semconv.K8SPodUID, []string{"K8S_POD_UID", "POD_UID"}},
+	{semconv.K8SNamespaceName, []string{"K8S_NAMESPACE_NAME", "POD_NAMESPACE"}},
+	{semconv.K8SNodeName, []string{"K8S_NODE_NAME", "NODE_NAME"}},
+}
+
+// resourceOptions は有効な Detector を resource.Option に変換する
+func (c ResourceDetectorsConfig) resourceOptions() []resource.Option {
+	var opts []resource.Option
+	if c.Host {
+		opts = append(opts, resource.WithHost())
+	}
+	if c.OS {
+		opts = append(opts, resource.WithOS())
+	}
+	if c.Process {
+		opts = append(opts,
+			resource.WithProcessPID(),
+			resource.WithProcessExecutableName(),
+			resource.WithProcessRuntimeName(),
+			resource.WithProcessRuntimeVersion(),
+			resource.WithProcessRuntimeDescription(),
+		)
+	}
+
+	var detectors []resource.Detector
+	if c.ServiceInstance {
+		detectors = append(detectors, detectorFunc(detectServiceInstance))
+	}
+	if c.Container {
+		detectors = append(detectors, detectorFunc(detectContainer))
+	}
+	if c.Kubernetes {
+		detectors = append(detectors, detectorFunc(detectKubernetes))
+	}
+	if c.BuildInfo {
+		detectors = append(detectors, detectorFunc(detectBuildInfo))
+	}
+	if len(detectors) > 0 {
+		opts = append(opts, resource.WithDetectors(detectors...))
+	}
+	return opts
+}
+
+// newResource は Detector で検出した属性と attrs を持つリソースを生成する
+//
+// NOTE: attrs (サービス名や Config.ResourceAttributes) は Detector より後に適用されるため、同じキーの場合は attrs が優先される。
+func newResource(ctx context.Context, detectors ResourceDetectorsConfig, attrs []attribute.KeyValue) (*resource.Resource, error) {
+	opts := append(detectors.resourceOptions(), resource.WithAttributes(attrs...))
+	res, err := resource.New(ctx, opts...)
+	if errors.Is(err, resource.ErrPartialResource) {
+		// NOTE: 一部の Detector が失敗した場合も、検出できた属性でリソースを生成する
+		slog.WarnContext(ctx, "otel: some resource attributes could not be detected", slog.String("error", err.Error()))
+		return res, nil
+	}
+	return res, err
+}
+
+// detectorFunc は関数を resource.Detector として扱うためのアダプタ
+type detectorFunc func(ctx context.Context) (*resource.Resource, error)
+
+// Detect は f を呼び出す
+func (f detectorFunc) Detect(ctx context.Context) (*resource.Resource, error) {
+	return f(ctx)
+}
+
+// detectServiceInstance は UUID v4 を生成し service.instance.id として返す
+func detectServiceInstance(context.Context) (*resource.Resource, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return nil, fmt.Errorf("generate service.instance.id: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // NOTE: バージョン 4
+	b[8] = (b[8] & 0x3f) | 0x80 // NOTE: バリアント RFC 4122
+	id := fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+	return resource.NewSchemaless(semconv.ServiceInstanceID(id)), nil
+}
+
+// containerIDSources はコンテナ ID を探すファイルと、コンテナ ID (64桁の16進数) を取り出す正規表現 (先頭から順に参照する)
+//
+// 例:
+//   - cgroup v1: "12:memory:/docker/<id>", "11:cpu:/kubepods/burstable/pod<uid>/cri-containerd-<id>.scope"
+//   - cgroup v2: "... /var/lib/docker/containers/<id>/hostname /etc/hostname ..."
+//
+// NOTE: mountinfo には overlay2 のレイヤー ID 等の無関係な64桁の16進数も含まれるため、containers/<id>/ の形式のみを対象とする
+var containerIDSources = []struct {
+	path    string
+	pattern *regexp.Regexp
+}{
+	{"/proc/self/cgroup", regexp.MustCompile(`(?:^|[/:-])([0-9a-f]{64})(?:\.scope|/|$)`)},
+	{"/proc/self/mountinfo", regexp.MustCompile(`/containers/([0-9a-f]{64})/`)},
+}
+
+// detectContainer は cgroup の情報からコンテナ ID を検出し container.id として返す。コンテナ外で実行している場合は空のリソースを返す
+func detectContainer(context.Context) (*resource.Resource, error) {
+	for _, src := range containerIDSources {
+		id, err := findContainerID(src.path, src.pattern)
+		if err != nil {
+			return nil, err
+		}
+		if id != "" {
+			return resource.NewSchemaless(semconv.ContainerID(id)), nil
+		}
+	}
+	return resource.Empty(), nil
+}
+
+// findContainerID は path の各行から pattern に一致するコンテナ ID を探す。ファイルが存在しない場合は空文字を返す
+func findContainerID(path string, pattern *regexp.Regexp) (string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("detect container.id: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if m := pattern.FindStringSubmatch(scanner.Text()); m != nil {
+			return m[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("detect container.id: %w", err)
+	}
+	return "", nil
+}
+
+// detectKubernetes は Downward API の環境変数から Kubernetes の属性を返す。設定されていない属性は付与しない
+func detectKubernetes(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	for _, k := range kubernetesEnvs {
+		for _, env := range k.envs {
+			if v := os.Getenv(env); v != "" {
+				attrs = append(attrs, k.attr(v))
+				break
+			}
+		}
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
+
+// detectBuildInfo は debug.ReadBuildInfo の VCS 情報を返す
+//
+// NOTE: VCS 情報は go build 時にリポジトリ内でビルドした場合のみ埋め込まれる (go run や -buildvcs=false の場合は付与されない)。
+func detectBuildInfo(context.Context) (*resource.Resource, error) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return resource.Empty(), nil
+	}
+	var attrs []attribute.KeyValue
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			attrs = append(attrs, attribute.String("vcs.ref.head.revision", s.Value))
+		case "vcs.time":
+			attrs = append(attrs, attribute.String("vcs.time", s.Value))
+		case "vcs.modified":
+			attrs = append(attrs, attribute.Bool("vcs.modified", s.Value == "true"))
+		}
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
@@ -0,0 +1,146 @@
+package otel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+)
+
+const testContainerID = "5b6f3a1c9e2d4f8a7b0c1d2e3f405162738495a6b7c8d9e0f1a2b3c4d5e6f7a8"
+
+func TestFindContainerID(t *testing.T) {
+	cgroup, mountinfo := containerIDSources[0], containerIDSources[1]
+	layerID := strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name      string
+		mountinfo bool
+		content   string
+		want      string
+	}{
+		{
+			name:    "cgroup v1 docker",
+			content: "13:pids:/\n12:memory:/docker/" + testContainerID + "\n",
+			want:    testContainerID,
+		},
+		{
+			name:    "cgroup v1 kubernetes with containerd scope",
+			content: "11:cpu:/kubepods/burstable/pod7e3a1c2b-0d4f-4e5a-9b8c-1d2e3f405162/cri-containerd-" + testContainerID + ".scope\n",
+			want:    testContainerID,
+		},
+		{
+			name:    "cgroup v1 kubernetes with cri-o",
+			content: "1:name=systemd:/kubepods.slice/kubepods-besteffort.slice/crio-" + testContainerID + ".scope\n",
+			want:    testContainerID,
+		},
+		{
+			name:    "cgroup v2 has no container id in cgroup",
+			content: "0::/\n",
+		},
+		{
+			name:      "cgroup v2 mountinfo hostname mount",
+			mountinfo: true,
+			content: "714 688 0:55 / / rw,relatime - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/" + layerID + "\n" +
+				"735 714 254:1 /var/lib/docker/containers/" + testContainerID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw\n",
+			want: testContainerID,
+		},
+		{
+			name:      "cgroup v2 mountinfo ignores overlay layer ids",
+			mountinfo: true,
+			content:   "714 688 0:55 / / rw,relatime - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/" + layerID + "/diff\n",
+		},
+		{
+			name:    "shorter hex id is not a container id",
+			content: "12:memory:/docker/" + testContainerID[:63] + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := cgroup
+			if tt.mountinfo {
+				src = mountinfo
+			}
+			path := filepath.Join(t.TempDir(), filepath.Base(src.path))
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			got, err := findContainerID(path, src.pattern)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("container id = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// NOTE: コンテナ外 (ファイルが存在しない) 場合はエラーにしない
+	got, err := findContainerID(filepath.Join(t.TempDir(), "missing"), cgroup.pattern)
+	if err != nil || got != "" {
+		t.Errorf("missing file = %q, %v, want no id and no error", got, err)
+	}
+}
+
+func TestDetectKubernetes(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []attribute.KeyValue
+	}{
+		{
+			name: "downward api variables",
+			env: map[string]string{
+				"K8S_POD_NAME":       "article-api-7d9f8b6c5-x2k4p",
+				"K8S_POD_UID":        "7e3a1c2b-0d4f-4e5a-9b8c-1d2e3f405162",
+				"K8S_NAMESPACE_NAME": "article",
+				"K8S_NODE_NAME":      "node-1",
+			},
+			want: []attribute.KeyValue{
+				attribute.String("k8s.pod.name", "article-api-7d9f8b6c5-x2k4p"),
+				attribute.String("k8s.pod.uid", "7e3a1c2b-0d4f-4e5a-9b8c-1d2e3f405162"),
+				attribute.String("k8s.namespace.name", "article"),
+				attribute.String("k8s.node.name", "node-1"),
+			},
+		},
+		{
+			name: "fallback variable names",
+			env:  map[string]string{"POD_NAME": "article-api-0", "POD_NAMESPACE": "default", "NODE_NAME": "node-2"},
+			want: []attribute.KeyValue{
+				attribute.String("k8s.pod.name", "article-api-0"),
+				attribute.String("k8s.namespace.name", "default"),
+				attribute.String("k8s.node.name", "node-2"),
+			},
+		},
+		{
+			name: "K8S_ prefix takes precedence",
+			env:  map[string]string{"K8S_POD_NAME": "preferred", "POD_NAME": "fallback"},
+			want: []attribute.KeyValue{attribute.String("k8s.pod.name", "preferred")},
+		},
+		{
+			name: "outside kubernetes",
+			env:  map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// NOTE: 実行環境の変数の影響を受けないように、全ての変数を空にしてから設定する
+			for _, k := range kubernetesEnvs {
+				for _, env := range k.envs {
+					t.Setenv(env, tt.env[env])
+				}
+			}
+			res, err := detectKubernetes(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := resource.NewSchemaless(tt.want...); !res.Equal(want) {
+				t.Errorf("resource = %v, want %v", res.Attributes(), want.Attributes())
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileConfig は JSON Lines 形式でファイルに出力する場合の設定
+//
+// ファイルはサイズまたは経過時間でローテーションされ、ローテーション後のファイルは
+// "<Path>.<yyyymmddThhmmss.000>" にリネームされる。
+type FileConfig struct {
+	// Path は出力先のファイルパス (例: "/var/log/article-api/spans.jsonl")。ディレクトリが存在しない場合は作成する
+	Path string
+
+	// MaxSize はローテーションするファイルサイズ (バイト)。0 の場合は100MB
+	MaxSize int64
+
+	// RotateInterval はファイルを開いてからローテーションするまでの時間。0 の場合は時間によるローテーションを行わない
+	RotateInterval time.Duration
+
+	// MaxBackups はローテーション後のファイルを保持する数。0 の場合は数による削除を行わない
+	MaxBackups int
+
+	// MaxAge はローテーション後のファイルを保持する期間。0 の場合は期間による削除を行わない
+	MaxAge time.Duration
+}
+
+const (
+	defaultFileMaxSize = 100 * 1024 * 1024
+	// rotatedSuffixLayout はローテーション後のファイル名に付与する時刻のフォーマット
+	rotatedSuffixLayout = "20060102T150405.000"
+)
+
+// rotatingFile はサイズ・時間でローテーションする io.WriteCloser
+//
+// NOTE: json.Encoder は1オブジェクトを1回の Write で書き込むため、ローテーションによって1行が2ファイルに分割されることはない。
+type rotatingFile struct {
+	cfg FileConfig
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	closed   bool
+	// renamed は file をリネーム済みで、cfg.Path に新しいファイルをまだ開けていない状態かを表す
+	renamed bool
+
+	openFile func(name string, flag int, perm os.FileMode) (*os.File, error) // NOTE: テストで差し替えるため
+}
+
+// newRotatingFile は cfg.Path を追記モードで開いた rotatingFile を生成する
+func newRotatingFile(cfg FileConfig) (*rotatingFile, error) {
+	if cfg.Path == "" {
+		return nil, errors.New("otel: file exporter requires Path")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = defaultFileMaxSize
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.Path), 0o755); err != nil {
+		return nil, fmt.Errorf("otel: create directory for %q: %w", cfg.Path, err)
+	}
+
+	f := &rotatingFile{cfg: cfg, openFile: os.OpenFile}
+	file, size, err := f.open()
+	if err != nil {
+		return nil, err
+	}
+	f.file, f.size, f.openedAt = file, size, time.Now()
+	return f, nil
+}
+
+// open は cfg.Path を追記モードで開き、ファイルと現在のサイズを返す
+func (f *rotatingFile) open() (*os.File, int64, error) {
+	file, err := f.openFile(f.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, 0, fmt.Errorf("otel: open %q: %w", f.cfg.Path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, fmt.Errorf("otel: stat %q: %w", f.cfg.Path, err)
+	}
+	return file, info.Size(), nil
+}
+
+// Write は p を書き込む。書き込み後のサイズが MaxSize を超える場合、または RotateInterval を経過した場合は先にローテーションする
+//
+// NOTE: ローテーションに失敗した場合もテレメトリを失わないよう現在のファイルに書き込み、ローテーションのエラーを返す。
+// ローテーションは次回の Write で再試行される。
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	var rotateErr error
+	expired := f.cfg.RotateInterval > 0 && time.Since(f.openedAt) >= f.cfg.RotateInterval
+	if f.renamed || (f.size > 0 && (f.size+int64(len(p)) > f.cfg.MaxSize || expired)) {
+		rotateErr = f.rotate()
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, errors.Join(rotateErr, err)
+}
+
+// rotate は現在のファイルをリネームして新しいファイルを開き、保持数・保持期間を超えたファイルを削除する
+//
+// NOTE: 新しいファイルを開けるまでは現在のファイル (リネーム後) を開いたままにし、書き込みを続けられるようにする。
+func (f *rotatingFile) rotate() error {
+	if !f.renamed {
+		rotated := f.cfg.Path + "." + time.Now().Format(rotatedSuffixLayout)
+		if err := os.Rename(f.cfg.Path, rotated); err != nil {
+			return fmt.Errorf("otel: rotate %q: %w", f.cfg.Path, err)
+		}
+		f.renamed = true
+	}
+	file, size, err := f.open()
+	if err != nil {
+		return err
+	}
+	closeErr := f.file.Close()
+	f.file, f.size, f.openedAt = file, size, time.Now()
+	f.renamed = false
+	f.removeExpired()
+	if closeErr != nil {
+		return fmt.Errorf("otel: close rotated file of %q: %w", f.cfg.Path, closeErr)
+	}
+	return nil
+}
+
+// removeExpired は MaxBackups / MaxAge を超えたローテーション後のファイルを削除する
+//
+// NOTE: 削除の失敗はテレメトリの出力自体を止めるほどの問題ではないため無視する。
+func (f *rotatingFile) removeExpired() {
+	if f.cfg.MaxBackups <= 0 && f.cfg.MaxAge <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(f.cfg.Path + ".*")
+	if err != nil {
+		return
+	}
+	type backup struct {
+		path string
+		at   time.Time
+	}
+	var backups []backup
+	for _, m := range matches {
+		at, err := time.ParseInLocation(rotatedSuffixLayout, strings.TrimPrefix(m, f.cfg.Path+"."), time.Local)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: m, at: at})
+	}
+	// 新しい順に並べ、先頭から MaxBackups 件を残す
+	slices.SortFunc(backups, func(a, b backup) int { return b.at.Compare(a.at) })
+
+	for i, b := range backups {
+		tooMany := f.cfg.MaxBackups > 0 && i >= f.cfg.MaxBackups
+		tooOld := f.cfg.MaxAge > 0 && time.Since(b.at) > f.cfg.MaxAge
+		if tooMany || tooOld {
+			_ = os.Remove(b.path)
+		}
+	}
+}
+
+// Sync は書き込み済みのデータをディスクに同期する
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Close はディスクに同期してからファイルを閉じる。複数回呼んでも安全
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return errors.Join(f.file.Sync(), f.file.Close())
+}
@@ -0,0 +1,56 @@
+package otel
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotatingFileKeepsWritingWhenReopenFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	f, err := newRotatingFile(FileConfig{Path: path, MaxSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write([]byte("first---\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// NOTE: リネーム後に新しいファイルを開けない場合も、リネーム後のファイルに書き込みを続ける
+	f.openFile = func(string, int, os.FileMode) (*os.File, error) { return nil, os.ErrPermission }
+	n, err := f.Write([]byte("second--\n"))
+	if !errors.Is(err, os.ErrPermission) {
+		t.Errorf("Write error = %v, want the reopen error", err)
+	}
+	if n != len("second--\n") {
+		t.Errorf("Write wrote %d bytes, want the whole record", n)
+	}
+
+	// NOTE: 次の Write で新しいファイルを開き直す
+	f.openFile = os.OpenFile
+	if _, err := f.Write([]byte("third---\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "third---\n" {
+		t.Errorf("current file = %q, want only the record written after reopening", current)
+	}
+	rotated, err := filepath.Glob(path + ".*")
+	if err != nil || len(rotated) != 1 {
+		t.Fatalf("rotated files = %v (%v), want 1", rotated, err)
+	}
+	old, err := os.ReadFile(rotated[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(old) != "first---\nsecond--\n" {
+		t.Errorf("rotated file = %q, want the records written before reopening", old)
+	}
+}
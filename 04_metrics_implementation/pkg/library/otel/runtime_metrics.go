@@ -0,0 +1,281 @@
+package otel
+
+import (
+	"context"
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// NOTE: Go ランタイムとプロセスのメトリクス
+// Config.RuntimeMetrics を有効にすると、NewProvider が以下の計器を MeterProvider に登録する。
+// 計装コードを書かなくても、全てのインスタンスでメモリリークやゴルーチンリーク、GC による停止時間の悪化を監視できる。
+//
+// 名前と単位は OpenTelemetry の Go ランタイム / プロセスのセマンティック規約に従う:
+//   - go.goroutine.count                 : ゴルーチン数
+//   - go.processor.limit                 : GOMAXPROCS
+//   - go.memory.used                     : Go ランタイムが使用中のメモリ (go.memory.type: stack / other)
+//   - go.memory.limit                    : GOMEMLIMIT (未設定の場合は記録しない)
+//   - go.memory.allocated                : ヒープに割り当てたバイト数の累計
+//   - go.memory.allocations              : ヒープに割り当てたオブジェクト数の累計
+//   - go.memory.gc.goal                  : 次の GC が実行されるヒープサイズ
+//   - go.config.gogc                     : GOGC
+//   - process.cpu.time                   : CPU 時間の累計 (cpu.mode: user / system)
+//   - process.open_file_descriptor.count : 開いているファイルディスクリプタ数 (Linux のみ)
+//   - process.uptime                     : プロセスの起動からの経過時間
+//
+// GC の回数と停止時間はセマンティック規約が未定義のため、go.* の命名に合わせて以下の名前で記録する:
+//   - go.gc.count          : GC の実行回数の累計
+//   - go.gc.pause.duration : GC による Stop-The-World の停止時間の分布 (Histogram)
+//
+// 値は runtime/metrics から取得する (runtime.ReadMemStats と異なり Stop-The-World が発生しない)。
+// go.gc.pause.duration 以外は Observable な計器として、Reader ごとの収集時に観測する。
+
+// processStartTime はプロセスの起動時刻 (パッケージの初期化時刻で近似する)
+var processStartTime = time.Now()
+
+// runtime/metrics から読み取るメトリクス名
+const (
+	rmMemoryTotal      = "/memory/classes/total:bytes"
+	rmMemoryReleased   = "/memory/classes/heap/released:bytes"
+	rmMemoryStacks     = "/memory/classes/heap/stacks:bytes"
+	rmMemoryOSStacks   = "/memory/classes/os-stacks:bytes"
+	rmMemoryLimit      = "/gc/gomemlimit:bytes"
+	rmHeapAllocBytes   = "/gc/heap/allocs:bytes"
+	rmHeapAllocObjects = "/gc/heap/allocs:objects"
+	rmHeapGoal         = "/gc/heap/goal:bytes"
+	rmGOGC             = "/gc/gogc:percent"
+	rmGoroutines       = "/sched/goroutines:goroutines"
+	rmGOMAXPROCS       = "/sched/gomaxprocs:threads"
+	rmGCCycles         = "/gc/cycles/total:gc-cycles"
+	rmGCPauses         = "/sched/pauses/total/gc:seconds"
+)
+
+// runtimeMetrics は Go ランタイムとプロセスのメトリクスを観測する
+type runtimeMetrics struct {
+	gcPause metric.Float64Histogram
+
+	mu      sync.Mutex
+	samples []metrics.Sample
+	index   map[string]int
+
+	pauseMu   sync.Mutex
+	pauses    []metrics.Sample
+	lastPause []uint64 // NOTE: 前回記録時の GC 停止時間のバケットごとの累計 (差分を Histogram に記録する)
+
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// newRuntimeMetrics は mp に Go ランタイムとプロセスの計器を登録する。Observable な計器はメトリクスの収集ごとに観測される
+//
+// NOTE: Observable な計器の観測は MeterProvider の収集に合わせて行われるため、MeterProvider のシャットダウン後は自動的に停止する。
+// GC の停止時間は start で開始し、stop で停止する。
+func newRuntimeMetrics(mp metric.MeterProvider) (*runtimeMetrics, error) {
+	meter := mp.Meter(instrumentationName)
+	r := &runtimeMetrics{
+		index:  make(map[string]int),
+		pauses: []metrics.Sample{{Name: rmGCPauses}},
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+	for i, name := range []string{
+		rmMemoryTotal, rmMemoryReleased, rmMemoryStacks, rmMemoryOSStacks, rmMemoryLimit,
+		rmHeapAllocBytes, rmHeapAllocObjects, rmHeapGoal, rmGOGC,
+		rmGoroutines, rmGOMAXPROCS, rmGCCycles,
+	} {
+		r.samples = append(r.samples, metrics.Sample{Name: name})
+		r.index[name] = i
+	}
+
+	goroutines, err := meter.Int64ObservableUpDownCounter("go.goroutine.count",
+		metric.WithDescription("ゴルーチン数"), metric.WithUnit("{goroutine}"))
+	if err != nil {
+		return nil, err
+	}
+	processorLimit, err := meter.Int64ObservableUpDownCounter("go.processor.limit",
+		metric.WithDescription("ゴルーチンを同時に実行できる OS スレッド数 (GOMAXPROCS)"), metric.WithUnit("{thread}"))
+	if err != nil {
+		return nil, err
+	}
+	memoryUsed, err := meter.Int64ObservableUpDownCounter("go.memory.used",
+		metric.WithDescription("Go ランタイムが使用中のメモリ"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	memoryLimit, err := meter.Int64ObservableUpDownCounter("go.memory.limit",
+		metric.WithDescription("Go ランタイムのメモリ使用量の上限 (GOMEMLIMIT)"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	memoryAllocated, err := meter.Int64ObservableCounter("go.memory.allocated",
+		metric.WithDescription("ヒープに割り当てたメモリの累計"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	memoryAllocations, err := meter.Int64ObservableCounter("go.memory.allocations",
+		metric.WithDescription("ヒープに割り当てたオブジェクト数の累計"), metric.WithUnit("{allocation}"))
+	if err != nil {
+		return nil, err
+	}
+	gcGoal, err := meter.Int64ObservableUpDownCounter("go.memory.gc.goal",
+		metric.WithDescription("次の GC が実行されるヒープサイズ"), metric.WithUnit("By"))
+	if err != nil {
+		return nil, err
+	}
+	gogc, err := meter.Int64ObservableUpDownCounter("go.config.gogc",
+		metric.WithDescription("GC の実行頻度を決めるヒープ増加率 (GOGC)"), metric.WithUnit("%"))
+	if err != nil {
+		return nil, err
+	}
+	gcCount, err := meter.Int64ObservableCounter("go.gc.count",
+		metric.WithDescription("GC の実行回数の累計"), metric.WithUnit("{gc_cycle}"))
+	if err != nil {
+		return nil, err
+	}
+	cpuTime, err := meter.Float64ObservableCounter("process.cpu.time",
+		metric.WithDescription("プロセスが使用した CPU 時間の累計"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	openFDs, err := meter.Int64ObservableUpDownCounter("process.open_file_descriptor.count",
+		metric.WithDescription("プロセスが開いているファイルディスクリプタ数"), metric.WithUnit("{count}"))
+	if err != nil {
+		return nil, err
+	}
+	uptime, err := meter.Float64ObservableGauge("process.uptime",
+		metric.WithDescription("プロセスの起動からの経過時間"), metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	r.gcPause, err = meter.Float64Histogram("go.gc.pause.duration",
+		metric.WithDescription("GC による Stop-The-World の停止時間"), metric.WithUnit("s"),
+		metric.WithExplicitBucketBoundaries(0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1))
+	if err != nil {
+		return nil, err
+	}
+
+	stackAttrs := metric.WithAttributes(attribute.String("go.memory.type", "stack"))
+	otherAttrs := metric.WithAttributes(attribute.String("go.memory.type", "other"))
+	userAttrs := metric.WithAttributes(attribute.String("cpu.mode", "user"))
+	systemAttrs := metric.WithAttributes(attribute.String("cpu.mode", "system"))
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		metrics.Read(r.samples)
+
+		stack := r.uint64(rmMemoryStacks) + r.uint64(rmMemoryOSStacks)
+		used := r.uint64(rmMemoryTotal) - r.uint64(rmMemoryReleased)
+		o.ObserveInt64(memoryUsed, int64(stack), stackAttrs)
+		o.ObserveInt64(memoryUsed, int64(used-stack), otherAttrs)
+		// NOTE: GOMEMLIMIT 未設定時は math.MaxInt64 となるため記録しない
+		if limit := r.uint64(rmMemoryLimit); limit != math.MaxInt64 {
+			o.ObserveInt64(memoryLimit, int64(limit))
+		}
+		o.ObserveInt64(memoryAllocated, int64(r.uint64(rmHeapAllocBytes)))
+		o.ObserveInt64(memoryAllocations, int64(r.uint64(rmHeapAllocObjects)))
+		o.ObserveInt64(gcGoal, int64(r.uint64(rmHeapGoal)))
+		o.ObserveInt64(gogc, int64(r.uint64(rmGOGC)))
+		o.ObserveInt64(goroutines, int64(r.uint64(rmGoroutines)))
+		o.ObserveInt64(processorLimit, int64(r.uint64(rmGOMAXPROCS)))
+		o.ObserveInt64(gcCount, int64(r.uint64(rmGCCycles)))
+
+		if user, system, ok := processCPUTime(); ok {
+			o.ObserveFloat64(cpuTime, user.Seconds(), userAttrs)
+			o.ObserveFloat64(cpuTime, system.Seconds(), systemAttrs)
+		}
+		if n, ok := openFileDescriptors(); ok {
+			o.ObserveInt64(openFDs, int64(n))
+		}
+		o.ObserveFloat64(uptime, time.Since(processStartTime).Seconds())
+		return nil
+	}, goroutines, processorLimit, memoryUsed, memoryLimit, memoryAllocated, memoryAllocations,
+		gcGoal, gogc, gcCount, cpuTime, openFDs, uptime)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// start は interval ごとに GC の停止時間を記録する goroutine を起動する
+//
+// NOTE: 同期の Histogram への記録は全ての Reader に反映されるため、Reader ごとに呼ばれる Observable のコールバックではなく、
+// 独立した goroutine から1回だけ記録する。
+func (r *runtimeMetrics) start(interval time.Duration) {
+	go func() {
+		defer close(r.doneCh)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stopCh:
+				return
+			case <-ticker.C:
+				r.recordGCPauses(context.Background())
+			}
+		}
+	}()
+}
+
+// stop は GC の停止時間を記録する goroutine を停止し、最後の記録以降に発生した停止時間を記録する。複数回呼んでも安全
+func (r *runtimeMetrics) stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopCh)
+		<-r.doneCh
+		r.recordGCPauses(context.Background())
+	})
+}
+
+// uint64 は読み取ったメトリクスの値を返す (未対応の Go バージョンの場合は 0)
+func (r *runtimeMetrics) uint64(name string) uint64 {
+	v := r.samples[r.index[name]].Value
+	if v.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return v.Uint64()
+}
+
+// recordGCPauses は前回の記録以降に発生した GC の停止時間を go.gc.pause.duration に記録する
+//
+// NOTE: runtime/metrics の停止時間は起動時からの累計の Histogram として提供されるため、バケットごとの増分を
+// バケットの中央値で記録する。ランタイムのバケットは十分に細かいため、誤差は分析上問題にならない。
+// Observable な Histogram は存在しないため、同期の Histogram に定期的に記録する (値は次回の収集に含まれる)。
+func (r *runtimeMetrics) recordGCPauses(ctx context.Context) {
+	r.pauseMu.Lock()
+	defer r.pauseMu.Unlock()
+	metrics.Read(r.pauses)
+
+	v := r.pauses[0].Value
+	if v.Kind() != metrics.KindFloat64Histogram {
+		return
+	}
+	h := v.Float64Histogram()
+	if len(r.lastPause) != len(h.Counts) {
+		// NOTE: 初回は起動時からの累計をそのまま記録する
+		r.lastPause = make([]uint64, len(h.Counts))
+	}
+	for i, count := range h.Counts {
+		delta := count - r.lastPause[i]
+		r.lastPause[i] = count
+		if delta == 0 {
+			continue
+		}
+		lower, upper := h.Buckets[i], h.Buckets[i+1]
+		value := (lower + upper) / 2
+		switch {
+		case math.IsInf(lower, -1):
+			value = upper
+		case math.IsInf(upper, 1):
+			value = lower
+		}
+		for range delta {
+			r.gcPause.Record(ctx, value)
+		}
+	}
+}
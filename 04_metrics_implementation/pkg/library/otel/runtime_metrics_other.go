@@ -0,0 +1,15 @@
+//go:build !unix
+
+package otel
+
+import "time"
+
+// processCPUTime は unix 以外の OS では取得できないため ok=false を返す
+func processCPUTime() (user, system time.Duration, ok bool) {
+	return 0, 0, false
+}
+
+// openFileDescriptors は unix 以外の OS では取得できないため ok=false を返す
+func openFileDescriptors() (int, bool) {
+	return 0, false
+}
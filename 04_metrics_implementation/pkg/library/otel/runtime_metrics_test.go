@@ -0,0 +1,65 @@
+package otel
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// gcPauseCount は reader から収集した go.gc.pause.duration の記録数を返す
+func gcPauseCount(t *testing.T, reader sdkmetric.Reader) uint64 {
+	t.Helper()
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if h, ok := m.Data.(metricdata.Histogram[float64]); ok && m.Name == "go.gc.pause.duration" {
+				var count uint64
+				for _, dp := range h.DataPoints {
+					count += dp.Count
+				}
+				return count
+			}
+		}
+	}
+	return 0
+}
+
+func TestGCPausesAreRecordedOnceForAllReaders(t *testing.T) {
+	first, second := sdkmetric.NewManualReader(), sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(first), sdkmetric.WithReader(second))
+	defer mp.Shutdown(context.Background())
+
+	r, err := newRuntimeMetrics(mp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.GC()
+	r.recordGCPauses(context.Background())
+
+	want := gcPauseCount(t, first)
+	if want == 0 {
+		t.Fatal("no GC pauses recorded after runtime.GC")
+	}
+	if got := gcPauseCount(t, second); got != want {
+		t.Errorf("second reader counted %d pauses, want %d as the first reader", got, want)
+	}
+
+	// NOTE: 収集 (Observable のコールバック) では停止時間を記録しない
+	runtime.GC()
+	if got := gcPauseCount(t, first); got != want {
+		t.Errorf("collecting recorded pauses: got %d, want %d", got, want)
+	}
+
+	r.start(time.Hour)
+	r.stop()
+	if got := gcPauseCount(t, second); got <= want {
+		t.Errorf("stop did not record the pending pauses: got %d, want more than %d", got, want)
+	}
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package otel
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// processCPUTime はプロセスが使用したユーザー / システム CPU 時間の累計を返す
+func processCPUTime() (user, system time.Duration, ok bool) {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0, 0, false
+	}
+	return time.Duration(ru.Utime.Nano()), time.Duration(ru.Stime.Nano()), true
+}
+
+// openFileDescriptors はプロセスが開いているファイルディスクリプタ数を返す
+//
+// NOTE: /proc が存在しない OS (macOS 等) では取得できないため ok=false を返す。
+func openFileDescriptors() (int, bool) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	return len(entries), true
+}
@@ -0,0 +1,92 @@
+package otel
+
+import (
+	"fmt"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SamplerType はトレースのサンプリング戦略 (OTEL_TRACES_SAMPLER と同じ名前を使用する)
+type SamplerType string
+
+const (
+	// SamplerAlwaysOn は全てのスパンを記録する (未指定時のデフォルト)
+	SamplerAlwaysOn SamplerType = "always_on"
+	// SamplerAlwaysOff はスパンを一切記録しない
+	SamplerAlwaysOff SamplerType = "always_off"
+	// SamplerTraceIDRatio は TraceID に基づき Ratio の割合だけ記録する
+	SamplerTraceIDRatio SamplerType = "traceidratio"
+	// SamplerParentBasedAlwaysOn は親スパンの判定に従い、ルートスパンは全て記録する
+	SamplerParentBasedAlwaysOn SamplerType = "parentbased_always_on"
+	// SamplerParentBasedAlwaysOff は親スパンの判定に従い、ルートスパンは記録しない
+	SamplerParentBasedAlwaysOff SamplerType = "parentbased_always_off"
+	// SamplerParentBasedTraceIDRatio は親スパンの判定に従い、ルートスパンは Ratio の割合だけ記録する
+	SamplerParentBasedTraceIDRatio SamplerType = "parentbased_traceidratio"
+	// SamplerRules は Rules に一致したルールの割合で記録し、どのルールにも一致しない場合は Ratio の割合で記録する
+	SamplerRules SamplerType = "rules"
+	// SamplerParentBasedRules は親スパンの判定に従い、ルートスパンは SamplerRules で判定する
+	SamplerParentBasedRules SamplerType = "parentbased_rules"
+)
+
+// SamplerConfig はサンプリング戦略の設定
+type SamplerConfig struct {
+	// Type はサンプラーの種類。空文字の場合は SamplerAlwaysOn として扱う
+	Type SamplerType
+
+	// Ratio は traceidratio 系のサンプラーで記録する割合 (0〜1)。rules 系ではどのルールにも一致しない場合の割合。
+	// nil (未指定) の場合は1 (全て記録する)。0 の場合は (親スパンの判定に従うスパン以外は) 記録しない
+	Ratio *float64
+
+	// Rules は rules 系のサンプラーで使用するルール。先頭から順に評価し、最初に一致したルールを適用する
+	Rules []SamplingRule
+}
+
+// newSampler は SamplerConfig に応じた sdktrace.Sampler を生成する
+func newSampler(cfg SamplerConfig) (sdktrace.Sampler, error) {
+	switch cfg.Type {
+	case "", SamplerAlwaysOn:
+		return sdktrace.AlwaysSample(), nil
+	case SamplerAlwaysOff:
+		return sdktrace.NeverSample(), nil
+	case SamplerTraceIDRatio:
+		if err := validateRatio(cfg.ratio()); err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(cfg.ratio()), nil
+	case SamplerParentBasedAlwaysOn:
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case SamplerParentBasedAlwaysOff:
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case SamplerParentBasedTraceIDRatio:
+		if err := validateRatio(cfg.ratio()); err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.ratio())), nil
+	case SamplerRules:
+		return newRuleSampler(cfg.Rules, cfg.ratio())
+	case SamplerParentBasedRules:
+		root, err := newRuleSampler(cfg.Rules, cfg.ratio())
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(root), nil
+	default:
+		return nil, fmt.Errorf("otel: unsupported sampler %q", cfg.Type)
+	}
+}
+
+// ratio は Ratio を返す。未指定 (nil) の場合は1
+func (c SamplerConfig) ratio() float64 {
+	if c.Ratio == nil {
+		return 1
+	}
+	return *c.Ratio
+}
+
+// validateRatio はサンプリング割合が 0〜1 の範囲にあるかを検証する
+func validateRatio(ratio float64) error {
+	if ratio < 0 || ratio > 1 {
+		return fmt.Errorf("otel: sampler ratio must be in [0, 1], got %v", ratio)
+	}
+	return nil
+}
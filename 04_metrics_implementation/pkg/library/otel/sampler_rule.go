@@ -0,0 +1,165 @@
+package otel
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// SamplingRule は HTTP ルートまたはスパン名ごとのサンプリング割合
+//
+// 例: POST /articles は全件、GET /articles/{id} は5%だけ記録する
+//
+//	[]SamplingRule{
+//	    {Route: "POST /articles", Ratio: 1},
+//	    {Route: "GET /articles/{id}", Ratio: 0.05},
+//	}
+type SamplingRule struct {
+	// Route は "METHOD /path" 形式のルート (http.ServeMux のパターンと同じ書式)。メソッドは省略可。
+	// パスの {id} は任意の1セグメント、末尾の {rest...} は残り全てのセグメントに一致する
+	Route string
+
+	// SpanName はスパン名の完全一致 (例: "ArticleUsecase.Create")。Route と同時に指定した場合は両方に一致する必要がある
+	SpanName string
+
+	// Ratio は一致したトレースを記録する割合 (0〜1)
+	Ratio float64
+}
+
+// HTTP サーバースパンの開始時に otelhttp が設定する属性のキー
+//
+// NOTE: otelhttp.NewHandler は ServeMux でルーティングされる前にスパンを開始するため、サンプリング時点では http.route がまだ設定されていない。
+// そのため http.route が無い場合は http.request.method と url.path を Route のパターンと照合する。
+const (
+	attrHTTPRoute         = attribute.Key("http.route")
+	attrHTTPRequestMethod = attribute.Key("http.request.method")
+	attrURLPath           = attribute.Key("url.path")
+)
+
+// ruleSampler はルールごとに異なる割合で TraceIDRatioBased サンプリングを行う sdktrace.Sampler
+type ruleSampler struct {
+	rules    []compiledRule
+	fallback sdktrace.Sampler
+}
+
+// compiledRule は Route を事前に分解した SamplingRule
+type compiledRule struct {
+	method   string
+	segments []string
+	route    string
+	spanName string
+	sampler  sdktrace.Sampler
+}
+
+// newRuleSampler は rules と、どのルールにも一致しない場合の割合 fallbackRatio から ruleSampler を生成する
+func newRuleSampler(rules []SamplingRule, fallbackRatio float64) (sdktrace.Sampler, error) {
+	if err := validateRatio(fallbackRatio); err != nil {
+		return nil, err
+	}
+
+	s := &ruleSampler{fallback: sdktrace.TraceIDRatioBased(fallbackRatio)}
+	for i, r := range rules {
+		if r.Route == "" && r.SpanName == "" {
+			return nil, fmt.Errorf("otel: sampling rule %d must have Route or SpanName", i)
+		}
+		if err := validateRatio(r.Ratio); err != nil {
+			return nil, fmt.Errorf("otel: sampling rule %d: %w", i, err)
+		}
+
+		c := compiledRule{
+			route:    r.Route,
+			spanName: r.SpanName,
+			sampler:  sdktrace.TraceIDRatioBased(r.Ratio),
+		}
+		if r.Route != "" {
+			path := r.Route
+			if method, rest, found := strings.Cut(r.Route, " "); found {
+				c.method, path = method, strings.TrimSpace(rest)
+			}
+			c.segments = strings.Split(strings.Trim(path, "/"), "/")
+		}
+		s.rules = append(s.rules, c)
+	}
+	return s, nil
+}
+
+// ShouldSample は最初に一致したルールのサンプラーに判定を委譲する
+func (s *ruleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	var route, method, path string
+	for _, kv := range p.Attributes {
+		switch kv.Key {
+		case attrHTTPRoute:
+			route = kv.Value.AsString()
+		case attrHTTPRequestMethod:
+			method = kv.Value.AsString()
+		case attrURLPath:
+			path = kv.Value.AsString()
+		}
+	}
+
+	for _, r := range s.rules {
+		if r.spanName != "" && r.spanName != p.Name {
+			continue
+		}
+		if r.segments != nil && !r.matchRoute(route, method, path) {
+			continue
+		}
+		return r.sampler.ShouldSample(p)
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+// Description はサンプラーの説明を返す
+func (s *ruleSampler) Description() string {
+	descs := make([]string, 0, len(s.rules))
+	for _, r := range s.rules {
+		key := strings.TrimSpace(r.route + " " + r.spanName)
+		descs = append(descs, fmt.Sprintf("%s=%s", key, r.sampler.Description()))
+	}
+	return fmt.Sprintf("RuleBased{%s,fallback=%s}", strings.Join(descs, ","), s.fallback.Description())
+}
+
+// matchRoute は http.route、または HTTP メソッドと URL パスがルールの Route に一致するかを判定する
+func (r compiledRule) matchRoute(route, method, path string) bool {
+	if route != "" {
+		// NOTE: http.route にはメソッドが含まれないため、パス部分のみで比較する
+		if r.method != "" && method != "" && r.method != method {
+			return false
+		}
+		_, routePath, found := strings.Cut(route, " ")
+		if !found {
+			routePath = route
+		}
+		return strings.Trim(routePath, "/") == strings.Join(r.segments, "/")
+	}
+
+	if path == "" {
+		return false
+	}
+	if r.method != "" && r.method != method {
+		return false
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, want := range r.segments {
+		if strings.HasPrefix(want, "{") && strings.HasSuffix(want, "...}") {
+			return true
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if strings.HasPrefix(want, "{") && strings.HasSuffix(want, "}") {
+			if segments[i] == "" {
+				return false
+			}
+			continue
+		}
+		if want != segments[i] {
+			return false
+		}
+	}
+	return len(segments) == len(r.segments)
+}
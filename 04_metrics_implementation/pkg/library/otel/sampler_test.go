@@ -0,0 +1,242 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NOTE: TraceIDRatioBased は TraceID の下位8バイトで判定するため、
+// 下位8バイトが0の TraceID は割合が0より大きければ必ず記録され、0xff の TraceID は割合が1未満であれば必ず記録されない
+var (
+	lowTraceID  = trace.TraceID{0x01, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	highTraceID = trace.TraceID{0x01, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+// ratioPtr は SamplerConfig.Ratio / TailSamplingConfig.Ratio に指定する割合のポインタを返す
+func ratioPtr(v float64) *float64 { return &v }
+
+// sampleRoot はルートスパンとしてサンプリングの判定を行い、記録されるかを返す
+func sampleRoot(s sdktrace.Sampler, traceID trace.TraceID, name string, attrs ...attribute.KeyValue) bool {
+	res := s.ShouldSample(sdktrace.SamplingParameters{
+		ParentContext: context.Background(),
+		TraceID:       traceID,
+		Name:          name,
+		Kind:          trace.SpanKindServer,
+		Attributes:    attrs,
+	})
+	return res.Decision == sdktrace.RecordAndSample
+}
+
+func TestRuleSamplerMatching(t *testing.T) {
+	sampler, err := newSampler(SamplerConfig{
+		Type:  SamplerRules,
+		Ratio: ratioPtr(0.5),
+		Rules: []SamplingRule{
+			{Route: "GET /healthz", Ratio: 0},
+			{Route: "POST /articles", Ratio: 1},
+			{Route: "GET /articles/{id}", Ratio: 0},
+			{Route: "/static/{rest...}", Ratio: 0},
+			{SpanName: "ArticleUsecase.Create", Ratio: 0},
+			{Route: "/reports", SpanName: "report", Ratio: 0},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		traceID trace.TraceID
+		span    string
+		attrs   []attribute.KeyValue
+		want    bool
+	}{
+		{
+			name:    "method and path match a literal route",
+			traceID: lowTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("GET"), attrURLPath.String("/healthz")},
+			want:    false,
+		},
+		{
+			name:    "different method falls back",
+			traceID: lowTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("POST"), attrURLPath.String("/healthz")},
+			want:    true,
+		},
+		{
+			name:    "matching route with ratio 1 records a high trace id",
+			traceID: highTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("POST"), attrURLPath.String("/articles/")},
+			want:    true,
+		},
+		{
+			name:    "wildcard segment matches",
+			traceID: lowTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("GET"), attrURLPath.String("/articles/article-123")},
+			want:    false,
+		},
+		{
+			name:    "wildcard segment does not match extra segments",
+			traceID: lowTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("GET"), attrURLPath.String("/articles/article-123/comments")},
+			want:    true,
+		},
+		{
+			name:    "rest wildcard matches any depth and any method",
+			traceID: lowTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("HEAD"), attrURLPath.String("/static/css/app.css")},
+			want:    false,
+		},
+		{
+			name:    "http.route takes precedence over url.path",
+			traceID: lowTraceID,
+			attrs: []attribute.KeyValue{
+				attrHTTPRoute.String("/articles/{id}"),
+				attrHTTPRequestMethod.String("GET"),
+				attrURLPath.String("/unrelated"),
+			},
+			want: false,
+		},
+		{
+			name:    "span name rule",
+			traceID: lowTraceID,
+			span:    "ArticleUsecase.Create",
+			want:    false,
+		},
+		{
+			name:    "route and span name must both match",
+			traceID: lowTraceID,
+			span:    "other",
+			attrs:   []attribute.KeyValue{attrURLPath.String("/reports")},
+			want:    true,
+		},
+		{
+			name:    "fallback ratio records a low trace id",
+			traceID: lowTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("GET"), attrURLPath.String("/unknown")},
+			want:    true,
+		},
+		{
+			name:    "fallback ratio drops a high trace id",
+			traceID: highTraceID,
+			attrs:   []attribute.KeyValue{attrHTTPRequestMethod.String("GET"), attrURLPath.String("/unknown")},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampleRoot(sampler, tt.traceID, tt.span, tt.attrs...); got != tt.want {
+				t.Errorf("sampled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSamplerRatioDefaultsToOne(t *testing.T) {
+	for _, typ := range []SamplerType{SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio, SamplerRules, SamplerParentBasedRules} {
+		t.Run(string(typ), func(t *testing.T) {
+			sampler, err := newSampler(SamplerConfig{
+				Type:  typ,
+				Rules: []SamplingRule{{Route: "GET /healthz", Ratio: 0}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sampleRoot(sampler, highTraceID, "span", attrURLPath.String("/articles")) {
+				t.Error("unset Ratio dropped a trace, want every trace recorded")
+			}
+		})
+	}
+}
+
+func TestSamplerZeroRatioDropsRoots(t *testing.T) {
+	// NOTE: lowTraceID は割合が0より大きければ必ず記録されるため、記録されなければ割合0が適用されている
+	for _, typ := range []SamplerType{SamplerTraceIDRatio, SamplerParentBasedTraceIDRatio, SamplerRules, SamplerParentBasedRules} {
+		t.Run(string(typ), func(t *testing.T) {
+			sampler, err := newSampler(SamplerConfig{
+				Type:  typ,
+				Ratio: ratioPtr(0),
+				Rules: []SamplingRule{{Route: "POST /articles", Ratio: 1}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sampleRoot(sampler, lowTraceID, "span", attrURLPath.String("/articles")) {
+				t.Error("Ratio 0 recorded a trace, want none recorded")
+			}
+		})
+	}
+}
+
+func TestParentBasedSamplers(t *testing.T) {
+	rules := []SamplingRule{{Route: "/articles", Ratio: 0}}
+	parent := func(sampled, remote bool) context.Context {
+		flags := trace.TraceFlags(0)
+		if sampled {
+			flags = trace.FlagsSampled
+		}
+		sc := trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    lowTraceID,
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+			Remote:     remote,
+		})
+		return trace.ContextWithSpanContext(context.Background(), sc)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    SamplerConfig
+		parent context.Context
+		want   bool
+	}{
+		{name: "rules: root follows the rule", cfg: SamplerConfig{Type: SamplerParentBasedRules, Rules: rules}, parent: context.Background(), want: false},
+		{name: "rules: sampled remote parent wins over the rule", cfg: SamplerConfig{Type: SamplerParentBasedRules, Rules: rules}, parent: parent(true, true), want: true},
+		{name: "rules: unsampled remote parent is dropped", cfg: SamplerConfig{Type: SamplerParentBasedRules, Ratio: ratioPtr(1)}, parent: parent(false, true), want: false},
+		{name: "rules: sampled local parent is recorded", cfg: SamplerConfig{Type: SamplerParentBasedRules, Rules: rules}, parent: parent(true, false), want: true},
+		{name: "non parent based rules ignore the parent", cfg: SamplerConfig{Type: SamplerRules, Rules: rules}, parent: parent(true, true), want: false},
+		{name: "always_off root", cfg: SamplerConfig{Type: SamplerParentBasedAlwaysOff}, parent: context.Background(), want: false},
+		{name: "always_off with sampled parent", cfg: SamplerConfig{Type: SamplerParentBasedAlwaysOff}, parent: parent(true, true), want: true},
+		{name: "traceidratio with unsampled parent", cfg: SamplerConfig{Type: SamplerParentBasedTraceIDRatio, Ratio: ratioPtr(1)}, parent: parent(false, true), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sampler, err := newSampler(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res := sampler.ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: tt.parent,
+				TraceID:       lowTraceID,
+				Name:          "span",
+				Attributes:    []attribute.KeyValue{attrURLPath.String("/articles")},
+			})
+			if got := res.Decision == sdktrace.RecordAndSample; got != tt.want {
+				t.Errorf("sampled = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSamplerValidation(t *testing.T) {
+	tests := map[string]SamplerConfig{
+		"unknown type":         {Type: "sometimes"},
+		"ratio above one":      {Type: SamplerTraceIDRatio, Ratio: ratioPtr(1.5)},
+		"negative ratio":       {Type: SamplerParentBasedTraceIDRatio, Ratio: ratioPtr(-0.1)},
+		"negative rules ratio": {Type: SamplerRules, Ratio: ratioPtr(-1)},
+		"empty rule":           {Type: SamplerRules, Rules: []SamplingRule{{Ratio: 1}}},
+		"rule ratio above one": {Type: SamplerParentBasedRules, Rules: []SamplingRule{{SpanName: "x", Ratio: 2}}},
+	}
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newSampler(cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,352 @@
+package otel
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// TailSamplingConfig はテールサンプリングの設定
+//
+// ヘッドサンプリング (Sampler) はスパン開始時に判定するため「エラーになったか」「遅かったか」を判定材料にできない。
+// テールサンプリングはトレースの全スパンをメモリに溜めておき、ルートスパンの終了時にトレース全体を見て記録するかを判定する。
+// NOTE: 判定対象のスパンを取りこぼさないよう、ヘッドサンプリングは always_on (デフォルト) と組み合わせて使用する。
+type TailSamplingConfig struct {
+	// Enabled が true の場合にテールサンプリングを有効化する
+	Enabled bool
+
+	// LatencyThreshold 以上かかったトレースは常に記録する。0 の場合はレイテンシによる判定を行わない
+	LatencyThreshold time.Duration
+
+	// Ratio はエラーでも低速でもないトレースを記録する割合 (0〜1)。nil (未指定) の場合は1 (全て記録する)。
+	// 0 の場合はエラーまたは低速のトレースのみを記録する
+	Ratio *float64
+
+	// MaxTraceAge は最初のスパンを受け取ってからルートスパンの終了を待つ最大時間。0 の場合は30秒。
+	// 超過したトレースはその時点のスパンで判定して破棄する
+	MaxTraceAge time.Duration
+
+	// MaxBufferedSpans はメモリに保持するスパン数の上限。0 の場合は10000。
+	// 超過した場合は最も古いトレースから判定して破棄する。保持する判定結果の数も同じ値を上限とし、古いものから破棄する
+	MaxBufferedSpans int
+}
+
+// ratio は判定材料の無いトレースを記録する割合を返す (未指定の場合は1)
+func (c TailSamplingConfig) ratio() float64 {
+	if c.Ratio == nil {
+		return 1
+	}
+	return *c.Ratio
+}
+
+const (
+	defaultTailSamplingMaxTraceAge      = 30 * time.Second
+	defaultTailSamplingMaxBufferedSpans = 10000
+)
+
+// TailSamplingProcessor はトレース単位でスパンをバッファし、ルートスパン終了時に next へ渡すかを判定する sdktrace.SpanProcessor
+type TailSamplingProcessor struct {
+	next     sdktrace.SpanProcessor
+	cfg      TailSamplingConfig
+	fallback sdktrace.Sampler
+
+	mu       sync.Mutex
+	traces   map[trace.TraceID]*bufferedTrace
+	order    *list.List // NOTE: 最初のスパンを受け取った順 (古い順) に TraceID を保持する
+	buffered int
+	// decided はルートスパン終了後に遅れて届いたスパンを同じ判定で扱うため、判定結果を MaxTraceAge の間だけ保持する
+	decided      map[trace.TraceID]decision
+	decidedOrder *list.List // NOTE: 判定した順 (古い順) に TraceID を保持する
+
+	keptCounter    metric.Int64Counter
+	droppedCounter metric.Int64Counter
+	registration   metric.Registration
+
+	stopCh       chan struct{}
+	doneCh       chan struct{}
+	shutdownOnce sync.Once
+}
+
+// bufferedTrace は判定待ちのトレース
+type bufferedTrace struct {
+	spans     []sdktrace.ReadOnlySpan
+	firstSeen time.Time
+	hasError  bool
+	elem      *list.Element
+}
+
+// decision はトレースの判定結果
+type decision struct {
+	keep bool
+	at   time.Time
+	elem *list.Element
+}
+
+var _ sdktrace.SpanProcessor = (*TailSamplingProcessor)(nil)
+
+// NewTailSamplingProcessor は TailSamplingProcessor を生成する
+//
+// 記録すると判定したトレースのスパンは next (通常は BatchSpanProcessor) に渡される。
+// 判定結果は mp 上の以下のメトリクスとして記録される:
+//   - otel.tail_sampling.traces.kept    : 記録したトレース数 (reason: error / latency / ratio)
+//   - otel.tail_sampling.traces.dropped : 破棄したトレース数 (reason: ratio)
+//   - otel.tail_sampling.spans.buffered : 判定待ちでメモリに保持しているスパン数
+//
+// どちらも判定がルートスパン終了前 (MaxTraceAge / MaxBufferedSpans 超過、Shutdown、ForceFlush) に行われた場合は incomplete=true が付与される。
+func NewTailSamplingProcessor(next sdktrace.SpanProcessor, cfg TailSamplingConfig, mp metric.MeterProvider) (*TailSamplingProcessor, error) {
+	if err := validateRatio(cfg.ratio()); err != nil {
+		return nil, fmt.Errorf("otel: tail sampling: %w", err)
+	}
+	if cfg.MaxTraceAge <= 0 {
+		cfg.MaxTraceAge = defaultTailSamplingMaxTraceAge
+	}
+	if cfg.MaxBufferedSpans <= 0 {
+		cfg.MaxBufferedSpans = defaultTailSamplingMaxBufferedSpans
+	}
+
+	p := &TailSamplingProcessor{
+		next:         next,
+		cfg:          cfg,
+		fallback:     sdktrace.TraceIDRatioBased(cfg.ratio()),
+		traces:       make(map[trace.TraceID]*bufferedTrace),
+		order:        list.New(),
+		decided:      make(map[trace.TraceID]decision),
+		decidedOrder: list.New(),
+		stopCh:       make(chan struct{}),
+		doneCh:       make(chan struct{}),
+	}
+
+	meter := mp.Meter(instrumentationName)
+	var err error
+	p.keptCounter, err = meter.Int64Counter(
+		"otel.tail_sampling.traces.kept",
+		metric.WithDescription("テールサンプリングで記録したトレース数"),
+		metric.WithUnit("{trace}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.droppedCounter, err = meter.Int64Counter(
+		"otel.tail_sampling.traces.dropped",
+		metric.WithDescription("テールサンプリングで破棄したトレース数"),
+		metric.WithUnit("{trace}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	bufferedGauge, err := meter.Int64ObservableGauge(
+		"otel.tail_sampling.spans.buffered",
+		metric.WithDescription("テールサンプリングの判定待ちでメモリに保持しているスパン数"),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.registration, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		o.ObserveInt64(bufferedGauge, int64(p.buffered))
+		return nil
+	}, bufferedGauge)
+	if err != nil {
+		return nil, err
+	}
+
+	go p.sweep()
+	return p, nil
+}
+
+// OnStart は next に委譲する (判定はスパン終了時に行う)
+func (p *TailSamplingProcessor) OnStart(parent context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(parent, s)
+}
+
+// OnEnd は終了したスパンをバッファし、ルートスパンであればトレース全体を判定する
+func (p *TailSamplingProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		return
+	}
+	traceID := s.SpanContext().TraceID()
+
+	p.mu.Lock()
+	// NOTE: 判定済みのトレースに遅れて届いたスパンは、同じ判定結果に従ってそのまま渡す (または破棄する)
+	if d, ok := p.decided[traceID]; ok {
+		p.mu.Unlock()
+		if d.keep {
+			p.next.OnEnd(s)
+		}
+		return
+	}
+
+	t, ok := p.traces[traceID]
+	if !ok {
+		t = &bufferedTrace{firstSeen: time.Now()}
+		t.elem = p.order.PushBack(traceID)
+		p.traces[traceID] = t
+	}
+	t.spans = append(t.spans, s)
+	t.hasError = t.hasError || s.Status().Code == codes.Error
+	p.buffered++
+
+	var flush []func()
+	// NOTE: 親が無い、または親がリモート (他サービス) のスパンをこのプロセスにおけるルートスパンとみなす
+	if parent := s.Parent(); !parent.IsValid() || parent.IsRemote() {
+		flush = append(flush, p.decideLocked(traceID, t, s.EndTime().Sub(s.StartTime()), false))
+	}
+	for p.buffered > p.cfg.MaxBufferedSpans && p.order.Len() > 0 {
+		oldest := p.order.Front().Value.(trace.TraceID)
+		flush = append(flush, p.decideLocked(oldest, p.traces[oldest], 0, true))
+	}
+	p.mu.Unlock()
+
+	for _, f := range flush {
+		f()
+	}
+}
+
+// decideLocked はトレースを判定してバッファから取り除き、next へ渡す処理を返す (p.mu を保持した状態で呼ぶ)
+//
+// NOTE: next.OnEnd はエクスポーターのキューに積むため、ロックの外で実行できるよう関数として返す。
+func (p *TailSamplingProcessor) decideLocked(traceID trace.TraceID, t *bufferedTrace, rootDuration time.Duration, incomplete bool) func() {
+	delete(p.traces, traceID)
+	p.order.Remove(t.elem)
+	p.buffered -= len(t.spans)
+
+	var keep bool
+	var reason string
+	switch {
+	case t.hasError:
+		keep, reason = true, "error"
+	case p.cfg.LatencyThreshold > 0 && rootDuration >= p.cfg.LatencyThreshold:
+		keep, reason = true, "latency"
+	default:
+		result := p.fallback.ShouldSample(sdktrace.SamplingParameters{
+			ParentContext: context.Background(),
+			TraceID:       traceID,
+		})
+		keep, reason = result.Decision == sdktrace.RecordAndSample, "ratio"
+	}
+	p.decided[traceID] = decision{keep: keep, at: time.Now(), elem: p.decidedOrder.PushBack(traceID)}
+	// NOTE: ルートスパンが短時間に大量に終了した場合もメモリを使い切らないよう、判定結果の数も MaxBufferedSpans で制限する
+	for p.decidedOrder.Len() > p.cfg.MaxBufferedSpans {
+		p.forgetLocked(p.decidedOrder.Front().Value.(trace.TraceID))
+	}
+
+	spans := t.spans
+	attrs := metric.WithAttributes(
+		attribute.String("reason", reason),
+		attribute.Bool("incomplete", incomplete),
+	)
+	return func() {
+		ctx := context.Background()
+		if !keep {
+			p.droppedCounter.Add(ctx, 1, attrs)
+			return
+		}
+		p.keptCounter.Add(ctx, 1, attrs)
+		for _, s := range spans {
+			p.next.OnEnd(s)
+		}
+	}
+}
+
+// forgetLocked は判定結果を取り除く (p.mu を保持した状態で呼ぶ)
+func (p *TailSamplingProcessor) forgetLocked(traceID trace.TraceID) {
+	d := p.decided[traceID]
+	p.decidedOrder.Remove(d.elem)
+	delete(p.decided, traceID)
+}
+
+// decideAllLocked はバッファ中の全トレースを判定し、next へ渡す処理を返す (p.mu を保持した状態で呼ぶ)
+func (p *TailSamplingProcessor) decideAllLocked() []func() {
+	var flush []func()
+	for p.order.Len() > 0 {
+		oldest := p.order.Front().Value.(trace.TraceID)
+		flush = append(flush, p.decideLocked(oldest, p.traces[oldest], 0, true))
+	}
+	return flush
+}
+
+// sweep は MaxTraceAge を超えたトレースと判定結果を定期的に取り除く
+func (p *TailSamplingProcessor) sweep() {
+	defer close(p.doneCh)
+
+	interval := p.cfg.MaxTraceAge / 4
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stopCh:
+			return
+		case now := <-ticker.C:
+			var flush []func()
+			p.mu.Lock()
+			for p.order.Len() > 0 {
+				oldest := p.order.Front().Value.(trace.TraceID)
+				t := p.traces[oldest]
+				if now.Sub(t.firstSeen) < p.cfg.MaxTraceAge {
+					break
+				}
+				flush = append(flush, p.decideLocked(oldest, t, 0, true))
+			}
+			for p.decidedOrder.Len() > 0 {
+				oldest := p.decidedOrder.Front().Value.(trace.TraceID)
+				if now.Sub(p.decided[oldest].at) < p.cfg.MaxTraceAge {
+					break
+				}
+				p.forgetLocked(oldest)
+			}
+			p.mu.Unlock()
+
+			for _, f := range flush {
+				f()
+			}
+		}
+	}
+}
+
+// Shutdown はバッファ中の全トレースを判定してから next をシャットダウンする
+func (p *TailSamplingProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	p.shutdownOnce.Do(func() {
+		close(p.stopCh)
+		<-p.doneCh
+
+		p.mu.Lock()
+		flush := p.decideAllLocked()
+		p.mu.Unlock()
+		for _, f := range flush {
+			f()
+		}
+
+		_ = p.registration.Unregister()
+		err = p.next.Shutdown(ctx)
+	})
+	return err
+}
+
+// ForceFlush はバッファ中の全トレースをその時点のスパンで判定して next に渡してから、next をフラッシュする
+//
+// NOTE: 判定後に届いたスパンは decided の判定結果に従って扱われるため、トレースが途中で分断されることはない
+func (p *TailSamplingProcessor) ForceFlush(ctx context.Context) error {
+	p.mu.Lock()
+	flush := p.decideAllLocked()
+	p.mu.Unlock()
+	for _, f := range flush {
+		f()
+	}
+	return p.next.ForceFlush(ctx)
+}
@@ -0,0 +1,155 @@
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTailSamplingTest は SpanRecorder を next とする TailSamplingProcessor と、それを登録した Tracer を返す
+func newTailSamplingTest(t *testing.T, cfg TailSamplingConfig) (*TailSamplingProcessor, *tracetest.SpanRecorder, trace.Tracer) {
+	t.Helper()
+	cfg.Enabled = true
+	recorder := tracetest.NewSpanRecorder()
+	p, err := NewTailSamplingProcessor(recorder, cfg, noop.NewMeterProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return p, recorder, tp.Tracer("test")
+}
+
+func TestTailSamplingForwardsOnStart(t *testing.T) {
+	_, recorder, tracer := newTailSamplingTest(t, TailSamplingConfig{})
+
+	_, span := tracer.Start(context.Background(), "root")
+	if got := len(recorder.Started()); got != 1 {
+		t.Fatalf("next received %d started spans before End, want 1", got)
+	}
+	span.End()
+}
+
+func TestTailSamplingDecisions(t *testing.T) {
+	// NOTE: 1e-9 の割合では下位8バイトが 0xff の TraceID は記録されない (sampler_test.go 参照)
+	tests := []struct {
+		name  string
+		cfg   TailSamplingConfig
+		error bool
+		want  int
+	}{
+		{name: "unset ratio keeps a healthy trace", cfg: TailSamplingConfig{}, want: 2},
+		{name: "low ratio drops a healthy trace", cfg: TailSamplingConfig{Ratio: ratioPtr(1e-9)}, want: 0},
+		{name: "error is always kept", cfg: TailSamplingConfig{Ratio: ratioPtr(1e-9)}, error: true, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, recorder, tracer := newTailSamplingTest(t, tt.cfg)
+
+			ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    highTraceID,
+				SpanID:     trace.SpanID{1},
+				TraceFlags: trace.FlagsSampled,
+				Remote:     true,
+			}))
+			ctx, root := tracer.Start(ctx, "root")
+			_, child := tracer.Start(ctx, "child")
+			if tt.error {
+				child.SetStatus(codes.Error, "failed")
+			}
+			child.End()
+			if got := len(recorder.Ended()); got != 0 {
+				t.Fatalf("next received %d spans before the root ended, want 0", got)
+			}
+			root.End()
+
+			if got := len(recorder.Ended()); got != tt.want {
+				t.Errorf("next received %d spans, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTailSamplingForceFlushReleasesPendingTraces(t *testing.T) {
+	p, recorder, tracer := newTailSamplingTest(t, TailSamplingConfig{})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_, child := tracer.Start(ctx, "child")
+	child.End()
+
+	if err := p.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(recorder.Ended()); got != 1 {
+		t.Fatalf("next received %d spans after ForceFlush, want the pending child", got)
+	}
+
+	// NOTE: ForceFlush 後に終了したルートスパンは同じ判定結果に従って渡される
+	root.End()
+	if got := len(recorder.Ended()); got != 2 {
+		t.Errorf("next received %d spans after the root ended, want 2", got)
+	}
+}
+
+func TestTailSamplingCapsDecisions(t *testing.T) {
+	p, _, tracer := newTailSamplingTest(t, TailSamplingConfig{MaxBufferedSpans: 2})
+
+	for range 5 {
+		_, span := tracer.Start(context.Background(), "root")
+		span.End()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.decided) != 2 || p.decidedOrder.Len() != 2 {
+		t.Errorf("decided = %d (order %d), want 2", len(p.decided), p.decidedOrder.Len())
+	}
+}
+
+func TestTailSamplingZeroRatioKeepsOnlyErrorAndSlowTraces(t *testing.T) {
+	_, recorder, tracer := newTailSamplingTest(t, TailSamplingConfig{
+		Ratio:            ratioPtr(0),
+		LatencyThreshold: 100 * time.Millisecond,
+	})
+	start := time.Now()
+
+	// NOTE: lowTraceID の下位8バイトは0のため、割合が0より大きければ必ず記録される
+	traces := []struct {
+		name     string
+		traceID  trace.TraceID
+		error    bool
+		duration time.Duration
+	}{
+		{name: "healthy", traceID: lowTraceID, duration: time.Millisecond},
+		{name: "error", traceID: trace.TraceID{0x02}, error: true, duration: time.Millisecond},
+		{name: "slow", traceID: trace.TraceID{0x03}, duration: 200 * time.Millisecond},
+	}
+	for _, tt := range traces {
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    tt.traceID,
+			SpanID:     trace.SpanID{1},
+			TraceFlags: trace.FlagsSampled,
+			Remote:     true,
+		}))
+		_, root := tracer.Start(ctx, tt.name, trace.WithTimestamp(start))
+		if tt.error {
+			root.SetStatus(codes.Error, "failed")
+		}
+		root.End(trace.WithTimestamp(start.Add(tt.duration)))
+	}
+
+	var got []string
+	for _, s := range recorder.Ended() {
+		got = append(got, s.Name())
+	}
+	if len(got) != 2 || got[0] != "error" || got[1] != "slow" {
+		t.Errorf("exported %v, want [error slow]", got)
+	}
+}
@@ -0,0 +1,64 @@
+package otel
+
+import (
+	"log/slog"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Telemetry はアプリケーションの各層 (repository / usecase / handler 等) に渡す計装用の依存関係
+//
+// otel.Tracer() / otel.Meter() / slog.Default() のグローバルを参照する代わりにコンストラクタで受け取ることで、
+// テストでは独自のインメモリの Provider を持つ複数のインスタンスを並行して動かせるようになる。
+//
+// NOTE: 未設定のフィールドはグローバル (otel.GetTracerProvider() / otel.GetMeterProvider() / otel.GetTextMapPropagator() / slog.Default()) を使用する。
+// otelhttp 等の計装ライブラリに nil の Provider を渡した場合と同じ挙動となる。
+type Telemetry struct {
+	TracerProvider trace.TracerProvider
+	MeterProvider  metric.MeterProvider
+	Propagators    propagation.TextMapPropagator
+	Logger         *slog.Logger
+}
+
+// Telemetry は p の TracerProvider / MeterProvider / Propagators と logger を持つ Telemetry を返す
+//
+// NOTE: Config.Baggage.MetricKeys が指定されている場合、MeterProvider は記録時に Baggage のメンバーを属性として追加するようラップされる。
+func (p *Provider) Telemetry(logger *slog.Logger) Telemetry {
+	var mp metric.MeterProvider = p.MeterProvider
+	if p.meterProvider != nil {
+		mp = p.meterProvider
+	}
+	return Telemetry{
+		TracerProvider: p.TracerProvider,
+		MeterProvider:  mp,
+		Propagators:    p.Propagators,
+		Logger:         logger,
+	}
+}
+
+// Tracer は name を計装スコープとする Tracer を返す
+func (t Telemetry) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	if t.TracerProvider == nil {
+		return otel.Tracer(name, opts...)
+	}
+	return t.TracerProvider.Tracer(name, opts...)
+}
+
+// Meter は name を計装スコープとする Meter を返す
+func (t Telemetry) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	if t.MeterProvider == nil {
+		return otel.Meter(name, opts...)
+	}
+	return t.MeterProvider.Meter(name, opts...)
+}
+
+// Log は出力先の Logger を返す
+func (t Telemetry) Log() *slog.Logger {
+	if t.Logger == nil {
+		return slog.Default()
+	}
+	return t.Logger
+}
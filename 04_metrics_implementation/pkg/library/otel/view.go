@@ -0,0 +1,144 @@
+package otel
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// AggregationType はメトリクスの集約方法
+type AggregationType string
+
+const (
+	// AggregationDefault は計器の種類に応じた SDK デフォルトの集約 (Counter は Sum、Histogram は ExplicitBucketHistogram 等)
+	AggregationDefault AggregationType = "default"
+	// AggregationDrop は計器を破棄し、エクスポートしない
+	AggregationDrop AggregationType = "drop"
+	// AggregationSum は合計値に集約する
+	AggregationSum AggregationType = "sum"
+	// AggregationLastValue は最後に記録された値に集約する
+	AggregationLastValue AggregationType = "last_value"
+	// AggregationExplicitBucketHistogram は MetricView.Buckets の境界でヒストグラムに集約する
+	AggregationExplicitBucketHistogram AggregationType = "explicit_bucket_histogram"
+)
+
+// MetricView は計器ごとにエクスポート時の名前・集約・属性を上書きするルール
+//
+// 計器を作成するコード (meter.Float64Histogram 等) を変更せずに、デプロイ先ごとにバケット境界の調整や
+// 高カーディナリティ属性の除外、不要な計器の破棄を設定で行うために使用する。
+// 1つの計器に複数のルールが一致した場合は、それぞれのルールで別のメトリクスとして出力される。
+//
+// 例: article.views.total から article_id 属性を除外する
+//
+//	MetricView{Instrument: "article.views.total", AttributeKeys: []string{"category_id"}}
+type MetricView struct {
+	// Instrument は対象の計器名。"*" (任意の文字列) と "?" (任意の1文字) のワイルドカードが使用できる (例: "article.*")
+	Instrument string
+
+	// Meter が指定された場合は、その計装スコープ名 (otel.Meter に渡した名前) の計器のみを対象とする
+	Meter string
+
+	// Rename はエクスポート時のメトリクス名。ワイルドカードで複数の計器に一致する場合は指定できない
+	Rename string
+
+	// Aggregation は集約方法。未指定の場合は計器の設定 (Buckets を指定した場合は explicit_bucket_histogram) に従う
+	Aggregation AggregationType
+
+	// Buckets はヒストグラムのバケット境界 (昇順)。計器側の WithExplicitBucketBoundaries より優先される
+	Buckets []float64
+
+	// AttributeKeys が指定された場合は、これらのキーの属性のみを残し、それ以外の属性を除外する
+	AttributeKeys []string
+}
+
+// newViews は MetricView を sdkmetric.View に変換する
+//
+// exp は Aggregation に base2_exponential_bucket_histogram を指定したルールに適用する指数ヒストグラムの設定。
+func newViews(rules []MetricView, exp ExponentialHistogramConfig) ([]sdkmetric.View, error) {
+	views := make([]sdkmetric.View, 0, len(rules))
+	for _, rule := range rules {
+		view, err := rule.view(exp)
+		if err != nil {
+			return nil, fmt.Errorf("otel: metric view %q: %w", rule.Instrument, err)
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// view は MetricView を sdkmetric.View に変換する
+func (v MetricView) view(exp ExponentialHistogramConfig) (sdkmetric.View, error) {
+	if v.Instrument == "" {
+		return nil, errors.New("instrument name is required")
+	}
+	// NOTE: sdkmetric.NewView もワイルドカードと Name の組み合わせを拒否するが、エラーは otel.Handle に報告されるだけで View が無視されるため、起動時に検出する。
+	if v.Rename != "" && strings.ContainsAny(v.Instrument, "*?") {
+		return nil, errors.New("rename cannot be used with a wildcard instrument name")
+	}
+
+	aggregation, err := v.aggregation(exp)
+	if err != nil {
+		return nil, err
+	}
+	stream := sdkmetric.Stream{
+		Name:        v.Rename,
+		Aggregation: aggregation,
+	}
+	if v.AttributeKeys != nil {
+		keys := make([]attribute.Key, len(v.AttributeKeys))
+		for i, k := range v.AttributeKeys {
+			keys[i] = attribute.Key(k)
+		}
+		stream.AttributeFilter = attribute.NewAllowKeysFilter(keys...)
+	}
+
+	return sdkmetric.NewView(
+		sdkmetric.Instrument{
+			Name:  v.Instrument,
+			Scope: instrumentation.Scope{Name: v.Meter},
+		},
+		stream,
+	), nil
+}
+
+// aggregation は Aggregation と Buckets を sdkmetric.Aggregation に変換する (nil の場合は計器の設定に従う)
+func (v MetricView) aggregation(exp ExponentialHistogramConfig) (sdkmetric.Aggregation, error) {
+	if v.Buckets != nil && v.Aggregation != "" && v.Aggregation != AggregationExplicitBucketHistogram {
+		return nil, fmt.Errorf("buckets cannot be used with aggregation %q", v.Aggregation)
+	}
+	if !slices.IsSorted(v.Buckets) || len(slices.Compact(slices.Clone(v.Buckets))) != len(v.Buckets) {
+		return nil, fmt.Errorf("buckets must be strictly increasing: %v", v.Buckets)
+	}
+
+	switch v.Aggregation {
+	case "":
+		if v.Buckets != nil {
+			return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: v.Buckets}, nil
+		}
+		return nil, nil
+	case AggregationDefault:
+		return sdkmetric.AggregationDefault{}, nil
+	case AggregationDrop:
+		return sdkmetric.AggregationDrop{}, nil
+	case AggregationSum:
+		return sdkmetric.AggregationSum{}, nil
+	case AggregationLastValue:
+		return sdkmetric.AggregationLastValue{}, nil
+	case AggregationExplicitBucketHistogram:
+		// NOTE: Boundaries が空の場合は (-∞, +∞) の1バケットになり分布が失われるため、境界の指定を必須とする
+		if len(v.Buckets) == 0 {
+			return nil, errors.New("explicit_bucket_histogram requires buckets")
+		}
+		return sdkmetric.AggregationExplicitBucketHistogram{Boundaries: v.Buckets}, nil
+	case AggregationBase2ExponentialHistogram:
+		return exp.aggregation()
+	default:
+		return nil, fmt.Errorf("unsupported aggregation %q", v.Aggregation)
+	}
+}
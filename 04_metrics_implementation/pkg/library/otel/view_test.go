@@ -0,0 +1,194 @@
+package otel
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// collectWithViews は rules を適用した MeterProvider で record を実行し、エクスポートされるメトリクスを名前ごとに返す
+func collectWithViews(t *testing.T, rules []MetricView, record func(meter metric.Meter)) map[string]metricdata.Metrics {
+	t.Helper()
+	views, err := newViews(rules, ExponentialHistogramConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(views...))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	record(mp.Meter("article"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m
+		}
+	}
+	return metrics
+}
+
+// addCounter は meter に name の Int64Counter を作成し、attrs を付与して1を加算する
+func addCounter(t *testing.T, meter metric.Meter, name string, attrs ...attribute.KeyValue) {
+	t.Helper()
+	c, err := meter.Int64Counter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(context.Background(), 1, metric.WithAttributes(attrs...))
+}
+
+func TestMetricViewAppliesRules(t *testing.T) {
+	articleAttrs := []attribute.KeyValue{attribute.String("article_id", "article-123"), attribute.String("category_id", "tech")}
+
+	tests := []struct {
+		name   string
+		rules  []MetricView
+		record func(t *testing.T, meter metric.Meter)
+		check  func(t *testing.T, metrics map[string]metricdata.Metrics)
+	}{
+		{
+			name:  "wildcard matches every instrument with the pattern",
+			rules: []MetricView{{Instrument: "article.*.total", Aggregation: AggregationDrop}},
+			record: func(t *testing.T, meter metric.Meter) {
+				addCounter(t, meter, "article.views.total")
+				addCounter(t, meter, "article.likes.total")
+				addCounter(t, meter, "user.logins.total")
+			},
+			check: func(t *testing.T, metrics map[string]metricdata.Metrics) {
+				if got := slices.Sorted(maps.Keys(metrics)); !slices.Equal(got, []string{"user.logins.total"}) {
+					t.Errorf("exported metrics = %v, want only user.logins.total", got)
+				}
+			},
+		},
+		{
+			name:  "rename",
+			rules: []MetricView{{Instrument: "article.views.total", Rename: "article.views"}},
+			record: func(t *testing.T, meter metric.Meter) {
+				addCounter(t, meter, "article.views.total")
+			},
+			check: func(t *testing.T, metrics map[string]metricdata.Metrics) {
+				if _, ok := metrics["article.views"]; !ok {
+					t.Errorf("exported metrics = %v, want article.views", slices.Sorted(maps.Keys(metrics)))
+				}
+				if _, ok := metrics["article.views.total"]; ok {
+					t.Error("the original name is still exported")
+				}
+			},
+		},
+		{
+			name:  "buckets override the histogram boundaries",
+			rules: []MetricView{{Instrument: "article.create.duration", Buckets: []float64{0.1, 0.5, 1}}},
+			record: func(t *testing.T, meter metric.Meter) {
+				h, err := meter.Float64Histogram("article.create.duration", metric.WithExplicitBucketBoundaries(1, 2, 3))
+				if err != nil {
+					t.Fatal(err)
+				}
+				h.Record(context.Background(), 0.25)
+			},
+			check: func(t *testing.T, metrics map[string]metricdata.Metrics) {
+				data, ok := metrics["article.create.duration"].Data.(metricdata.Histogram[float64])
+				if !ok || len(data.DataPoints) != 1 {
+					t.Fatalf("data = %T, want one histogram data point", metrics["article.create.duration"].Data)
+				}
+				dp := data.DataPoints[0]
+				if !slices.Equal(dp.Bounds, []float64{0.1, 0.5, 1}) || !slices.Equal(dp.BucketCounts, []uint64{0, 1, 0, 0}) {
+					t.Errorf("bounds = %v, counts = %v, want the view's boundaries", dp.Bounds, dp.BucketCounts)
+				}
+			},
+		},
+		{
+			name:  "attribute keys keep only the allowlisted attributes",
+			rules: []MetricView{{Instrument: "article.views.total", AttributeKeys: []string{"category_id"}}},
+			record: func(t *testing.T, meter metric.Meter) {
+				addCounter(t, meter, "article.views.total", articleAttrs...)
+				addCounter(t, meter, "user.logins.total", articleAttrs...)
+			},
+			check: func(t *testing.T, metrics map[string]metricdata.Metrics) {
+				want := map[string]attribute.Set{
+					"article.views.total": attribute.NewSet(attribute.String("category_id", "tech")),
+					"user.logins.total":   attribute.NewSet(articleAttrs...),
+				}
+				for name, attrs := range want {
+					data, ok := metrics[name].Data.(metricdata.Sum[int64])
+					if !ok || len(data.DataPoints) != 1 {
+						t.Fatalf("%s data = %T, want one sum data point", name, metrics[name].Data)
+					}
+					if got := data.DataPoints[0].Attributes; !got.Equals(&attrs) {
+						t.Errorf("%s attributes = %v, want %v", name, got.ToSlice(), attrs.ToSlice())
+					}
+				}
+			},
+		},
+		{
+			name:  "drop",
+			rules: []MetricView{{Instrument: "article.views.total", Aggregation: AggregationDrop}},
+			record: func(t *testing.T, meter metric.Meter) {
+				addCounter(t, meter, "article.views.total")
+				addCounter(t, meter, "article.likes.total")
+			},
+			check: func(t *testing.T, metrics map[string]metricdata.Metrics) {
+				if got := slices.Sorted(maps.Keys(metrics)); !slices.Equal(got, []string{"article.likes.total"}) {
+					t.Errorf("exported metrics = %v, want only article.likes.total", got)
+				}
+			},
+		},
+		{
+			name:  "meter limits the rule to the instrumentation scope",
+			rules: []MetricView{{Instrument: "article.views.total", Meter: "other", Aggregation: AggregationDrop}},
+			record: func(t *testing.T, meter metric.Meter) {
+				addCounter(t, meter, "article.views.total")
+			},
+			check: func(t *testing.T, metrics map[string]metricdata.Metrics) {
+				if _, ok := metrics["article.views.total"]; !ok {
+					t.Error("a rule for another meter dropped the instrument")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := collectWithViews(t, tt.rules, func(meter metric.Meter) { tt.record(t, meter) })
+			tt.check(t, metrics)
+		})
+	}
+}
+
+func TestMetricViewRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    MetricView
+		wantErr string
+	}{
+		{name: "missing instrument", rule: MetricView{Rename: "views"}, wantErr: "instrument name is required"},
+		{name: "rename with *", rule: MetricView{Instrument: "article.*", Rename: "article"}, wantErr: "rename cannot be used with a wildcard"},
+		{name: "rename with ?", rule: MetricView{Instrument: "article.view?", Rename: "article"}, wantErr: "rename cannot be used with a wildcard"},
+		{name: "unsorted buckets", rule: MetricView{Instrument: "article.create.duration", Buckets: []float64{1, 0.5}}, wantErr: "strictly increasing"},
+		{name: "duplicate buckets", rule: MetricView{Instrument: "article.create.duration", Buckets: []float64{0.5, 0.5, 1}}, wantErr: "strictly increasing"},
+		{name: "buckets with another aggregation", rule: MetricView{Instrument: "article.create.duration", Aggregation: AggregationSum, Buckets: []float64{1}}, wantErr: `buckets cannot be used with aggregation "sum"`},
+		{name: "explicit histogram without buckets", rule: MetricView{Instrument: "article.create.duration", Aggregation: AggregationExplicitBucketHistogram}, wantErr: "requires buckets"},
+		{name: "unsupported aggregation", rule: MetricView{Instrument: "article.views.total", Aggregation: "median"}, wantErr: `unsupported aggregation "median"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newViews([]MetricView{tt.rule}, ExponentialHistogramConfig{})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if !strings.HasPrefix(err.Error(), "otel: metric view ") || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// NOTE: Zipkin エクスポーター
+// スパンを Zipkin v2 の JSON 形式 (POST /api/v2/spans) で送信する。Zipkin を運用しているチームにトレースを共有するために使用する。
+// SDK 公式の Zipkin エクスポーターは非推奨となったため、OpenTelemetry の仕様 (Zipkin へのマッピング) に従って変換する:
+//   - kind           : SERVER / CLIENT / PRODUCER / CONSUMER (INTERNAL は省略)
+//   - localEndpoint  : リソースの service.name
+//   - remoteEndpoint : CLIENT / PRODUCER のスパンの peer.service / server.address / network.peer.address 等
+//   - tags           : スパンの属性とリソースの属性 (同じキーの場合はスパンの属性を優先)、配列は JSON 文字列
+//   - ステータス     : otel.status_code (OK / ERROR) と、ERROR の場合は error タグに説明を設定する
+//   - annotations    : イベント名 (属性がある場合は "名前: {属性の JSON}")
+//
+// timestamp / duration はマイクロ秒で表現する。
+
+// ZipkinConfig は Zipkin エクスポーターの接続設定
+type ZipkinConfig struct {
+	// Endpoint は送信先の URL。空の場合は http://localhost:9411/api/v2/spans
+	Endpoint string
+
+	// Headers は全リクエストに付与するヘッダー
+	Headers map[string]string
+
+	// Timeout は1回のエクスポートのタイムアウト。0 の場合は10秒
+	Timeout time.Duration
+}
+
+const (
+	defaultZipkinEndpoint = "http://localhost:9411/api/v2/spans"
+	defaultZipkinTimeout  = 10 * time.Second
+)
+
+// zipkinSpan は Zipkin v2 のスパン
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name,omitempty"`
+	Kind           string             `json:"kind,omitempty"`
+	Timestamp      int64              `json:"timestamp,omitempty"`
+	Duration       int64              `json:"duration,omitempty"`
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+}
+
+// zipkinEndpoint は Zipkin v2 のエンドポイント (サービス名・IP アドレス・ポート)
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+// zipkinAnnotation は Zipkin v2 のアノテーション (時刻付きのイベント)
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"`
+	Value     string `json:"value"`
+}
+
+// zipkinExporter は Zipkin v2 の JSON 形式でスパンを送信する sdktrace.SpanExporter
+type zipkinExporter struct {
+	client   *http.Client
+	endpoint string
+	headers  map[string]string
+	stopped  atomic.Bool
+}
+
+var _ sdktrace.SpanExporter = (*zipkinExporter)(nil)
+
+// newZipkinExporter は zipkinExporter を生成する
+func newZipkinExporter(cfg ZipkinConfig) (sdktrace.SpanExporter, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultZipkinEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("otel: invalid zipkin endpoint %q: must be an absolute http or https URL", endpoint)
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultZipkinTimeout
+	}
+	return &zipkinExporter{
+		client:   &http.Client{Timeout: timeout},
+		endpoint: endpoint,
+		headers:  cfg.Headers,
+	}, nil
+}
+
+// ExportSpans はスパンを Zipkin v2 の JSON に変換して送信する
+func (e *zipkinExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if e.stopped.Load() || len(spans) == 0 {
+		return nil
+	}
+	models := make([]zipkinSpan, len(spans))
+	for i, s := range spans {
+		models[i] = toZipkinSpan(s)
+	}
+	body, err := json.Marshal(models)
+	if err != nil {
+		return fmt.Errorf("otel: encode zipkin spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otel: export spans to zipkin: %w", err)
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("otel: export spans to zipkin: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Shutdown は以降のエクスポートを停止する
+func (e *zipkinExporter) Shutdown(ctx context.Context) error {
+	e.stopped.Store(true)
+	e.client.CloseIdleConnections()
+	return ctx.Err()
+}
+
+// toZipkinSpan は ReadOnlySpan を Zipkin v2 のスパンに変換する
+func toZipkinSpan(s sdktrace.ReadOnlySpan) zipkinSpan {
+	sc := s.SpanContext()
+	z := zipkinSpan{
+		TraceID:        sc.TraceID().String(),
+		ID:             sc.SpanID().String(),
+		Name:           s.Name(),
+		Kind:           toZipkinKind(s.SpanKind()),
+		Timestamp:      s.StartTime().UnixMicro(),
+		Duration:       max(s.EndTime().Sub(s.StartTime()).Microseconds(), 1), // NOTE: Zipkin では 0 が「不明」を表すため、1マイクロ秒未満は1に切り上げる
+		RemoteEndpoint: toZipkinRemoteEndpoint(s),
+		Annotations:    toZipkinAnnotations(s.Events()),
+		Tags:           toZipkinTags(s),
+	}
+	if parent := s.Parent(); parent.SpanID().IsValid() {
+		z.ParentID = parent.SpanID().String()
+	}
+	if name, ok := s.Resource().Set().Value(semconv.ServiceNameKey); ok {
+		z.LocalEndpoint = &zipkinEndpoint{ServiceName: name.Emit()}
+	}
+	return z
+}
+
+// toZipkinKind はスパンの種類を Zipkin の kind に変換する (INTERNAL と未指定は省略する)
+func toZipkinKind(kind trace.SpanKind) string {
+	switch kind {
+	case trace.SpanKindServer:
+		return "SERVER"
+	case trace.SpanKindClient:
+		return "CLIENT"
+	case trace.SpanKindProducer:
+		return "PRODUCER"
+	case trace.SpanKindConsumer:
+		return "CONSUMER"
+	default:
+		return ""
+	}
+}
+
+// toZipkinTags はスパンの属性・リソースの属性・ステータス・計装スコープを Zipkin のタグに変換する
+func toZipkinTags(s sdktrace.ReadOnlySpan) map[string]string {
+	tags := make(map[string]string)
+	for _, kv := range s.Resource().Attributes() {
+		if kv.Key == semconv.ServiceNameKey {
+			continue // NOTE: localEndpoint.serviceName として送信する
+		}
+		tags[string(kv.Key)] = zipkinTagValue(kv.Value)
+	}
+	for _, kv := range s.Attributes() {
+		tags[string(kv.Key)] = zipkinTagValue(kv.Value)
+	}
+
+	// NOTE: Zipkin は error タグの有無でエラーを判定するため、エラー以外のスパンでは属性の error を削除する
+	delete(tags, "error")
+	switch status := s.Status(); status.Code {
+	case codes.Ok:
+		tags["otel.status_code"] = "OK"
+	case codes.Error:
+		tags["otel.status_code"] = "ERROR"
+		tags["error"] = status.Description
+	}
+
+	if scope := s.InstrumentationScope(); scope.Name != "" {
+		tags["otel.scope.name"] = scope.Name
+		if scope.Version != "" {
+			tags["otel.scope.version"] = scope.Version
+		}
+	}
+	if n := s.DroppedAttributes(); n > 0 {
+		tags["otel.dropped_attributes_count"] = strconv.Itoa(n)
+	}
+	if n := s.DroppedEvents(); n > 0 {
+		tags["otel.dropped_events_count"] = strconv.Itoa(n)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// zipkinTagValue は属性の値を Zipkin のタグの文字列に変換する (配列は JSON 文字列)
+func zipkinTagValue(v attribute.Value) string {
+	switch v.Type() {
+	case attribute.BOOLSLICE, attribute.INT64SLICE, attribute.FLOAT64SLICE, attribute.STRINGSLICE:
+		b, err := json.Marshal(v.AsInterface())
+		if err != nil {
+			return v.Emit()
+		}
+		return string(b)
+	default:
+		return v.Emit()
+	}
+}
+
+// toZipkinAnnotations はイベントを Zipkin のアノテーションに変換する
+func toZipkinAnnotations(events []sdktrace.Event) []zipkinAnnotation {
+	if len(events) == 0 {
+		return nil
+	}
+	annotations := make([]zipkinAnnotation, 0, len(events))
+	for _, ev := range events {
+		value := ev.Name
+		if len(ev.Attributes) > 0 {
+			attrs := make(map[string]any, len(ev.Attributes))
+			for _, kv := range ev.Attributes {
+				attrs[string(kv.Key)] = kv.Value.AsInterface()
+			}
+			if b, err := json.Marshal(attrs); err == nil {
+				value = ev.Name + ": " + string(b)
+			}
+		}
+		annotations = append(annotations, zipkinAnnotation{Timestamp: ev.Time.UnixMicro(), Value: value})
+	}
+	return annotations
+}
+
+// zipkinRemoteEndpointKeys は remoteEndpoint を決定する属性 (優先順、OpenTelemetry の仕様の順序から非推奨の属性を除いたもの)
+var zipkinRemoteEndpointKeys = []attribute.Key{
+	"peer.service",
+	semconv.ServerAddressKey,
+	semconv.NetworkPeerAddressKey,
+	"peer.hostname",
+	"peer.address",
+	"db.name",
+}
+
+// toZipkinRemoteEndpoint は CLIENT / PRODUCER のスパンの接続先を返す (該当する属性がない場合は nil)
+func toZipkinRemoteEndpoint(s sdktrace.ReadOnlySpan) *zipkinEndpoint {
+	if kind := s.SpanKind(); kind != trace.SpanKindClient && kind != trace.SpanKindProducer {
+		return nil
+	}
+	set := attribute.NewSet(s.Attributes()...)
+	for _, key := range zipkinRemoteEndpointKeys {
+		v, ok := set.Value(key)
+		if !ok || v.AsString() == "" {
+			continue
+		}
+		if key != semconv.NetworkPeerAddressKey {
+			return &zipkinEndpoint{ServiceName: v.AsString()}
+		}
+		// NOTE: network.peer.address は IP アドレスの場合のみ、network.peer.port と組み合わせて ipv4 / ipv6 に設定する
+		ip := net.ParseIP(v.AsString())
+		if ip == nil {
+			continue
+		}
+		ep := &zipkinEndpoint{}
+		if ip.To4() != nil {
+			ep.IPv4 = ip.String()
+		} else {
+			ep.IPv6 = ip.String()
+		}
+		if port, ok := set.Value(semconv.NetworkPeerPortKey); ok {
+			ep.Port = int(port.AsInt64())
+		}
+		return ep
+	}
+	return nil
+}
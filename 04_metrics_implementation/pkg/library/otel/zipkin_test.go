@@ -0,0 +1,268 @@
+package otel
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// zipkinTestSpans は種類・ステータス・接続先の異なるスパンを返す
+func zipkinTestSpans() []sdktrace.ReadOnlySpan {
+	traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+	sc := func(id string) trace.SpanContext {
+		spanID, _ := trace.SpanIDFromHex(id)
+		return trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled})
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "article-api"),
+		attribute.String("deployment.environment", "test"),
+	)
+	// NOTE: ナノ秒の端数はマイクロ秒に切り捨てられる
+	start := time.Unix(1700000000, 1500)
+
+	return tracetest.SpanStubs{
+		{
+			Name:        "http-server",
+			SpanContext: sc("2122232425262728"),
+			SpanKind:    trace.SpanKindServer,
+			StartTime:   start,
+			EndTime:     start.Add(3 * time.Millisecond),
+			Attributes:  []attribute.KeyValue{attribute.String("error", "not an error"), attribute.StringSlice("http.tags", []string{"a", "b"})},
+			Status:      sdktrace.Status{Code: codes.Ok},
+			Resource:    res,
+		},
+		{
+			Name:        "ArticleRepository.FindByID",
+			SpanContext: sc("1112131415161718"),
+			Parent:      sc("2122232425262728"),
+			SpanKind:    trace.SpanKindClient,
+			StartTime:   start,
+			EndTime:     start.Add(1500*time.Microsecond + 400),
+			Attributes: []attribute.KeyValue{
+				attribute.String("peer.service", "postgres"),
+				attribute.String("db.system", "postgresql"),
+				attribute.String("deployment.environment", "span"),
+			},
+			Events: []sdktrace.Event{
+				{Name: "retry", Time: start.Add(100 * time.Microsecond), Attributes: []attribute.KeyValue{attribute.Int("attempt", 2)}},
+				{Name: "connected", Time: start.Add(200 * time.Microsecond)},
+			},
+			Status:               sdktrace.Status{Code: codes.Error, Description: "connection refused"},
+			Resource:             res,
+			InstrumentationScope: instrumentation.Scope{Name: "repository", Version: "1.0.0"},
+		},
+		{
+			Name:        "publish",
+			SpanContext: sc("3132333435363738"),
+			Parent:      sc("2122232425262728"),
+			SpanKind:    trace.SpanKindProducer,
+			StartTime:   start,
+			EndTime:     start.Add(time.Millisecond),
+			Attributes: []attribute.KeyValue{
+				attribute.String("network.peer.address", "10.0.0.1"),
+				attribute.Int("network.peer.port", 5672),
+			},
+			Resource: res,
+		},
+		{
+			Name:        "consume",
+			SpanContext: sc("4142434445464748"),
+			SpanKind:    trace.SpanKindConsumer,
+			StartTime:   start,
+			EndTime:     start.Add(time.Millisecond),
+			Attributes:  []attribute.KeyValue{attribute.String("peer.service", "ignored")},
+			Resource:    res,
+		},
+		{
+			Name:        "ArticleUsecase.Create",
+			SpanContext: sc("5152535455565758"),
+			SpanKind:    trace.SpanKindInternal,
+			StartTime:   start,
+			EndTime:     start,
+			Resource:    res,
+		},
+	}.Snapshots()
+}
+
+func TestZipkinExporter(t *testing.T) {
+	receiver := startHTTPReceiver(t)
+	exporter, err := newZipkinExporter(ZipkinConfig{
+		Endpoint: receiver.URL + "/api/v2/spans",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exporter.ExportSpans(context.Background(), zipkinTestSpans()); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("received %d requests, want 1", len(requests))
+	}
+	req := requests[0]
+	if req.path != "/api/v2/spans" || req.contentType != "application/json" || req.header.Get("Authorization") != "Bearer token" {
+		t.Errorf("request = %s (Content-Type %q, Authorization %q)", req.path, req.contentType, req.header.Get("Authorization"))
+	}
+	var spans []zipkinSpan
+	if err := json.Unmarshal(req.body, &spans); err != nil {
+		t.Fatalf("decode zipkin payload: %v", err)
+	}
+	if len(spans) != 5 {
+		t.Fatalf("decoded %d spans, want 5", len(spans))
+	}
+	local := &zipkinEndpoint{ServiceName: "article-api"}
+	const startMicros = 1700000000000001
+
+	tests := []struct {
+		name string
+		got  zipkinSpan
+		want zipkinSpan
+	}{
+		{
+			name: "server",
+			got:  spans[0],
+			want: zipkinSpan{
+				TraceID:       "0102030405060708090a0b0c0d0e0f10",
+				ID:            "2122232425262728",
+				Name:          "http-server",
+				Kind:          "SERVER",
+				Timestamp:     startMicros,
+				Duration:      3000,
+				LocalEndpoint: local,
+				Tags: map[string]string{
+					"deployment.environment": "test",
+					"http.tags":              `["a","b"]`,
+					"otel.status_code":       "OK",
+				},
+			},
+		},
+		{
+			name: "client",
+			got:  spans[1],
+			want: zipkinSpan{
+				TraceID:        "0102030405060708090a0b0c0d0e0f10",
+				ID:             "1112131415161718",
+				ParentID:       "2122232425262728",
+				Name:           "ArticleRepository.FindByID",
+				Kind:           "CLIENT",
+				Timestamp:      startMicros,
+				Duration:       1500,
+				LocalEndpoint:  local,
+				RemoteEndpoint: &zipkinEndpoint{ServiceName: "postgres"},
+				Annotations: []zipkinAnnotation{
+					{Timestamp: startMicros + 100, Value: `retry: {"attempt":2}`},
+					{Timestamp: startMicros + 200, Value: "connected"},
+				},
+				Tags: map[string]string{
+					"deployment.environment": "span",
+					"peer.service":           "postgres",
+					"db.system":              "postgresql",
+					"otel.status_code":       "ERROR",
+					"error":                  "connection refused",
+					"otel.scope.name":        "repository",
+					"otel.scope.version":     "1.0.0",
+				},
+			},
+		},
+		{
+			name: "producer",
+			got:  spans[2],
+			want: zipkinSpan{
+				TraceID:        "0102030405060708090a0b0c0d0e0f10",
+				ID:             "3132333435363738",
+				ParentID:       "2122232425262728",
+				Name:           "publish",
+				Kind:           "PRODUCER",
+				Timestamp:      startMicros,
+				Duration:       1000,
+				LocalEndpoint:  local,
+				RemoteEndpoint: &zipkinEndpoint{IPv4: "10.0.0.1", Port: 5672},
+				Tags: map[string]string{
+					"deployment.environment": "test",
+					"network.peer.address":   "10.0.0.1",
+					"network.peer.port":      "5672",
+				},
+			},
+		},
+		{
+			name: "consumer has no remote endpoint",
+			got:  spans[3],
+			want: zipkinSpan{
+				TraceID:       "0102030405060708090a0b0c0d0e0f10",
+				ID:            "4142434445464748",
+				Name:          "consume",
+				Kind:          "CONSUMER",
+				Timestamp:     startMicros,
+				Duration:      1000,
+				LocalEndpoint: local,
+				Tags: map[string]string{
+					"deployment.environment": "test",
+					"peer.service":           "ignored",
+				},
+			},
+		},
+		{
+			name: "internal omits kind and rounds duration up",
+			got:  spans[4],
+			want: zipkinSpan{
+				TraceID:       "0102030405060708090a0b0c0d0e0f10",
+				ID:            "5152535455565758",
+				Name:          "ArticleUsecase.Create",
+				Timestamp:     startMicros,
+				Duration:      1,
+				LocalEndpoint: local,
+				Tags:          map[string]string{"deployment.environment": "test"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.got, tt.want) {
+				got, _ := json.MarshalIndent(tt.got, "", "  ")
+				want, _ := json.MarshalIndent(tt.want, "", "  ")
+				t.Errorf("span =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+
+	// NOTE: kind が INTERNAL のスパンは JSON に kind を含めない
+	var raw []map[string]any
+	if err := json.Unmarshal(req.body, &raw); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := raw[4]["kind"]; ok {
+		t.Errorf("internal span has kind %v, want it omitted", raw[4]["kind"])
+	}
+}
+
+func TestZipkinExporterReturnsErrorStatus(t *testing.T) {
+	receiver := startHTTPReceiver(t)
+	receiver.respond = func(w http.ResponseWriter, _ httpRequest) {
+		http.Error(w, "invalid span", http.StatusBadRequest)
+	}
+	exporter, err := newZipkinExporter(ZipkinConfig{Endpoint: receiver.URL + "/api/v2/spans"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exporter.ExportSpans(context.Background(), zipkinTestSpans())
+	if err == nil {
+		t.Fatal("ExportSpans succeeded, want an error for 400")
+	}
+	if want := "otel: export spans to zipkin: 400 Bad Request: invalid span"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}